@@ -0,0 +1,51 @@
+// Команда rotate-keys шифрует данные, записанные открытыми до включения шифрования или до ключей
+// документов, затем переоборачивает ключи данных активным мастер-ключом ENCRYPTION_KEY_ID
+// и печатает отчет в формате JSON. Уже зашифрованное содержимое файлов не перечитывается.
+// После завершения старый мастер-ключ можно убрать из конфигурации.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+	"github.com/paudarco/doc-storage/pkg/logger"
+	"github.com/paudarco/doc-storage/pkg/postgres"
+)
+
+func main() {
+	batch := flag.Int("batch", 500, "keys read per query")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	log := logger.InitLogger(cfg.Env)
+
+	keys, err := envelope.NewKeyring(cfg.Encryption)
+	if err != nil {
+		log.Fatalf("error loading encryption keys: %s", err.Error())
+	}
+
+	pool, err := postgres.NewPostgresPool(cfg.DB)
+	if err != nil {
+		log.Fatalf("error creating pool: %s", err.Error())
+	}
+	defer pool.Close()
+
+	rotator := service.NewKeyRotator(repository.NewKeyRepository(pool, keys, cfg.EncryptionSearchIndex), keys, log)
+	report, err := rotator.Rotate(context.Background(), *batch)
+	if err != nil {
+		log.Fatalf("rotation failed: %s", err.Error())
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+
+	log.Infof("encrypted %d documents and %d blobs, rewrapped %d blob and %d document keys with %s, %d skipped",
+		report.EncryptedDocuments, report.EncryptedBlobs, report.Blobs, report.Documents, report.ActiveKeyID, report.Skipped)
+}
@@ -0,0 +1,53 @@
+// Команда scrub перечитывает содержимое всех файлов, сверяет его с SHA-256 и печатает отчет
+// в формате JSON. С -quarantine поврежденное содержимое помечается и больше не отдается.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+	"github.com/paudarco/doc-storage/pkg/logger"
+	"github.com/paudarco/doc-storage/pkg/postgres"
+)
+
+func main() {
+	quarantine := flag.Bool("quarantine", false, "mark corrupt content so it is no longer served")
+	batch := flag.Int("batch", 50, "blobs read per query")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	log := logger.InitLogger(cfg.Env)
+
+	pool, err := postgres.NewPostgresPool(cfg.DB)
+	if err != nil {
+		log.Fatalf("error creating pool: %s", err.Error())
+	}
+	defer pool.Close()
+
+	keys, err := envelope.NewKeyring(cfg.Encryption)
+	if err != nil {
+		log.Fatalf("error loading encryption keys: %s", err.Error())
+	}
+
+	scrubber := service.NewScrubber(repository.NewBlobRepository(pool, keys), log)
+	report, err := scrubber.Scrub(context.Background(), *batch, *quarantine)
+	if err != nil {
+		log.Fatalf("scrub failed: %s", err.Error())
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+
+	log.Infof("checked %d blobs, %d corrupt", report.Checked, len(report.Corrupt))
+	if len(report.Corrupt) > 0 {
+		pool.Close()
+		os.Exit(1)
+	}
+}
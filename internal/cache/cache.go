@@ -1,43 +1,58 @@
-package cache
-
-import (
-	"context"
-	"time"
-
-	"github.com/paudarco/doc-storage/internal/config"
-	"github.com/redis/go-redis/v9"
-)
-
-const (
-	TokenPrefix    = "token:"
-	DocPrefix      = "doc:"
-	DocListPrefix  = "doc_list:"
-	UserDocsPrefix = "user_docs:"
-)
-
-type Token interface {
-	SetToken(ctx context.Context, token, userID string) error
-	GetUserIDByToken(ctx context.Context, token string) (string, error)
-	DeleteToken(ctx context.Context, token string) error
-}
-
-type Doc interface {
-	SetDoc(ctx context.Context, id string, docData []byte) error
-	GetDoc(ctx context.Context, id string) (*[]byte, error)
-	DeleteDoc(ctx context.Context, id string) error
-	SetDocList(ctx context.Context, cacheKey string, listData []byte) error
-	GetDocList(ctx context.Context, cacheKey string) (*[]byte, error)
-	InvalidateUserDocLists(ctx context.Context, userID string) error
-}
-
-type Cache struct {
-	Token
-	Doc
-}
-
-func NewCache(cache *redis.Client, cfg *config.Config) *Cache {
-	return &Cache{
-		Token: NewTokenCache(cache, time.Duration(cfg.AccessTTL)*time.Hour),
-		Doc:   NewDocCache(cache, time.Duration(cfg.DocTTL)*time.Hour),
-	}
-}
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TokenPrefix    = "token:"
+	DocPrefix      = "doc:"
+	DocListPrefix  = "doc_list:"
+	UserDocsPrefix = "user_docs:"
+
+	LinkDownloadsPrefix = "link_downloads:"
+	LinkFailuresPrefix  = "link_failures:"
+)
+
+type Token interface {
+	SetToken(ctx context.Context, token, userID string) error
+	GetUserIDByToken(ctx context.Context, token string) (string, error)
+	DeleteToken(ctx context.Context, token string) error
+}
+
+type Doc interface {
+	SetDoc(ctx context.Context, id string, docData []byte, expiresAt *time.Time) error
+	GetDoc(ctx context.Context, id string) (*[]byte, error)
+	DeleteDoc(ctx context.Context, id string) error
+	DeleteDocs(ctx context.Context, ids []string) error
+	SetDocList(ctx context.Context, cacheKey string, listData []byte, expiresAt *time.Time) error
+	GetDocList(ctx context.Context, cacheKey string) (*[]byte, error)
+	InvalidateUserDocLists(ctx context.Context, userID string) error
+}
+
+type Link interface {
+	IncrLinkDownloads(ctx context.Context, linkID string, maxDownloads int, expiresAt time.Time) (int64, error)
+	GetLinkDownloads(ctx context.Context, linkIDs []string) (map[string]int64, error)
+	DeleteLinkDownloads(ctx context.Context, linkID string) error
+	GetLinkFailures(ctx context.Context, linkID string) (int64, error)
+	IncrLinkFailures(ctx context.Context, linkID string, window time.Duration) (int64, error)
+	DeleteLinkFailures(ctx context.Context, linkID string) error
+}
+
+type Cache struct {
+	Token
+	Doc
+	Link
+}
+
+func NewCache(cache *redis.Client, cfg *config.Config) *Cache {
+	return &Cache{
+		Token: NewTokenCache(cache, time.Duration(cfg.AccessTTL)*time.Hour),
+		Doc:   NewDocCache(cache, time.Duration(cfg.DocTTL)*time.Hour),
+		Link:  NewLinkCache(cache),
+	}
+}
@@ -1,102 +1,147 @@
-package cache
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"time"
-
-	"github.com/paudarco/doc-storage/internal/errors"
-	"github.com/redis/go-redis/v9"
-)
-
-type DocCache struct {
-	cache *redis.Client
-	exp   time.Duration
-}
-
-func NewDocCache(cache *redis.Client, exp time.Duration) *DocCache {
-	return &DocCache{
-		cache: cache,
-		exp:   exp,
-	}
-}
-
-func (c *DocCache) SetDoc(ctx context.Context, id string, docData []byte) error {
-	key := DocPrefix + id
-	return c.cache.Set(ctx, key, docData, c.exp).Err()
-}
-
-func (c *DocCache) GetDoc(ctx context.Context, id string) (*[]byte, error) {
-	key := DocPrefix + id
-	val, err := c.cache.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return nil, errors.ErrDocNotFound
-	} else if err != nil {
-		return nil, err
-	}
-
-	data := []byte(val)
-	return &data, nil
-}
-
-func (c *DocCache) DeleteDoc(ctx context.Context, id string) error {
-	key := DocPrefix + id
-	return c.cache.Del(ctx, key).Err()
-}
-
-func (c *DocCache) SetDocList(ctx context.Context, cacheKey string, listData []byte) error {
-	return c.cache.Set(ctx, cacheKey, listData, c.exp).Err()
-}
-
-func (c *DocCache) GetDocList(ctx context.Context, cacheKey string) (*[]byte, error) {
-	val, err := c.cache.Get(ctx, cacheKey).Result()
-	if err == redis.Nil {
-		return nil, errors.ErrDocListNotFound
-	} else if err != nil {
-		return nil, err
-	}
-
-	data := []byte(val)
-	return &data, nil
-}
-
-func BuildDocListCacheKey(userID, loginFilter, keyFilter, valueFilter string, limit int) string {
-	// Для простоты используем форматирование строки. В production лучше использовать хеширование.
-	return fmt.Sprintf("%s:%s:%s:%s:%s:%d", DocListPrefix, userID, loginFilter, keyFilter, valueFilter, limit)
-}
-
-// InvalidateUserDocLists Инвалидирует все списки документов конкретного пользователя
-// Это делается путем установки флага или удаления ключей по паттерну.
-// Более эффективный способ: использовать Redis Sets или Tags.
-// Здесь реализация через паттерн SCAN.
-func (c *DocCache) InvalidateUserDocLists(ctx context.Context, userID string) error {
-	pattern := fmt.Sprintf("%s:%s:*", DocListPrefix, userID)
-	iter := c.cache.Scan(ctx, 0, pattern, 0).Iterator()
-	for iter.Next(ctx) {
-		err := c.cache.Del(ctx, iter.Val()).Err()
-		if err != nil {
-			// Логируем ошибку, но продолжаем
-			log.Printf("Error deleting cache key %s: %v", iter.Val(), err)
-		}
-	}
-	return iter.Err()
-}
-
-// InvalidateAllDocListsForUserLogin Инвалидирует списки, где мог быть документ этого пользователя
-// Например, если документ стал публичным или изменился grant.
-// Это более сложная операция, требует отслеживания всех возможных списков.
-// Для упрощения можно инвалидировать все списки, связанные с этим пользователем как владельцем.
-// Или использовать более сложную систему тегов/индексов в Redis.
-// Пока реализуем как инвалидацию по userID владельца.
-func (c *DocCache) InvalidateAllDocListsForUserLogin(ctx context.Context, userLogin string) error {
-	// Эта операция сложнее, так как мы не знаем ID пользователя по логину напрямую в кэше.
-	// В реальном приложении либо храним маппинг login->userID в кэше, либо инвалидируем иначе.
-	// Пока оставим заглушку или реализуем позже.
-	// Например, можно хранить в Redis SET "user_login:{login}" -> userID
-	// Или просто инвалидировать все списки (неэффективно).
-	// Для демонстрации: инвалидируем по userID, который мы должны знать из контекста создания/обновления.
-	// В service.DocService.Create/Update/Delete мы будем вызывать InvalidateUserDocLists(userID)
-	// Это покроет большинство случаев.
-	return nil
-}
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+type DocCache struct {
+	cache *redis.Client
+	exp   time.Duration
+}
+
+func NewDocCache(cache *redis.Client, exp time.Duration) *DocCache {
+	return &DocCache{
+		cache: cache,
+		exp:   exp,
+	}
+}
+
+// SetDoc кэширует документ. Если у документа есть срок жизни, запись не переживет его.
+func (c *DocCache) SetDoc(ctx context.Context, id string, docData []byte, expiresAt *time.Time) error {
+	exp, ok := c.ttl(expiresAt)
+	if !ok {
+		return nil
+	}
+	key := DocPrefix + id
+	return c.cache.Set(ctx, key, docData, exp).Err()
+}
+
+func (c *DocCache) GetDoc(ctx context.Context, id string) (*[]byte, error) {
+	key := DocPrefix + id
+	val, err := c.cache.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, errors.ErrDocNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	data := []byte(val)
+	return &data, nil
+}
+
+func (c *DocCache) DeleteDoc(ctx context.Context, id string) error {
+	key := DocPrefix + id
+	return c.cache.Del(ctx, key).Err()
+}
+
+// DeleteDocs удаляет из кэша несколько документов одной командой
+func (c *DocCache) DeleteDocs(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = DocPrefix + id
+	}
+	return c.cache.Del(ctx, keys...).Err()
+}
+
+// SetDocList кэширует страницу списка не дольше, чем до истечения самого раннего документа на ней
+func (c *DocCache) SetDocList(ctx context.Context, cacheKey string, listData []byte, expiresAt *time.Time) error {
+	exp, ok := c.ttl(expiresAt)
+	if !ok {
+		return nil
+	}
+	return c.cache.Set(ctx, cacheKey, listData, exp).Err()
+}
+
+// ttl ограничивает время жизни записи моментом expiresAt. ok = false, если он уже наступил.
+func (c *DocCache) ttl(expiresAt *time.Time) (time.Duration, bool) {
+	if expiresAt == nil {
+		return c.exp, true
+	}
+	left := time.Until(*expiresAt)
+	if left <= 0 {
+		return 0, false
+	}
+	if c.exp > 0 && c.exp < left {
+		return c.exp, true
+	}
+	return left, true
+}
+
+func (c *DocCache) GetDocList(ctx context.Context, cacheKey string) (*[]byte, error) {
+	val, err := c.cache.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, errors.ErrDocListNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	data := []byte(val)
+	return &data, nil
+}
+
+// BuildDocListCacheKey строит ключ списка из хеша запроса. Ключ начинается с ID пользователя,
+// чтобы списки можно было инвалидировать по шаблону.
+func BuildDocListCacheKey(userID string, q *entity.DocListQuery) string {
+	// encoding/json сортирует ключи map, поэтому одинаковые запросы дают одинаковый хеш
+	data, _ := json.Marshal(q)
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%s:%s:%s", DocListPrefix, userID, hex.EncodeToString(sum[:]))
+}
+
+// InvalidateUserDocLists Инвалидирует все списки документов конкретного пользователя
+// Это делается путем установки флага или удаления ключей по паттерну.
+// Более эффективный способ: использовать Redis Sets или Tags.
+// Здесь реализация через паттерн SCAN.
+func (c *DocCache) InvalidateUserDocLists(ctx context.Context, userID string) error {
+	pattern := fmt.Sprintf("%s:%s:*", DocListPrefix, userID)
+	iter := c.cache.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		err := c.cache.Del(ctx, iter.Val()).Err()
+		if err != nil {
+			// Логируем ошибку, но продолжаем
+			log.Printf("Error deleting cache key %s: %v", iter.Val(), err)
+		}
+	}
+	return iter.Err()
+}
+
+// InvalidateAllDocListsForUserLogin Инвалидирует списки, где мог быть документ этого пользователя
+// Например, если документ стал публичным или изменился grant.
+// Это более сложная операция, требует отслеживания всех возможных списков.
+// Для упрощения можно инвалидировать все списки, связанные с этим пользователем как владельцем.
+// Или использовать более сложную систему тегов/индексов в Redis.
+// Пока реализуем как инвалидацию по userID владельца.
+func (c *DocCache) InvalidateAllDocListsForUserLogin(ctx context.Context, userLogin string) error {
+	// Эта операция сложнее, так как мы не знаем ID пользователя по логину напрямую в кэше.
+	// В реальном приложении либо храним маппинг login->userID в кэше, либо инвалидируем иначе.
+	// Пока оставим заглушку или реализуем позже.
+	// Например, можно хранить в Redis SET "user_login:{login}" -> userID
+	// Или просто инвалидировать все списки (неэффективно).
+	// Для демонстрации: инвалидируем по userID, который мы должны знать из контекста создания/обновления.
+	// В service.DocService.Create/Update/Delete мы будем вызывать InvalidateUserDocLists(userID)
+	// Это покроет большинство случаев.
+	return nil
+}
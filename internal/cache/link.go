@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// incrDownloadsScript атомарно увеличивает счетчик скачиваний и откатывает его,
+// если лимит уже исчерпан. Возвращает -1, когда скачивание не разрешено.
+var incrDownloadsScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIREAT', KEYS[1], ARGV[2])
+end
+local max = tonumber(ARGV[1])
+if max > 0 and n > max then
+	redis.call('DECR', KEYS[1])
+	return -1
+end
+return n
+`)
+
+// incrFailuresScript увеличивает счетчик неверных паролей. Срок жизни ставится при первой
+// ошибке, поэтому блокировка снимается через window после нее, а не после последней попытки.
+var incrFailuresScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+type LinkCache struct {
+	cache *redis.Client
+}
+
+func NewLinkCache(cache *redis.Client) *LinkCache {
+	return &LinkCache{cache: cache}
+}
+
+func (c *LinkCache) IncrLinkDownloads(ctx context.Context, linkID string, maxDownloads int, expiresAt time.Time) (int64, error) {
+	key := LinkDownloadsPrefix + linkID
+	n, err := incrDownloadsScript.Run(ctx, c.cache, []string{key}, maxDownloads, expiresAt.UnixMilli()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errors.ErrShareLinkExhausted
+	}
+	return n, nil
+}
+
+func (c *LinkCache) GetLinkDownloads(ctx context.Context, linkIDs []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(linkIDs))
+	if len(linkIDs) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(linkIDs))
+	for i, id := range linkIDs {
+		keys[i] = LinkDownloadsPrefix + id
+	}
+
+	vals, err := c.cache.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			n, _ := strconv.ParseInt(s, 10, 64)
+			result[linkIDs[i]] = n
+		}
+	}
+	return result, nil
+}
+
+func (c *LinkCache) DeleteLinkDownloads(ctx context.Context, linkID string) error {
+	return c.cache.Del(ctx, LinkDownloadsPrefix+linkID).Err()
+}
+
+func (c *LinkCache) GetLinkFailures(ctx context.Context, linkID string) (int64, error) {
+	n, err := c.cache.Get(ctx, LinkFailuresPrefix+linkID).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (c *LinkCache) IncrLinkFailures(ctx context.Context, linkID string, window time.Duration) (int64, error) {
+	return incrFailuresScript.Run(ctx, c.cache, []string{LinkFailuresPrefix + linkID}, window.Milliseconds()).Int64()
+}
+
+func (c *LinkCache) DeleteLinkFailures(ctx context.Context, linkID string) error {
+	return c.cache.Del(ctx, LinkFailuresPrefix+linkID).Err()
+}
@@ -1,58 +1,150 @@
-package config
-
-import (
-	"github.com/caarlos0/env/v11"
-	"github.com/joho/godotenv"
-)
-
-type (
-	Server struct {
-		Host string `env:"SERVER_HOST" envDefault:"192.168.0.158"`
-		Port string `env:"SERVER_PORT" envDefault:"8080"`
-		Env  string `env:"ENV" envDefault:""`
-	}
-
-	DB struct {
-		Host     string `env:"DB_HOST" envDefault:"localhost"`
-		Port     string `env:"DB_PORT" envDefault:"5432"`
-		User     string `env:"DB_USER" envDefault:"postgres"`
-		Password string `env:"DB_PASSWORD" envDefault:"postgres"`
-		Name     string `env:"DB_NAME" envDefault:"postgres"`
-		SSLMode  string `env:"DB_SSLMODE" envDefault:"disable"`
-	}
-
-	Redis struct {
-		Host     string `env:"REDIS_HOST" envDefault:"localhost"`
-		Port     string `env:"REDIS_PORT" envDefault:"6379"`
-		Password string `env:"REDIS_PASSWORD" envDefault:""`
-		DB       int    `env:"REDIS_DB" envDefault:"0"`
-	}
-
-	JWT struct {
-		Secret     string `env:"JWT_SECRET" envDefault:"your-secret-key"`
-		AccessTTL  int    `env:"ACCESS_JWT_TTL" envDefault:"24"` // hours
-		AdminToken string `env:"ADMIN_TOKEN" envDefault:""`
-		Issuer     string `env:"JWT_ISSUER" envDefault:"doc-storage"`
-	}
-
-	Doc struct {
-		DocTTL int `env:"DOC_TTL" envDefault:"24"` // hours
-	}
-)
-
-type Config struct {
-	Server
-	DB
-	Redis
-	JWT
-	Doc
-}
-
-func LoadConfig() *Config {
-	_ = godotenv.Load()
-
-	cfg := &Config{}
-	_ = env.Parse(cfg)
-
-	return cfg
-}
+package config
+
+import (
+	"github.com/caarlos0/env/v11"
+	"github.com/joho/godotenv"
+)
+
+type (
+	Server struct {
+		Host string `env:"SERVER_HOST" envDefault:"192.168.0.158"`
+		Port string `env:"SERVER_PORT" envDefault:"8080"`
+		Env  string `env:"ENV" envDefault:""`
+	}
+
+	DB struct {
+		Host     string `env:"DB_HOST" envDefault:"localhost"`
+		Port     string `env:"DB_PORT" envDefault:"5432"`
+		User     string `env:"DB_USER" envDefault:"postgres"`
+		Password string `env:"DB_PASSWORD" envDefault:"postgres"`
+		Name     string `env:"DB_NAME" envDefault:"postgres"`
+		SSLMode  string `env:"DB_SSLMODE" envDefault:"disable"`
+	}
+
+	Redis struct {
+		Host     string `env:"REDIS_HOST" envDefault:"localhost"`
+		Port     string `env:"REDIS_PORT" envDefault:"6379"`
+		Password string `env:"REDIS_PASSWORD" envDefault:""`
+		DB       int    `env:"REDIS_DB" envDefault:"0"`
+	}
+
+	JWT struct {
+		Secret     string `env:"JWT_SECRET" envDefault:"your-secret-key"`
+		AccessTTL  int    `env:"ACCESS_JWT_TTL" envDefault:"24"` // hours
+		AdminToken string `env:"ADMIN_TOKEN" envDefault:""`
+		Issuer     string `env:"JWT_ISSUER" envDefault:"doc-storage"`
+	}
+
+	Doc struct {
+		DocTTL          int   `env:"DOC_TTL" envDefault:"24"`               // hours
+		ListJSONMaxSize int64 `env:"LIST_JSON_MAX_SIZE" envDefault:"65536"` // bytes, лимит JSON для include=json
+		ArchiveMaxDocs  int   `env:"ARCHIVE_MAX_DOCS" envDefault:"10000"`   // документов в одном архиве
+		// Удаление документов с истекшим expires_at
+		DocReaperInterval int `env:"DOC_REAPER_INTERVAL" envDefault:"60"` // seconds, 0 отключает очистку
+		DocReaperBatch    int `env:"DOC_REAPER_BATCH" envDefault:"500"`
+	}
+
+	ShareLink struct {
+		LinkTTL    int `env:"SHARE_LINK_TTL" envDefault:"24"`      // hours
+		LinkMaxTTL int `env:"SHARE_LINK_MAX_TTL" envDefault:"720"` // hours
+		// Блокировка подбора пароля: после LinkMaxAttempts неверных паролей ссылка не принимает
+		// пароль LinkLockout секунд с первой ошибки, 0 отключает блокировку
+		LinkMaxAttempts int `env:"SHARE_LINK_MAX_ATTEMPTS" envDefault:"5"`
+		LinkLockout     int `env:"SHARE_LINK_LOCKOUT" envDefault:"900"` // seconds
+	}
+
+	Search struct {
+		SearchLanguage string `env:"SEARCH_LANGUAGE" envDefault:"simple"` // конфигурация Postgres text search по умолчанию
+	}
+
+	Quota struct {
+		QuotaMaxBytes int64 `env:"QUOTA_MAX_BYTES" envDefault:"0"` // bytes на пользователя, 0 - без ограничения
+		QuotaMaxDocs  int64 `env:"QUOTA_MAX_DOCS" envDefault:"0"`  // документов на пользователя, 0 - без ограничения
+	}
+
+	Upload struct {
+		UploadMaxSize int64 `env:"UPLOAD_MAX_SIZE" envDefault:"1073741824"` // bytes на один файл, 0 - без ограничения
+		// Шаблоны типов вида "image/png" или "image/*". Пустой allow разрешает все, кроме deny
+		UploadMimeAllow []string `env:"UPLOAD_MIME_ALLOW" envSeparator:","`
+		UploadMimeDeny  []string `env:"UPLOAD_MIME_DENY" envSeparator:"," envDefault:"application/x-dosexec,application/x-executable,application/x-mach-binary"`
+	}
+
+	Scan struct {
+		Scanner           string `env:"SCANNER" envDefault:"none"` // none или clamd
+		ClamdAddress      string `env:"CLAMD_ADDRESS" envDefault:"tcp://localhost:3310"`
+		ScanTimeout       int    `env:"SCAN_TIMEOUT" envDefault:"60"` // seconds на один файл
+		ScanWorkers       int    `env:"SCAN_WORKERS" envDefault:"2"`
+		ScanQueueSize     int    `env:"SCAN_QUEUE_SIZE" envDefault:"100"`
+		ScanSweepInterval int    `env:"SCAN_SWEEP_INTERVAL" envDefault:"300"` // seconds, 0 отключает повторный обход
+	}
+
+	Encryption struct {
+		// Мастер-ключи в формате "kid1:base64,kid2:base64" (32 байта), старые ключи оставляются для чтения
+		EncryptionKeys    map[string]string `env:"ENCRYPTION_KEYS" envSeparator:"," envKeyValSeparator:":"`
+		EncryptionKeyFile string            `env:"ENCRYPTION_KEY_FILE" envDefault:""` // файл со строками "kid:base64"
+		EncryptionKeyID   string            `env:"ENCRYPTION_KEY_ID" envDefault:""`   // активный ключ, пусто - без шифрования
+		// Поисковый индекс по содержимому зашифрованных документов хранит его слова открыто
+		EncryptionSearchIndex bool `env:"ENCRYPTION_SEARCH_INDEX" envDefault:"false"`
+	}
+
+	Blob struct {
+		BlobGCInterval int `env:"BLOB_GC_INTERVAL" envDefault:"3600"` // seconds, 0 отключает сборщик
+		BlobGCGrace    int `env:"BLOB_GC_GRACE" envDefault:"3600"`    // seconds, сколько хранить блоб без ссылок
+		BlobGCBatch    int `env:"BLOB_GC_BATCH" envDefault:"1000"`
+	}
+
+	Extract struct {
+		ExtractMaxEntries int   `env:"EXTRACT_MAX_ENTRIES" envDefault:"1000"`
+		ExtractMaxSize    int64 `env:"EXTRACT_MAX_SIZE" envDefault:"268435456"` // bytes, суммарно распакованных файлов
+		ExtractMaxRatio   int64 `env:"EXTRACT_MAX_RATIO" envDefault:"100"`      // распакованный размер / сжатый
+	}
+
+	Text struct {
+		TextWorkers   int `env:"TEXT_WORKERS" envDefault:"2"`
+		TextQueueSize int `env:"TEXT_QUEUE_SIZE" envDefault:"100"`
+	}
+
+	Thumbnail struct {
+		ThumbnailSizes     []int `env:"THUMBNAIL_SIZES" envSeparator:"," envDefault:"128,256,512"` // стороны квадрата в пикселях
+		ThumbnailWorkers   int   `env:"THUMBNAIL_WORKERS" envDefault:"2"`
+		ThumbnailQueueSize int   `env:"THUMBNAIL_QUEUE_SIZE" envDefault:"100"`
+		ThumbnailMaxPixels int   `env:"THUMBNAIL_MAX_PIXELS" envDefault:"50000000"` // защита от огромных изображений
+		ThumbnailCacheTTL  int   `env:"THUMBNAIL_CACHE_TTL" envDefault:"86400"`     // seconds, Cache-Control max-age
+	}
+
+	Presign struct {
+		// Ключи подписи в формате "kid1:secret1,kid2:secret2", старые ключи оставляются для проверки
+		PresignKeys   map[string]string `env:"PRESIGN_KEYS" envSeparator:"," envKeyValSeparator:":"`
+		PresignKeyID  string            `env:"PRESIGN_KEY_ID" envDefault:""`       // ключ, которым подписываются новые ссылки
+		PresignTTL    int               `env:"PRESIGN_TTL" envDefault:"900"`       // seconds
+		PresignMaxTTL int               `env:"PRESIGN_MAX_TTL" envDefault:"86400"` // seconds
+	}
+)
+
+type Config struct {
+	Server
+	DB
+	Redis
+	JWT
+	Doc
+	ShareLink
+	Presign
+	Search
+	Quota
+	Upload
+	Scan
+	Encryption
+	Blob
+	Extract
+	Text
+	Thumbnail
+}
+
+func LoadConfig() *Config {
+	_ = godotenv.Load()
+
+	cfg := &Config{}
+	_ = env.Parse(cfg)
+
+	return cfg
+}
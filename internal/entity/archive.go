@@ -0,0 +1,30 @@
+package entity
+
+// Форматы архива
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// ArchiveManifestName имя служебной записи архива со списком пропущенных документов
+const ArchiveManifestName = "manifest.json"
+
+// ArchiveRequest выбирает документы для архива: по списку ID, по папке (рекурсивно)
+// или, если не задано ни то ни другое, по фильтрам списка документов
+type ArchiveRequest struct {
+	IDs      []string `json:"ids"`
+	FolderID string   `json:"folder_id"`
+	Format   string   `json:"format"`
+}
+
+// ArchiveSkipped документ, не попавший в архив, и причина
+type ArchiveSkipped struct {
+	ID  string
+	Err error
+}
+
+type ArchiveResult struct {
+	Added     int
+	Skipped   []ArchiveSkipped
+	Truncated bool // достигнут лимит ARCHIVE_MAX_DOCS
+}
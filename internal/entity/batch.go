@@ -0,0 +1,38 @@
+package entity
+
+// Операции пакетного изменения документов
+const (
+	BatchDelete      = "delete"
+	BatchSetPublic   = "set_public"
+	BatchAddGrant    = "add_grant"
+	BatchRemoveGrant = "remove_grant"
+	BatchAddTags     = "add_tags"
+	BatchRemoveTags  = "remove_tags"
+	BatchMove        = "move"
+)
+
+const MaxBatchOperations = 1000
+
+type BatchOperation struct {
+	Op       string   `json:"op"`
+	ID       string   `json:"id"`
+	Public   *bool    `json:"public,omitempty"`    // set_public
+	Grant    *Grant   `json:"grant,omitempty"`     // add_grant
+	Login    string   `json:"login,omitempty"`     // remove_grant
+	Tags     []string `json:"tags,omitempty"`      // add_tags, remove_tags
+	FolderID *string  `json:"folder_id,omitempty"` // move, пустая строка - в корень
+}
+
+// BatchRequest список операций. Atomic применяет все операции или ни одной,
+// иначе ошибка одной операции не отменяет остальные.
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchResult struct {
+	Index int
+	ID    string
+	Op    string
+	Err   error
+}
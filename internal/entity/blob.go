@@ -0,0 +1,31 @@
+package entity
+
+import "io"
+
+// Blob содержимое файла, хранимое один раз на SHA-256
+type Blob struct {
+	Hash    string
+	Size    int64
+	Corrupt bool          // блоб в карантине
+	Content io.ReadCloser // расшифрованное содержимое, закрывает получатель
+}
+
+// BlobRef документ, ссылающийся на блоб
+type BlobRef struct {
+	DocumentID string `json:"id"`
+	UserID     string `json:"user_id"`
+	Name       string `json:"name"`
+}
+
+// CorruptBlob блоб, содержимое которого не совпало с хэшем
+type CorruptBlob struct {
+	Hash string    `json:"sha256"`
+	Docs []BlobRef `json:"docs"`
+}
+
+// ScrubReport результат проверки хранилища
+type ScrubReport struct {
+	Checked     int           `json:"checked"`
+	Corrupt     []CorruptBlob `json:"corrupt"`
+	Quarantined bool          `json:"quarantined"`
+}
@@ -1,18 +1,163 @@
-package entity
-
-import "time"
-
-type Document struct {
-	ID        string    `json:"id" db:"id"`
-	UserID    string    `json:"-" db:"user_id"`
-	Name      string    `json:"name" db:"name"`
-	IsFile    bool      `json:"file" db:"is_file"`
-	Public    bool      `json:"public" db:"public"`
-	Mime      string    `json:"mime,omitempty" db:"mime"`
-	Grant     []string  `json:"grant,omitempty" db:"grant"`
-	CreatedAt time.Time `json:"created" db:"created_at"`
-
-	// Эти поля не хранятся в БД, используются для передачи данных
-	JSONData interface{} `json:"json,omitempty" db:"-"` // Для JSON данных
-	FileData []byte      `json:"-" db:"-"`              // Для содержимого файла
-}
+package entity
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+type Permission string
+
+const (
+	PermissionNone  Permission = ""
+	PermissionRead  Permission = "read"
+	PermissionWrite Permission = "write"
+	PermissionShare Permission = "share"
+	PermissionOwner Permission = "owner"
+)
+
+var permissionLevels = map[Permission]int{
+	PermissionNone:  0,
+	PermissionRead:  1,
+	PermissionWrite: 2,
+	PermissionShare: 3,
+	PermissionOwner: 4,
+}
+
+// Level возвращает порядковый уровень права, неизвестные права считаются отсутствующими
+func (p Permission) Level() int {
+	return permissionLevels[p]
+}
+
+func (p Permission) Valid() bool {
+	return p.Level() > 0
+}
+
+// Allows проверяет, что право p не ниже требуемого
+func (p Permission) Allows(required Permission) bool {
+	return p.Level() >= required.Level()
+}
+
+type Grant struct {
+	Login      string     `json:"login" db:"login"`
+	Permission Permission `json:"permission" db:"permission"`
+}
+
+type Document struct {
+	ID       string   `json:"id" db:"id"`
+	UserID   string   `json:"-" db:"user_id"`
+	Name     string   `json:"name" db:"name"`
+	IsFile   bool     `json:"file" db:"is_file"`
+	Public   bool     `json:"public" db:"public"`
+	Mime     string   `json:"mime,omitempty" db:"mime"`
+	Grant    []Grant  `json:"grant,omitempty" db:"-"`
+	FolderID *string  `json:"folder_id,omitempty" db:"folder_id"`
+	Tags     []string `json:"tags,omitempty" db:"-"`
+
+	Attributes  map[string]interface{} `json:"attributes,omitempty" db:"attributes"` // Произвольные ключи meta
+	TextStatus  string                 `json:"text_status,omitempty" db:"text_status"`
+	TextError   string                 `json:"text_error,omitempty" db:"text_error"`
+	ThumbStatus string                 `json:"thumbnail_status,omitempty" db:"thumbnail_status"`
+	ScanStatus  string                 `json:"scan_status,omitempty" db:"scan_status"`
+	Size        int64                  `json:"size" db:"size"`                       // Размер файла или JSON в байтах
+	ContentHash string                 `json:"sha256,omitempty" db:"content_hash"`   // SHA-256 содержимого файла (hex)
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty" db:"expires_at"` // После этого момента документ скрыт и удаляется
+	LegalHold   bool                   `json:"legal_hold,omitempty" db:"legal_hold"` // Запрещает удаление, изменение и передачу
+	CreatedAt   time.Time              `json:"created" db:"created_at"`
+	UpdatedAt   time.Time              `json:"updated" db:"updated_at"`
+
+	// Эти поля не хранятся в БД, используются для передачи данных
+	Permission Permission    `json:"-" db:"-"`              // Эффективное право текущего пользователя
+	JSONData   interface{}   `json:"json,omitempty" db:"-"` // Для JSON данных
+	FileData   []byte        `json:"-" db:"-"`              // Содержимое файла при создании
+	Content    io.ReadCloser `json:"-" db:"-"`              // Поток содержимого файла при чтении, закрывает CloseContent
+
+	SearchLang  string `json:"-" db:"search_lang"`  // Конфигурация полнотекстового поиска
+	ContentText string `json:"-" db:"content_text"` // Извлеченный из файла текст для поиска
+}
+
+// CloseContent закрывает поток содержимого файла, если он открыт
+func (d *Document) CloseContent() {
+	if d.Content != nil {
+		d.Content.Close()
+		d.Content = nil
+	}
+}
+
+// Expired сообщает, истек ли срок жизни документа к моменту now
+func (d *Document) Expired(now time.Time) bool {
+	return d.ExpiresAt != nil && !now.Before(*d.ExpiresAt)
+}
+
+// DocUpdate описывает изменяемые поля метаданных документа, nil означает "не менять"
+type DocUpdate struct {
+	Name     *string `json:"name"`
+	Public   *bool   `json:"public"`
+	Mime     *string `json:"mime"`
+	FolderID *string `json:"folder_id"` // пустая строка перемещает документ в корень
+
+	// Attributes объединяются с текущими, ключ со значением null удаляется
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// ReservedMetaKeys ключи meta, которые не попадают в attributes
+var ReservedMetaKeys = map[string]struct{}{
+	"name":       {},
+	"file":       {},
+	"public":     {},
+	"mime":       {},
+	"grant":      {},
+	"tags":       {},
+	"folder_id":  {},
+	"lang":       {},
+	"extract":    {},
+	"sha256":     {},
+	"expires_at": {},
+	"ttl":        {},
+}
+
+const (
+	SortName       = "name"
+	SortCreated    = "created"
+	SortSize       = "size"
+	SortUpdated    = "updated"
+	AttrSortPrefix = "attr."
+)
+
+// DocSort порядок списка документов: по полю (name, created, size, updated) или по значению атрибута ("attr.<key>")
+type DocSort struct {
+	Field string
+	Desc  bool
+}
+
+func (s DocSort) Valid() bool {
+	switch s.Field {
+	case SortName, SortCreated, SortSize, SortUpdated:
+		return true
+	}
+	_, ok := s.AttrKey()
+	return ok
+}
+
+// AttrKey возвращает ключ атрибута, если сортировка идет по атрибуту
+func (s DocSort) AttrKey() (string, bool) {
+	if !strings.HasPrefix(s.Field, AttrSortPrefix) || s.Field == AttrSortPrefix {
+		return "", false
+	}
+	return strings.TrimPrefix(s.Field, AttrSortPrefix), true
+}
+
+type ShareRequest struct {
+	Grant []Grant `json:"grant"`
+}
+
+const AuditActionTransfer = "transfer"
+
+type TransferRequest struct {
+	Login string `json:"login" binding:"required"`
+}
+
+// HashCheckRequest список SHA-256 (hex) для проверки перед загрузкой
+type HashCheckRequest struct {
+	Hashes []string `json:"hashes"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+// ExtractResult манифест распаковки архива, загруженного с meta.extract=true
+type ExtractResult struct {
+	Docs    []ExtractedDoc    `json:"docs"`
+	Folders int               `json:"folders_created"`
+	Failed  []ExtractedFailed `json:"failed,omitempty"`
+}
+
+type ExtractedDoc struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type ExtractedFailed struct {
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+type Folder struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"-" db:"user_id"`
+	ParentID  *string   `json:"parent_id,omitempty" db:"parent_id"`
+	Name      string    `json:"name" db:"name"`
+	Grant     []Grant   `json:"grant,omitempty" db:"-"`
+	CreatedAt time.Time `json:"created" db:"created_at"`
+
+	Permission Permission `json:"-" db:"-"` // Эффективное право текущего пользователя
+}
+
+// FolderAccess право пользователя на папку и владелец дерева, в котором она лежит
+type FolderAccess struct {
+	OwnerID    string
+	Permission Permission
+}
+
+type CreateFolderRequest struct {
+	Name     string  `json:"name" binding:"required"`
+	ParentID *string `json:"parent_id"`
+}
+
+// FolderUpdate переименование и/или перемещение папки. Пустой parent_id означает корень.
+type FolderUpdate struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+type FolderContents struct {
+	Folder  *Folder     `json:"folder,omitempty"`
+	Folders []*Folder   `json:"folders"`
+	Docs    []*Document `json:"docs"`
+}
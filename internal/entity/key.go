@@ -0,0 +1,25 @@
+package entity
+
+// Владельцы обернутых ключей данных: содержимое файла (блоб) и документ
+const (
+	KeyOwnerBlob     = "blob"
+	KeyOwnerDocument = "document"
+)
+
+// WrappedKey ключ данных строки, обернутый мастер-ключом KeyID
+type WrappedKey struct {
+	Owner   string
+	ID      string
+	KeyID   string
+	Wrapped []byte
+}
+
+// RotateReport результат шифрования открытых данных и переобертывания ключей данных активным мастер-ключом
+type RotateReport struct {
+	ActiveKeyID        string `json:"active_key_id"`
+	EncryptedDocuments int    `json:"encrypted_documents"`
+	EncryptedBlobs     int    `json:"encrypted_blobs"`
+	Blobs              int    `json:"blobs"`
+	Documents          int    `json:"documents"`
+	Skipped            int    `json:"skipped"`
+}
@@ -0,0 +1,55 @@
+package entity
+
+import "time"
+
+const (
+	LinkStatusActive    = "active"
+	LinkStatusExpired   = "expired"
+	LinkStatusRevoked   = "revoked"
+	LinkStatusExhausted = "exhausted"
+)
+
+type ShareLink struct {
+	ID           string     `json:"id" db:"id"`
+	DocumentID   string     `json:"document_id" db:"document_id"`
+	CreatedBy    string     `json:"-" db:"created_by"`
+	TokenHash    string     `json:"-" db:"token_hash"`
+	PasswordHash string     `json:"-" db:"password_hash"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	MaxDownloads int        `json:"max_downloads" db:"max_downloads"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt    time.Time  `json:"created" db:"created_at"`
+
+	// Не хранятся в БД
+	Token     string `json:"token,omitempty" db:"-"` // Отдается только при создании ссылки
+	Downloads int64  `json:"downloads" db:"-"`       // Счетчик скачиваний из Redis
+}
+
+func (l *ShareLink) HasPassword() bool {
+	return l.PasswordHash != ""
+}
+
+func (l *ShareLink) Status(now time.Time) string {
+	switch {
+	case l.RevokedAt != nil:
+		return LinkStatusRevoked
+	case !now.Before(l.ExpiresAt):
+		return LinkStatusExpired
+	case l.MaxDownloads > 0 && l.Downloads >= int64(l.MaxDownloads):
+		return LinkStatusExhausted
+	default:
+		return LinkStatusActive
+	}
+}
+
+type CreateLinkRequest struct {
+	ExpiresIn    int        `json:"expires_in"` // seconds
+	ExpiresAt    *time.Time `json:"expires_at"`
+	MaxDownloads int        `json:"max_downloads"`
+	Password     string     `json:"password"`
+}
+
+// OpenLinkRequest тело POST /s/:token для ссылки с паролем
+type OpenLinkRequest struct {
+	Password string `json:"password" form:"password"`
+}
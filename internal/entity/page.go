@@ -0,0 +1,21 @@
+package entity
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// PageRequest параметры страницы списка. Cursor - непрозрачный курсор next/prev из предыдущего ответа,
+// Count запрашивает общее количество документов, подходящих под фильтры.
+type PageRequest struct {
+	Cursor string
+	Limit  int
+	Count  bool
+}
+
+type DocPage struct {
+	Docs  []*Document `json:"docs"`
+	Next  string      `json:"next,omitempty"`
+	Prev  string      `json:"prev,omitempty"`
+	Total *int        `json:"total,omitempty"`
+}
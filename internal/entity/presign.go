@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+type PresignRequest struct {
+	ExpiresIn int `json:"expires_in"` // seconds
+}
+
+type PresignedURL struct {
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	DocumentID string    `json:"id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// DocListQuery фильтры, сортировка и страница списка документов. Фильтры комбинируются через AND,
+// nil и пустые значения означают "не фильтровать".
+type DocListQuery struct {
+	Login string // владелец (логин или ID), пусто - текущий пользователь
+	Name  string // подстрока имени
+
+	Mime          string // точный тип или маска "image/*"
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	MinSize       *int64
+	MaxSize       *int64
+	Public        *bool
+	File          *bool
+	GrantedTo     string // логин, которому выдан грант на документ
+
+	Tags  TagFilter
+	Attrs map[string]string
+
+	Sort DocSort
+	Page PageRequest
+
+	// IncludeJSON добавляет в список содержимое JSON-документов размером не больше JSONMaxSize
+	IncludeJSON bool
+	JSONMaxSize int64
+}
@@ -0,0 +1,24 @@
+package entity
+
+// Quota ограничения хранилища пользователя, 0 означает без ограничения
+type Quota struct {
+	MaxBytes      int64 `json:"max_bytes"`
+	MaxDocs       int64 `json:"max_docs"`
+	MaxUploadSize int64 `json:"max_upload_size"` // размер одного файла
+}
+
+// Usage использование хранилища пользователем и действующие для него квоты
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Docs  int64 `json:"docs"`
+	Quota
+	// Override квоты заданы администратором, а не взяты из конфигурации
+	Override bool `json:"override"`
+}
+
+// QuotaOverride квоты пользователя, заданные администратором; nil возвращает значение по умолчанию
+type QuotaOverride struct {
+	MaxBytes      *int64 `json:"max_bytes"`
+	MaxDocs       *int64 `json:"max_docs"`
+	MaxUploadSize *int64 `json:"max_upload_size"`
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// RetentionRule запрещает удалять подходящие документы до RetainUntil. Заданные условия
+// объединяются через И, хотя бы одно из них обязательно.
+type RetentionRule struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"-"`
+	Owner       string    `json:"owner,omitempty"` // логин владельца документов
+	Tag         string    `json:"tag,omitempty"`
+	FolderID    string    `json:"folder_id,omitempty"` // папка вместе с вложенными
+	Mime        string    `json:"mime,omitempty"`      // тип или шаблон вида image/*
+	RetainUntil time.Time `json:"retain_until"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateRetentionRuleRequest struct {
+	Owner       string    `json:"owner"`
+	Tag         string    `json:"tag"`
+	FolderID    string    `json:"folder_id"`
+	Mime        string    `json:"mime"`
+	RetainUntil time.Time `json:"retain_until" binding:"required"`
+	Reason      string    `json:"reason"`
+}
+
+type LegalHoldRequest struct {
+	Hold *bool `json:"hold" binding:"required"`
+}
+
+// RetentionStatus ограничения на удаление документа: legal hold и срок по действующим правилам
+type RetentionStatus struct {
+	DocumentID    string     `json:"id"`
+	LegalHold     bool       `json:"legal_hold"`
+	RetainedUntil *time.Time `json:"retained_until,omitempty"`
+}
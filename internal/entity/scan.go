@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// Статусы антивирусной проверки файла
+const (
+	ScanStatusNone     = "none" // не файл, проверка не нужна
+	ScanStatusPending  = "pending"
+	ScanStatusClean    = "clean"
+	ScanStatusInfected = "infected"
+	ScanStatusError    = "error"
+)
+
+// QuarantinedDoc документ, не прошедший антивирусную проверку
+type QuarantinedDoc struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	Mime      string     `json:"mime,omitempty"`
+	Size      int64      `json:"size"`
+	Hash      string     `json:"sha256,omitempty"`
+	Status    string     `json:"scan_status"`
+	Signature string     `json:"signature,omitempty"`
+	ScannedAt *time.Time `json:"scanned_at,omitempty"`
+	CreatedAt time.Time  `json:"created"`
+}
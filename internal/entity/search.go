@@ -0,0 +1,19 @@
+package entity
+
+const (
+	SearchDefaultLimit = 20
+	SearchMaxLimit     = 100
+)
+
+// SearchQuery параметры полнотекстового поиска, пустой Lang означает язык из конфигурации
+type SearchQuery struct {
+	Query string
+	Lang  string
+	Limit int
+}
+
+type SearchResult struct {
+	Doc     *Document
+	Rank    float32
+	Snippet string
+}
@@ -0,0 +1,18 @@
+package entity
+
+const MaxTagLength = 64
+
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// TagFilter фильтр списка по тегам: MatchAll требует все теги (AND), иначе любой (OR)
+type TagFilter struct {
+	Tags     []string
+	MatchAll bool
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
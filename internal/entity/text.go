@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Статусы извлечения текста из файла
+const (
+	TextStatusNone        = "none" // не файл, текст не извлекается
+	TextStatusPending     = "pending"
+	TextStatusDone        = "done"
+	TextStatusFailed      = "failed"
+	TextStatusUnsupported = "unsupported"
+)
+
+type DocText struct {
+	DocumentID  string
+	Status      string
+	Error       string
+	Text        string
+	ExtractedAt *time.Time
+}
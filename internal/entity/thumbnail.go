@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// Статусы генерации превью
+const (
+	ThumbnailStatusNone    = "none" // не изображение, превью не строится
+	ThumbnailStatusPending = "pending"
+	ThumbnailStatusDone    = "done"
+	ThumbnailStatusFailed  = "failed"
+)
+
+// Thumbnail превью изображения, вписанное в квадрат Size x Size
+type Thumbnail struct {
+	DocumentID string
+	Size       int
+	Mime       string
+	Width      int
+	Height     int
+	Data       []byte
+	CreatedAt  time.Time
+}
@@ -1,83 +1,273 @@
-package errors
-
-import (
-	"errors"
-	"net/http"
-)
-
-var (
-	ErrInvalidRequestBody = errors.New("invalid request body")
-
-	ErrInvalidAdminToken  = errors.New("invalid admin token")
-	ErrInvalidToken       = errors.New("invalid token")
-	ErrTokenRequired      = errors.New("token required")
-	ErrTokenExpired       = errors.New("token expired")
-	ErrWrongToken         = errors.New("wrong token")
-	ErrInvalidAuthHeader  = errors.New("invalid auth header forman")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-
-	ErrUserNotFound     = errors.New("user not found")
-	ErrUserAlreadyExist = errors.New("user already exist")
-
-	ErrWrongPswrdLength   = errors.New("login and password must be at least 8 characters long")
-	ErrWrongLoginLength   = errors.New("login and password must be at least 8 characters long")
-	ErrLoginWithoutLatin  = errors.New("login must contain only latin letters and digits")
-	ErrPswrdWithoutLatin  = errors.New("password must contain only latin letters, digits and punct symbols")
-	ErrPswrdWithoutUpper  = errors.New("password must contain at least upper 2 letters")
-	ErrPswrdWithoutLower  = errors.New("password must contain at least lower 2 letters")
-	ErrPswrdWithoutDigit  = errors.New("password must contain at least 1 digit")
-	ErrPswrdWithoutSymbol = errors.New("password must contain at least 1 special character")
-
-	ErrDocNotFound      = errors.New("document not found")
-	ErrDocListNotFound  = errors.New("document list not found")
-	ErrMetaNameRequired = errors.New("meta.name is required")
-
-	ErrAccessDenied = errors.New("access denied")
-	ErrUnauthorized = errors.New("unautharized")
-)
-
-var badReqErrList map[error]interface{} = map[error]interface{}{
-	ErrInvalidRequestBody: nil,
-	ErrLoginWithoutLatin:  nil,
-	ErrPswrdWithoutLatin:  nil,
-	ErrWrongPswrdLength:   nil,
-	ErrWrongLoginLength:   nil,
-	ErrPswrdWithoutLower:  nil,
-	ErrPswrdWithoutUpper:  nil,
-	ErrPswrdWithoutDigit:  nil,
-	ErrPswrdWithoutSymbol: nil,
-	ErrMetaNameRequired:   nil,
-}
-
-var notFoundErrList map[error]interface{} = map[error]interface{}{
-	ErrDocNotFound:     nil,
-	ErrDocListNotFound: nil,
-	ErrUserNotFound:    nil,
-}
-
-var unauthErrList map[error]interface{} = map[error]interface{}{
-	ErrTokenRequired:      nil,
-	ErrInvalidAuthHeader:  nil,
-	ErrInvalidCredentials: nil,
-	ErrWrongToken:         nil,
-	ErrInvalidToken:       nil,
-	ErrTokenExpired:       nil,
-	ErrUnauthorized:       nil,
-}
-
-var forbiddenErrList map[error]interface{} = map[error]interface{}{
-	ErrInvalidAdminToken: nil,
-	ErrAccessDenied:      nil,
-}
-
-var conflictErrList map[error]interface{} = map[error]interface{}{
-	ErrUserAlreadyExist: nil,
-}
-
-var errorsList map[int]map[error]interface{} = map[int]map[error]interface{}{
-	http.StatusBadRequest:   badReqErrList,
-	http.StatusNotFound:     notFoundErrList,
-	http.StatusUnauthorized: unauthErrList,
-	http.StatusForbidden:    forbiddenErrList,
-	http.StatusConflict:     conflictErrList,
-}
+package errors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidRequestBody = errors.New("invalid request body")
+
+	ErrInvalidAdminToken  = errors.New("invalid admin token")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenRequired      = errors.New("token required")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrWrongToken         = errors.New("wrong token")
+	ErrInvalidAuthHeader  = errors.New("invalid auth header forman")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	ErrUserNotFound     = errors.New("user not found")
+	ErrUserAlreadyExist = errors.New("user already exist")
+
+	ErrWrongPswrdLength   = errors.New("login and password must be at least 8 characters long")
+	ErrWrongLoginLength   = errors.New("login and password must be at least 8 characters long")
+	ErrLoginWithoutLatin  = errors.New("login must contain only latin letters and digits")
+	ErrPswrdWithoutLatin  = errors.New("password must contain only latin letters, digits and punct symbols")
+	ErrPswrdWithoutUpper  = errors.New("password must contain at least upper 2 letters")
+	ErrPswrdWithoutLower  = errors.New("password must contain at least lower 2 letters")
+	ErrPswrdWithoutDigit  = errors.New("password must contain at least 1 digit")
+	ErrPswrdWithoutSymbol = errors.New("password must contain at least 1 special character")
+
+	ErrDocNotFound      = errors.New("document not found")
+	ErrDocListNotFound  = errors.New("document list not found")
+	ErrMetaNameRequired = errors.New("meta.name is required")
+	ErrInvalidExpiry    = errors.New("meta.expires_at must be a future RFC 3339 time and meta.ttl a positive number of seconds or duration; only one may be set")
+
+	ErrInvalidGrant         = errors.New("grant must be a list of logins or {login, permission} objects")
+	ErrInvalidPermission    = errors.New("permission must be one of: read, write, share, owner")
+	ErrPermissionEscalation = errors.New("cannot grant or revoke permission above your own")
+
+	ErrShareLinkNotFound    = errors.New("share link not found")
+	ErrShareLinkExpired     = errors.New("share link expired or revoked")
+	ErrShareLinkExhausted   = errors.New("share link download limit reached")
+	ErrShareLinkPassword    = errors.New("share link password required or wrong")
+	ErrShareLinkLocked      = errors.New("too many wrong passwords for this share link, try again later")
+	ErrInvalidLinkExpiry    = errors.New("share link expiry must be in the future and within the allowed maximum")
+	ErrInvalidLinkDownloads = errors.New("max_downloads must not be negative")
+
+	ErrDocAlreadyExist = errors.New("document already exist")
+
+	ErrPresignNotConfigured = errors.New("presigned urls are not configured")
+	ErrInvalidPresignTTL    = errors.New("presigned url expiry must be positive and within the allowed maximum")
+	ErrInvalidSignature     = errors.New("invalid url signature")
+	ErrSignatureExpired     = errors.New("url signature expired")
+	ErrUnknownSigningKey    = errors.New("unknown url signing key")
+
+	ErrFolderNotFound      = errors.New("folder not found")
+	ErrFolderAlreadyExist  = errors.New("folder with this name already exist")
+	ErrInvalidFolderName   = errors.New("folder name must not be empty, '.', '..' or contain '/'")
+	ErrFolderCycle         = errors.New("cannot move folder into itself or its subfolder")
+	ErrFolderOwnerMismatch = errors.New("cannot move between folders of different owners")
+
+	ErrInvalidTags   = errors.New("tags must be a list of non-empty strings up to 64 characters")
+	ErrInvalidSort   = errors.New("sort must be name, created, size, updated or attr.<key>")
+	ErrInvalidCursor = errors.New("invalid or stale cursor")
+
+	ErrInvalidMimeFilter = errors.New("mime filter must be type/subtype or type/*")
+	ErrInvalidDateFilter = errors.New("created_after and created_before must be RFC 3339 or YYYY-MM-DD dates")
+	ErrInvalidSizeFilter = errors.New("min_size and max_size must be non-negative integers")
+	ErrInvalidBoolFilter = errors.New("public and file filters must be true or false")
+
+	ErrInvalidFields  = errors.New("unknown field in fields")
+	ErrInvalidInclude = errors.New("include supports only json")
+	ErrInvalidSelect  = errors.New("select must be a path like $.a.b[0]")
+	ErrSelectOnFile   = errors.New("select is supported only for JSON documents")
+	ErrSelectNotFound = errors.New("select path not found in document")
+
+	ErrInvalidBatch           = errors.New("batch must contain from 1 to 1000 operations")
+	ErrInvalidBatchOp         = errors.New("unknown batch operation or missing operation arguments")
+	ErrUploadMetaOrder        = errors.New("meta must precede file parts")
+	ErrUploadMetaMismatch     = errors.New("meta array must contain an object for every file")
+	ErrInvalidArchiveFormat   = errors.New("invalid archive format, use zip or tar.gz")
+	ErrNotArchive             = errors.New("file is not a zip or tar archive")
+	ErrArchiveUnsafePath      = errors.New("archive contains absolute paths or paths outside the archive")
+	ErrArchiveTooManyEntries  = errors.New("archive has too many entries")
+	ErrArchiveTooLarge        = errors.New("archive is too large when unpacked")
+	ErrArchiveCompressionRate = errors.New("archive compression ratio is too high")
+	ErrBlobNotFound           = errors.New("no document with this sha256")
+	ErrInvalidHash            = errors.New("hashes must be a list of 1 to 1000 hex sha256 values")
+	ErrInvalidDigest          = errors.New("invalid sha256 digest")
+	ErrDigestMismatch         = errors.New("content does not match the supplied sha256 digest")
+	ErrContentCorrupt         = errors.New("stored content failed integrity check")
+	ErrBatchRolledBack        = errors.New("operation rolled back because another operation in the atomic batch failed")
+
+	ErrQuotaExceeded    = errors.New("storage quota exceeded")
+	ErrDocQuotaExceeded = errors.New("document count quota exceeded")
+	ErrDocExceedsQuota  = errors.New("document is larger than the storage quota")
+	ErrInvalidQuota     = errors.New("quota must be a non-negative number or null")
+
+	ErrUploadTooLarge = errors.New("file exceeds the maximum upload size")
+	ErrMimeMismatch   = errors.New("file content does not match the declared mime type")
+	ErrMimeDenied     = errors.New("file type is not accepted")
+	ErrMimeNotAllowed = errors.New("file type is not in the list of allowed types")
+
+	ErrScanPending       = errors.New("file is being scanned for malware, try again later")
+	ErrScanFailed        = errors.New("file could not be scanned for malware")
+	ErrContentInfected   = errors.New("file is quarantined: malware detected")
+	ErrInvalidScanStatus = errors.New("status must be pending, infected or error")
+
+	ErrLegalHold             = errors.New("document is under legal hold")
+	ErrRetentionActive       = errors.New("document is under a retention policy and cannot be deleted or taken out of it yet")
+	ErrRetentionRuleNotFound = errors.New("retention rule not found")
+	ErrInvalidRetentionRule  = errors.New("retention rule needs owner, tag, folder_id or mime and a future retain_until")
+
+	ErrSearchQueryRequired = errors.New("search query q is required")
+	ErrInvalidQuery        = errors.New("invalid query parameter, limit must be an integer")
+	ErrInvalidSearchLang   = errors.New("unknown search language")
+
+	ErrThumbnailNotFound    = errors.New("thumbnail is not available")
+	ErrThumbnailPending     = errors.New("thumbnail is being generated")
+	ErrInvalidThumbnailSize = errors.New("unsupported thumbnail size")
+
+	ErrAccessDenied = errors.New("access denied")
+	ErrUnauthorized = errors.New("unautharized")
+)
+
+var badReqErrList map[error]interface{} = map[error]interface{}{
+	ErrInvalidRequestBody:     nil,
+	ErrLoginWithoutLatin:      nil,
+	ErrPswrdWithoutLatin:      nil,
+	ErrWrongPswrdLength:       nil,
+	ErrWrongLoginLength:       nil,
+	ErrPswrdWithoutLower:      nil,
+	ErrPswrdWithoutUpper:      nil,
+	ErrPswrdWithoutDigit:      nil,
+	ErrPswrdWithoutSymbol:     nil,
+	ErrMetaNameRequired:       nil,
+	ErrInvalidExpiry:          nil,
+	ErrInvalidGrant:           nil,
+	ErrInvalidPermission:      nil,
+	ErrInvalidLinkExpiry:      nil,
+	ErrInvalidLinkDownloads:   nil,
+	ErrInvalidPresignTTL:      nil,
+	ErrInvalidFolderName:      nil,
+	ErrFolderCycle:            nil,
+	ErrFolderOwnerMismatch:    nil,
+	ErrInvalidTags:            nil,
+	ErrInvalidSort:            nil,
+	ErrInvalidCursor:          nil,
+	ErrInvalidMimeFilter:      nil,
+	ErrInvalidDateFilter:      nil,
+	ErrInvalidSizeFilter:      nil,
+	ErrInvalidBoolFilter:      nil,
+	ErrInvalidFields:          nil,
+	ErrInvalidInclude:         nil,
+	ErrInvalidSelect:          nil,
+	ErrSelectOnFile:           nil,
+	ErrInvalidBatch:           nil,
+	ErrInvalidBatchOp:         nil,
+	ErrUploadMetaOrder:        nil,
+	ErrUploadMetaMismatch:     nil,
+	ErrInvalidArchiveFormat:   nil,
+	ErrNotArchive:             nil,
+	ErrArchiveUnsafePath:      nil,
+	ErrArchiveTooManyEntries:  nil,
+	ErrArchiveTooLarge:        nil,
+	ErrArchiveCompressionRate: nil,
+	ErrInvalidHash:            nil,
+	ErrInvalidDigest:          nil,
+	ErrInvalidQuota:           nil,
+	ErrInvalidScanStatus:      nil,
+	ErrInvalidRetentionRule:   nil,
+	ErrDigestMismatch:         nil,
+	ErrSearchQueryRequired:    nil,
+	ErrInvalidQuery:           nil,
+	ErrInvalidSearchLang:      nil,
+	ErrInvalidThumbnailSize:   nil,
+}
+
+var notFoundErrList map[error]interface{} = map[error]interface{}{
+	ErrDocNotFound:           nil,
+	ErrDocListNotFound:       nil,
+	ErrUserNotFound:          nil,
+	ErrShareLinkNotFound:     nil,
+	ErrFolderNotFound:        nil,
+	ErrThumbnailNotFound:     nil,
+	ErrSelectNotFound:        nil,
+	ErrBlobNotFound:          nil,
+	ErrRetentionRuleNotFound: nil,
+}
+
+var unauthErrList map[error]interface{} = map[error]interface{}{
+	ErrTokenRequired:      nil,
+	ErrInvalidAuthHeader:  nil,
+	ErrInvalidCredentials: nil,
+	ErrWrongToken:         nil,
+	ErrInvalidToken:       nil,
+	ErrTokenExpired:       nil,
+	ErrUnauthorized:       nil,
+	ErrShareLinkPassword:  nil,
+}
+
+var forbiddenErrList map[error]interface{} = map[error]interface{}{
+	ErrInvalidAdminToken:    nil,
+	ErrAccessDenied:         nil,
+	ErrPermissionEscalation: nil,
+	ErrInvalidSignature:     nil,
+	ErrSignatureExpired:     nil,
+	ErrUnknownSigningKey:    nil,
+	ErrContentInfected:      nil,
+}
+
+var conflictErrList map[error]interface{} = map[error]interface{}{
+	ErrUserAlreadyExist:   nil,
+	ErrDocAlreadyExist:    nil,
+	ErrFolderAlreadyExist: nil,
+	ErrBatchRolledBack:    nil,
+}
+
+var goneErrList map[error]interface{} = map[error]interface{}{
+	ErrShareLinkExpired:   nil,
+	ErrShareLinkExhausted: nil,
+}
+
+var tooManyReqErrList map[error]interface{} = map[error]interface{}{
+	ErrShareLinkLocked: nil,
+}
+
+var notImplementedErrList map[error]interface{} = map[error]interface{}{
+	ErrPresignNotConfigured: nil,
+}
+
+var tooLargeErrList map[error]interface{} = map[error]interface{}{
+	ErrDocExceedsQuota: nil,
+	ErrUploadTooLarge:  nil,
+}
+
+var unsupportedMediaErrList map[error]interface{} = map[error]interface{}{
+	ErrMimeMismatch:   nil,
+	ErrMimeDenied:     nil,
+	ErrMimeNotAllowed: nil,
+}
+
+var insufficientStorageErrList map[error]interface{} = map[error]interface{}{
+	ErrQuotaExceeded:    nil,
+	ErrDocQuotaExceeded: nil,
+}
+
+var lockedErrList map[error]interface{} = map[error]interface{}{
+	ErrScanPending:     nil,
+	ErrScanFailed:      nil,
+	ErrLegalHold:       nil,
+	ErrRetentionActive: nil,
+}
+
+var internalErrList map[error]interface{} = map[error]interface{}{
+	ErrContentCorrupt: nil,
+}
+
+var errorsList map[int]map[error]interface{} = map[int]map[error]interface{}{
+	http.StatusBadRequest:            badReqErrList,
+	http.StatusNotFound:              notFoundErrList,
+	http.StatusUnauthorized:          unauthErrList,
+	http.StatusForbidden:             forbiddenErrList,
+	http.StatusConflict:              conflictErrList,
+	http.StatusGone:                  goneErrList,
+	http.StatusTooManyRequests:       tooManyReqErrList,
+	http.StatusNotImplemented:        notImplementedErrList,
+	http.StatusRequestEntityTooLarge: tooLargeErrList,
+	http.StatusInsufficientStorage:   insufficientStorageErrList,
+	http.StatusUnsupportedMediaType:  unsupportedMediaErrList,
+	http.StatusLocked:                lockedErrList,
+	http.StatusInternalServerError:   internalErrList,
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type AccountHandler struct {
+	account service.Account
+	log     *logrus.Logger
+}
+
+func NewAccountHandler(account service.Account, log *logrus.Logger) *AccountHandler {
+	return &AccountHandler{
+		account: account,
+		log:     log,
+	}
+}
+
+// GetUsage возвращает использование хранилища текущим пользователем и его квоты
+func (h *AccountHandler) GetUsage(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	usage, err := h.account.Usage(c.Request.Context(), userID)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": usage})
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type AdminHandler struct {
+	doc       service.Doc
+	account   service.Account
+	scan      service.Scan
+	retention service.Retention
+	log       *logrus.Logger
+}
+
+func NewAdminHandler(doc service.Doc, account service.Account, scan service.Scan, retention service.Retention, log *logrus.Logger) *AdminHandler {
+	return &AdminHandler{
+		doc:       doc,
+		account:   account,
+		scan:      scan,
+		retention: retention,
+		log:       log,
+	}
+}
+
+// TransferUserDocs передает все документы пользователя :login другому пользователю
+func (h *AdminHandler) TransferUserDocs(c *gin.Context) {
+	fromLogin := c.Param("login")
+
+	var req entity.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	ids, err := h.doc.TransferAll(c.Request.Context(), fromLogin, req.Login)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"from":  fromLogin,
+			"to":    req.Login,
+			"count": len(ids),
+			"docs":  ids,
+		},
+	})
+}
+
+// GetUserQuota возвращает использование хранилища и квоты пользователя :login
+func (h *AdminHandler) GetUserQuota(c *gin.Context) {
+	usage, err := h.account.UserUsage(c.Request.Context(), c.Param("login"))
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": usage})
+}
+
+// SetUserQuota задает квоты пользователя :login, null в поле возвращает квоту по умолчанию
+func (h *AdminHandler) SetUserQuota(c *gin.Context) {
+	var req entity.QuotaOverride
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidQuota)
+		return
+	}
+
+	usage, err := h.account.SetQuota(c.Request.Context(), c.Param("login"), &req)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": usage})
+}
+
+// ListQuarantine возвращает документы, не прошедшие антивирусную проверку.
+// status - список из pending, infected, error через запятую, по умолчанию infected.
+func (h *AdminHandler) ListQuarantine(c *gin.Context) {
+	var limit int
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+
+	docs, err := h.scan.Quarantine(c.Request.Context(), splitList(c.Query("status")), limit)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": docs})
+}
+
+// ListRetentionRules возвращает все правила хранения, включая истекшие
+func (h *AdminHandler) ListRetentionRules(c *gin.Context) {
+	rules, err := h.retention.ListRules(c.Request.Context())
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// CreateRetentionRule добавляет правило хранения
+func (h *AdminHandler) CreateRetentionRule(c *gin.Context) {
+	var req entity.CreateRetentionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRetentionRule)
+		return
+	}
+	if req.Mime != "" && !validMimeFilter(req.Mime) {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidMimeFilter)
+		return
+	}
+
+	rule, err := h.retention.CreateRule(c.Request.Context(), &req)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// DeleteRetentionRule удаляет правило хранения, документы снова можно удалять, если их не держат другие правила
+func (h *AdminHandler) DeleteRetentionRule(c *gin.Context) {
+	ruleID := c.Param("id")
+	if err := h.retention.DeleteRule(c.Request.Context(), ruleID); err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response": gin.H{
+			ruleID: true,
+		},
+	})
+}
+
+// SetLegalHold ставит ({"hold": true}) или снимает legal hold документа :id
+func (h *AdminHandler) SetLegalHold(c *gin.Context) {
+	var req entity.LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	status, err := h.retention.SetLegalHold(c.Request.Context(), c.Param("id"), *req.Hold)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
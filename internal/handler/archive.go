@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+)
+
+// ArchiveDocs отдает выбранные документы одним ZIP или tar.gz архивом. Архив пишется в ответ
+// по мере чтения документов, последней записью идет manifest.json со списком пропущенных.
+func (h *DocHandler) ArchiveDocs(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	var req entity.ArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+	if req.Format == "" {
+		req.Format = entity.ArchiveZip
+	}
+	if req.Format != entity.ArchiveZip && req.Format != entity.ArchiveTarGz {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidArchiveFormat)
+		return
+	}
+
+	query, err := getDocListQuery(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	var archive archiveWriter
+	start := func() {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="documents.%s"`, req.Format))
+		if req.Format == entity.ArchiveZip {
+			c.Header("Content-Type", "application/zip")
+			archive = newZipArchive(c.Writer)
+		} else {
+			c.Header("Content-Type", "application/gzip")
+			archive = newTarGzArchive(c.Writer)
+		}
+		c.Status(http.StatusOK)
+	}
+
+	names := newArchiveNames()
+	names.reserve(entity.ArchiveManifestName)
+
+	result, err := h.doc.Archive(c.Request.Context(), userID, &req, query, func(dir []string, doc *entity.Document) error {
+		if archive == nil {
+			start()
+		}
+		name, size, content := archiveEntry(doc)
+		return archive.Add(names.unique(dir, name), doc.UpdatedAt, size, content)
+	})
+	if err != nil {
+		if archive == nil {
+			response.NewErrorResponse(c, h.log, err)
+			return
+		}
+		// Заголовки уже отправлены, архив остается незавершенным
+		h.log.Errorf("archive aborted: %v", err)
+		c.Abort()
+		return
+	}
+	if archive == nil {
+		start()
+	}
+
+	skipped := make([]gin.H, 0, len(result.Skipped))
+	for _, item := range result.Skipped {
+		skipped = append(skipped, gin.H{
+			"id":    item.ID,
+			"error": gin.H{"code": errors.CheckError(item.Err), "message": item.Err.Error()},
+		})
+	}
+	manifest, _ := json.MarshalIndent(gin.H{
+		"documents": result.Added,
+		"truncated": result.Truncated,
+		"skipped":   skipped,
+	}, "", "  ")
+
+	if err := archive.Add(entity.ArchiveManifestName, time.Now(), int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		h.log.Errorf("archive aborted: %v", err)
+		c.Abort()
+		return
+	}
+	if err := archive.Close(); err != nil {
+		h.log.Errorf("failed to finish archive: %v", err)
+	}
+}
+
+// archiveEntry имя, размер и содержимое записи архива. Файл читается потоком из хранилища,
+// JSON документы сохраняются как .json.
+func archiveEntry(doc *entity.Document) (string, int64, io.Reader) {
+	if doc.IsFile {
+		if doc.Content == nil {
+			return doc.Name, 0, bytes.NewReader(nil)
+		}
+		return doc.Name, doc.Size, doc.Content
+	}
+
+	name := doc.Name
+	if !strings.HasSuffix(strings.ToLower(name), ".json") {
+		name += ".json"
+	}
+	var data []byte
+	switch v := doc.JSONData.(type) {
+	case nil:
+	case json.RawMessage:
+		data = v
+	default:
+		data, _ = json.Marshal(v)
+	}
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	return name, int64(len(data)), bytes.NewReader(data)
+}
+
+// archiveWriter пишет записи архива по одной. size нужен tar-заголовку до содержимого.
+type archiveWriter interface {
+	Add(name string, modTime time.Time, size int64, r io.Reader) error
+	Close() error
+}
+
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func newZipArchive(w io.Writer) *zipArchive {
+	return &zipArchive{zw: zip.NewWriter(w)}
+}
+
+func (a *zipArchive) Add(name string, modTime time.Time, size int64, r io.Reader) error {
+	w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+type tarGzArchive struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzArchive(w io.Writer) *tarGzArchive {
+	gz := gzip.NewWriter(w)
+	return &tarGzArchive{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+func (a *tarGzArchive) Add(name string, modTime time.Time, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// Содержимое короче заголовка - ошибка: запись оборвалась бы молча
+	n, err := io.Copy(a.tw, r)
+	if err == nil && n != size {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (a *tarGzArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// archiveNames выдает уникальные пути записей: одинаковые имена в одной папке
+// получают суффикс " (N)", сравнение без учета регистра
+type archiveNames map[string]struct{}
+
+func newArchiveNames() archiveNames {
+	return make(archiveNames)
+}
+
+func (n archiveNames) reserve(name string) {
+	n[strings.ToLower(name)] = struct{}{}
+}
+
+func (n archiveNames) unique(dir []string, name string) string {
+	segments := make([]string, 0, len(dir)+1)
+	for _, segment := range dir {
+		segments = append(segments, sanitizeArchiveName(segment))
+	}
+	prefix := strings.Join(segments, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	name = sanitizeArchiveName(name)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := prefix + name
+	for i := 1; ; i++ {
+		if _, taken := n[strings.ToLower(candidate)]; !taken {
+			break
+		}
+		candidate = fmt.Sprintf("%s%s (%d)%s", prefix, base, i, ext)
+	}
+	n.reserve(candidate)
+	return candidate
+}
+
+// sanitizeArchiveName убирает из имени разделители путей и управляющие символы,
+// чтобы запись не выходила за пределы своей папки при распаковке
+func sanitizeArchiveName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
@@ -1,129 +1,302 @@
-package handler
-
-import (
-	"fmt"
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-	"github.com/paudarco/doc-storage/internal/config"
-	"github.com/paudarco/doc-storage/internal/entity"
-	"github.com/paudarco/doc-storage/internal/errors"
-	"github.com/paudarco/doc-storage/internal/handler/response"
-	"github.com/paudarco/doc-storage/internal/service"
-	"github.com/sirupsen/logrus"
-)
-
-type AuthHandler struct {
-	userService service.User
-	user        service.User
-	cfg         *config.Config
-	log         *logrus.Logger
-}
-
-func NewAuthHandler(userService service.User, user service.User, cfg *config.Config, log *logrus.Logger) *AuthHandler {
-	return &AuthHandler{
-		userService: userService,
-		user:        user,
-		cfg:         cfg,
-		log:         log,
-	}
-}
-
-func (h *AuthHandler) Register(c *gin.Context) {
-	var req entity.RegisterRequest
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
-		return
-	}
-
-	if req.AdminToken != h.cfg.AdminToken {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidAdminToken)
-		return
-	}
-
-	if req.Login == "" || req.Pswd == "" {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
-		return
-	}
-
-	err := h.userService.Register(c.Request.Context(), req.Login, req.Pswd)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"response": gin.H{
-			"login": req.Login,
-		},
-	})
-}
-
-func (h *AuthHandler) Authenticate(c *gin.Context) {
-	var req entity.LoginRequest
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
-		return
-	}
-
-	if req.Login == "" || req.Pswd == "" {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
-		return
-	}
-
-	token, err := h.userService.Authenticate(c.Request.Context(), req.Login, req.Pswd)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidCredentials)
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"response": gin.H{
-			"token": token,
-		},
-	})
-}
-
-func (h *AuthHandler) Logout(c *gin.Context) {
-	token := c.Param("token")
-
-	err := h.user.InvalidateToken(c.Request.Context(), token)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, fmt.Errorf("failed to invalidate token: %w", err))
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"response": gin.H{
-			token: true,
-		},
-	})
-}
-
-func getUserID(c *gin.Context) (string, error) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		return "", fmt.Errorf("user ID not found in context")
-	}
-	idStr, ok := userID.(string)
-	if !ok {
-		return "", fmt.Errorf("user ID is not a string")
-	}
-	return idStr, nil
-}
-
-func getQueryParams(c *gin.Context) (login, key, value string, limit int) {
-	login = c.Query("login")
-	key = c.Query("key")
-	value = c.Query("value")
-	if l := c.Query("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
-	}
-	// Установим разумный лимит по умолчанию
-	if limit <= 0 || limit > 1000 {
-		limit = 100
-	}
-	return
-}
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type AuthHandler struct {
+	userService service.User
+	user        service.User
+	cfg         *config.Config
+	log         *logrus.Logger
+}
+
+func NewAuthHandler(userService service.User, user service.User, cfg *config.Config, log *logrus.Logger) *AuthHandler {
+	return &AuthHandler{
+		userService: userService,
+		user:        user,
+		cfg:         cfg,
+		log:         log,
+	}
+}
+
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req entity.RegisterRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	if req.AdminToken != h.cfg.AdminToken {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidAdminToken)
+		return
+	}
+
+	if req.Login == "" || req.Pswd == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	err := h.userService.Register(c.Request.Context(), req.Login, req.Pswd)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response": gin.H{
+			"login": req.Login,
+		},
+	})
+}
+
+func (h *AuthHandler) Authenticate(c *gin.Context) {
+	var req entity.LoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	if req.Login == "" || req.Pswd == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	token, err := h.userService.Authenticate(c.Request.Context(), req.Login, req.Pswd)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidCredentials)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response": gin.H{
+			"token": token,
+		},
+	})
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	token := c.Param("token")
+
+	err := h.user.InvalidateToken(c.Request.Context(), token)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, fmt.Errorf("failed to invalidate token: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response": gin.H{
+			token: true,
+		},
+	})
+}
+
+func getUserID(c *gin.Context) (string, error) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		return "", fmt.Errorf("user ID not found in context")
+	}
+	idStr, ok := userID.(string)
+	if !ok {
+		return "", fmt.Errorf("user ID is not a string")
+	}
+	return idStr, nil
+}
+
+func getQueryParams(c *gin.Context) (login, key, value string, limit int) {
+	login = c.Query("login")
+	key = c.Query("key")
+	value = c.Query("value")
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	// Установим разумный лимит по умолчанию
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	return
+}
+
+// getDocListQuery собирает фильтры, сортировку и страницу списка из параметров запроса
+func getDocListQuery(c *gin.Context) (*entity.DocListQuery, error) {
+	login, key, value, limit := getQueryParams(c)
+
+	sort, err := getDocSort(c)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &entity.DocListQuery{
+		Login:     login,
+		GrantedTo: c.Query("granted_to"),
+		Tags:      getTagFilter(c),
+		Attrs:     c.QueryMap("attr"),
+		Sort:      sort,
+		Page:      getPageRequest(c, limit),
+	}
+	if key == "name" {
+		q.Name = value
+	}
+
+	for _, include := range splitList(c.Query("include")) {
+		if include != "json" {
+			return nil, errors.ErrInvalidInclude
+		}
+		q.IncludeJSON = true
+	}
+
+	if mime := c.Query("mime"); mime != "" && mime != "*" && mime != "*/*" {
+		if !validMimeFilter(mime) {
+			return nil, errors.ErrInvalidMimeFilter
+		}
+		q.Mime = mime
+	}
+
+	if q.CreatedAfter, err = queryTime(c, "created_after"); err != nil {
+		return nil, err
+	}
+	if q.CreatedBefore, err = queryTime(c, "created_before"); err != nil {
+		return nil, err
+	}
+	if q.MinSize, err = querySize(c, "min_size"); err != nil {
+		return nil, err
+	}
+	if q.MaxSize, err = querySize(c, "max_size"); err != nil {
+		return nil, err
+	}
+	if q.Public, err = queryBool(c, "public"); err != nil {
+		return nil, err
+	}
+	if q.File, err = queryBool(c, "file"); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// docMetaFields поля метаданных, которые можно запросить через fields=
+var docMetaFields = map[string]struct{}{
+	"id": {}, "name": {}, "file": {}, "public": {}, "created": {}, "updated": {}, "size": {},
+	"permission": {}, "mime": {}, "grant": {}, "folder_id": {}, "tags": {}, "attributes": {},
+	"text_status": {}, "text_error": {}, "thumbnail_status": {}, "scan_status": {}, "expires_at": {}, "sha256": {},
+	"legal_hold": {}, "json": {},
+}
+
+// getFields разбирает fields=name,size,... ; nil означает все поля
+func getFields(c *gin.Context) (map[string]bool, error) {
+	list := splitList(c.Query("fields"))
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	fields := make(map[string]bool, len(list))
+	for _, field := range list {
+		if _, ok := docMetaFields[field]; !ok {
+			return nil, errors.ErrInvalidFields
+		}
+		fields[field] = true
+	}
+	return fields, nil
+}
+
+// splitList разбирает список через запятую, пропуская пустые элементы
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validMimeFilter допускает "type/subtype" и "type/*"
+func validMimeFilter(mime string) bool {
+	mainType, subType, ok := strings.Cut(mime, "/")
+	if !ok || mainType == "" || subType == "" || strings.Contains(subType, "/") {
+		return false
+	}
+	return !strings.Contains(mainType, "*") && (subType == "*" || !strings.Contains(subType, "*"))
+}
+
+var queryTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range queryTimeLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.ErrInvalidDateFilter
+}
+
+func querySize(c *gin.Context, name string) (*int64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	size, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || size < 0 {
+		return nil, errors.ErrInvalidSizeFilter
+	}
+	return &size, nil
+}
+
+func queryBool(c *gin.Context, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, errors.ErrInvalidBoolFilter
+	}
+	return &value, nil
+}
+
+// getPageRequest разбирает cursor=<next|prev из ответа> и count=true
+func getPageRequest(c *gin.Context, limit int) entity.PageRequest {
+	return entity.PageRequest{
+		Cursor: c.Query("cursor"),
+		Limit:  limit,
+		Count:  c.Query("count") == "true",
+	}
+}
+
+// getTagFilter разбирает tag=a&tag=b&tag_mode=all|any, по умолчанию требуются все теги
+func getTagFilter(c *gin.Context) entity.TagFilter {
+	return entity.TagFilter{
+		Tags:     c.QueryArray("tag"),
+		MatchAll: c.Query("tag_mode") != "any",
+	}
+}
+
+// getDocSort разбирает sort=name|created|size|updated|attr.<key> и order=asc|desc
+func getDocSort(c *gin.Context) (entity.DocSort, error) {
+	sort := entity.DocSort{
+		Field: c.DefaultQuery("sort", entity.SortName),
+		Desc:  strings.EqualFold(c.Query("order"), "desc"),
+	}
+	if !sort.Valid() {
+		return sort, errors.ErrInvalidSort
+	}
+	return sort, nil
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+// applyContentDigest переносит sha-256 из заголовка Content-Digest (RFC 9530) в meta.sha256.
+// Если meta.sha256 уже задан, значения должны совпадать. Другие алгоритмы игнорируются.
+func applyContentDigest(meta map[string]interface{}, header string) error {
+	if header == "" {
+		return nil
+	}
+
+	var digest string
+	for _, item := range strings.Split(header, ",") {
+		alg, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(alg), "sha-256") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return errors.ErrInvalidDigest
+		}
+		sum, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil || len(sum) != 32 {
+			return errors.ErrInvalidDigest
+		}
+		digest = hex.EncodeToString(sum)
+	}
+	if digest == "" {
+		return nil
+	}
+
+	if existing, ok := meta["sha256"].(string); ok && existing != "" {
+		if !strings.EqualFold(existing, digest) {
+			return errors.ErrDigestMismatch
+		}
+		return nil
+	}
+	meta["sha256"] = digest
+	return nil
+}
+
+// digestHeader значение для Repr-Digest/Content-Digest по hex SHA-256
+func digestHeader(hash string) string {
+	sum, err := hex.DecodeString(hash)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
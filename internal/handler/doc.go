@@ -1,224 +1,952 @@
-package handler
-
-import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
-	"strings"
-
-	"github.com/paudarco/doc-storage/internal/errors"
-	"github.com/paudarco/doc-storage/internal/handler/response"
-	"github.com/paudarco/doc-storage/internal/service"
-	"github.com/sirupsen/logrus"
-
-	"github.com/gin-gonic/gin"
-)
-
-type DocHandler struct {
-	doc service.Doc
-	log *logrus.Logger
-}
-
-func NewDocHandler(doc service.Doc, log *logrus.Logger) *DocHandler {
-	return &DocHandler{
-		doc: doc,
-		log: log,
-	}
-}
-
-func (h *DocHandler) UploadDoc(c *gin.Context) {
-	userID, err := getUserID(c)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
-		return
-	}
-
-	form, err := c.MultipartForm()
-	if err != nil {
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	var meta map[string]interface{}
-	if metaValues := form.Value["meta"]; len(metaValues) > 0 {
-		if err := json.Unmarshal([]byte(metaValues[0]), &meta); err != nil {
-			response.NewErrorResponse(c, h.log, err)
-			return
-		}
-	} else {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
-		return
-	}
-
-	var jsonData json.RawMessage
-	if jsonValues := form.Value["json"]; len(jsonValues) > 0 {
-		jsonData = json.RawMessage(jsonValues[0])
-	}
-
-	var fileData []byte
-	var fileHeader *multipart.FileHeader
-	if fileHeaders := form.File["file"]; len(fileHeaders) > 0 {
-		fileHeader = fileHeaders[0]
-		file, err := fileHeader.Open()
-		if err != nil {
-			response.NewErrorResponse(c, h.log, err)
-			return
-		}
-		defer file.Close()
-
-		fileData, err = io.ReadAll(file)
-		if err != nil {
-			response.NewErrorResponse(c, h.log, err)
-			return
-		}
-	}
-
-	doc, err := h.doc.Create(c.Request.Context(), userID, meta, jsonData, fileData)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	respData := gin.H{}
-	if doc.IsFile {
-		respData["file"] = doc.Name
-	}
-	if len(jsonData) > 0 {
-		var jsonResp interface{}
-		_ = json.Unmarshal(jsonData, &jsonResp)
-		respData["json"] = jsonResp
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data": respData,
-	})
-}
-
-func (h *DocHandler) ListDocs(c *gin.Context) {
-	userID, err := getUserID(c)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	loginFilter, keyFilter, valueFilter, limit := getQueryParams(c)
-
-	// Получаем список документов
-	docs, err := h.doc.List(c.Request.Context(), userID, loginFilter, keyFilter, valueFilter, limit)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	// Преобразуем в формат ответа
-	docList := make([]gin.H, len(docs))
-	for i, doc := range docs {
-		docList[i] = gin.H{
-			"id":      doc.ID,
-			"name":    doc.Name,
-			"file":    doc.IsFile,
-			"public":  doc.Public,
-			"created": doc.CreatedAt.Format("2006-01-02 15:04:05"),
-		}
-		if doc.Mime != "" {
-			docList[i]["mime"] = doc.Mime
-		}
-		if len(doc.Grant) > 0 {
-			docList[i]["grant"] = doc.Grant
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"docs": docList,
-		},
-	})
-}
-
-func (h *DocHandler) GetDoc(c *gin.Context) {
-	userID, err := getUserID(c)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	docID := c.Param("id")
-	if docID == "" {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
-		return
-	}
-
-	doc, err := h.doc.GetByID(c.Request.Context(), userID, docID)
-	if err != nil {
-		if strings.Contains(err.Error(), "access denied") {
-			response.NewErrorResponse(c, h.log, err)
-			return
-		}
-		if strings.Contains(err.Error(), "not found") {
-			response.NewErrorResponse(c, h.log, err)
-			return
-		}
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	if c.Request.Method == "HEAD" {
-		if doc.IsFile {
-			c.Header("Content-Type", doc.Mime)
-			c.Header("Content-Length", fmt.Sprintf("%d", len(doc.FileData)))
-		}
-		c.Status(http.StatusOK)
-		return
-	}
-
-	// Обработка GET запроса
-	if doc.IsFile {
-		// Отдаем файл
-		c.Data(http.StatusOK, doc.Mime, doc.FileData)
-	} else {
-		var jsonData interface{}
-		if doc.JSONData != nil {
-			_ = json.Unmarshal(doc.JSONData.([]byte), &jsonData)
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"data": jsonData,
-		})
-	}
-}
-
-func (h *DocHandler) DeleteDoc(c *gin.Context) {
-	userID, err := getUserID(c)
-	if err != nil {
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	docID := c.Param("id")
-	if docID == "" {
-		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
-		return
-	}
-
-	// Удаляем документ
-	err = h.doc.Delete(c.Request.Context(), userID, docID)
-	if err != nil {
-		if strings.Contains(err.Error(), "access denied") {
-			response.NewErrorResponse(c, h.log, err)
-			return
-		}
-		if strings.Contains(err.Error(), "not found") {
-			response.NewErrorResponse(c, h.log, err)
-			return
-		}
-		response.NewErrorResponse(c, h.log, err)
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"response": gin.H{
-			docID: true,
-		},
-	})
-}
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/paudarco/doc-storage/pkg/jsonpath"
+	"github.com/sirupsen/logrus"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DocHandler struct {
+	doc service.Doc
+	log *logrus.Logger
+}
+
+func NewDocHandler(doc service.Doc, log *logrus.Logger) *DocHandler {
+	return &DocHandler{
+		doc: doc,
+		log: log,
+	}
+}
+
+// maxMetaPartSize ограничивает размер частей meta и json, читаемых в память целиком
+const maxMetaPartSize = 8 << 20
+
+// uploadResult результат создания документа из одной части multipart запроса
+type uploadResult struct {
+	name      string
+	doc       *entity.Document
+	extracted *entity.ExtractResult // файл был архивом с meta.extract=true
+	err       error
+}
+
+// UploadDoc читает multipart запрос по частям, не сохраняя его целиком. Части meta и json
+// должны идти до частей file. meta - общий объект для всех файлов или массив объектов,
+// по одному на каждый файл в порядке следования. Часть json, как и общий meta, относится
+// ко всем документам запроса: каждый файл сохраняется вместе с ней. Каждый файл становится
+// отдельным документом и пишется в хранилище потоком, ошибка одного файла не отменяет
+// остальные. Файл с meta.extract=true распаковывается как архив в отдельные документы.
+func (h *DocHandler) UploadDoc(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	var (
+		sharedMeta map[string]interface{}
+		fileMetas  []map[string]interface{}
+		metaSeen   bool
+		jsonData   json.RawMessage
+		results    []uploadResult
+		// uploadLimit запрашивается при первом архиве, -1 - еще не запрошен
+		uploadLimit int64 = -1
+	)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(results) == 0 {
+				response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+				return
+			}
+			results = append(results, uploadResult{err: errors.ErrInvalidRequestBody})
+			break
+		}
+
+		switch part.FormName() {
+		case "meta":
+			raw, err := readPart(part)
+			if err != nil {
+				response.NewErrorResponse(c, h.log, err)
+				return
+			}
+			sharedMeta, fileMetas, err = parseUploadMeta(raw)
+			if err != nil {
+				response.NewErrorResponse(c, h.log, err)
+				return
+			}
+			metaSeen = true
+
+		case "json":
+			raw, err := readPart(part)
+			if err != nil {
+				response.NewErrorResponse(c, h.log, err)
+				return
+			}
+			jsonData = json.RawMessage(raw)
+
+		case "file":
+			result := uploadResult{name: part.FileName()}
+			meta, err := uploadFileMeta(part, metaSeen, sharedMeta, fileMetas, len(results))
+			if err == nil {
+				err = applyContentDigest(meta, part.Header.Get("Content-Digest"))
+			}
+			if extract, _ := meta["extract"].(bool); err == nil && extract {
+				// Архив распаковывается в памяти, поэтому читается целиком, но не дальше лимита
+				if uploadLimit < 0 {
+					uploadLimit, err = h.doc.UploadLimit(c.Request.Context(), userID)
+				}
+				var fileData []byte
+				if err == nil {
+					fileData, err = readUpload(part, uploadLimit)
+				}
+				if err == nil {
+					result.extracted, err = h.doc.CreateFromArchive(c.Request.Context(), userID, meta, fileData)
+				}
+			} else if err == nil {
+				result.doc, err = h.doc.Upload(c.Request.Context(), userID, meta, jsonData, part)
+			}
+			result.err = err
+			results = append(results, result)
+		}
+		part.Close()
+	}
+
+	if !metaSeen {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	// Без файлов создается один JSON документ
+	if len(results) == 0 {
+		if sharedMeta == nil {
+			response.NewErrorResponse(c, h.log, errors.ErrUploadMetaMismatch)
+			return
+		}
+		doc, err := h.doc.Create(c.Request.Context(), userID, sharedMeta, jsonData, nil)
+		if err != nil {
+			response.NewErrorResponse(c, h.log, err)
+			return
+		}
+		results = append(results, uploadResult{doc: doc})
+	}
+
+	// Один документ с общим meta - прежний формат ответа
+	if sharedMeta != nil && len(results) == 1 {
+		if results[0].err != nil {
+			response.NewErrorResponse(c, h.log, results[0].err)
+			return
+		}
+		if results[0].extracted != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"data": results[0].extracted,
+			})
+			return
+		}
+		doc := results[0].doc
+
+		respData := gin.H{}
+		if doc.IsFile {
+			respData["file"] = doc.Name
+		}
+		if len(jsonData) > 0 {
+			var jsonResp interface{}
+			_ = json.Unmarshal(jsonData, &jsonResp)
+			respData["json"] = jsonResp
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": respData,
+		})
+		return
+	}
+
+	created := 0
+	items := make([]gin.H, 0, len(results))
+	for i, res := range results {
+		item := gin.H{
+			"index": i,
+			"ok":    res.err == nil,
+		}
+		if res.extracted != nil {
+			created++
+			item["name"] = res.name
+			item["extracted"] = res.extracted
+		} else if res.doc != nil {
+			created++
+			item["id"] = res.doc.ID
+			item["name"] = res.doc.Name
+			item["size"] = res.doc.Size
+		} else if res.name != "" {
+			item["name"] = res.name
+		}
+		if res.err != nil {
+			code := errors.CheckError(res.err)
+			if code == http.StatusInternalServerError {
+				h.log.Errorf("upload of file %d (%s) failed: %v", i, res.name, res.err)
+			}
+			item["error"] = gin.H{"code": code, "message": res.err.Error()}
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"created": created,
+			"failed":  len(results) - created,
+			"results": items,
+		},
+	})
+}
+
+// readPart читает часть multipart запроса целиком, не больше maxMetaPartSize
+func readPart(part *multipart.Part) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(part, maxMetaPartSize+1))
+	if err != nil {
+		return nil, errors.ErrInvalidRequestBody
+	}
+	if len(raw) > maxMetaPartSize {
+		return nil, errors.ErrInvalidRequestBody
+	}
+	return raw, nil
+}
+
+// readUpload читает содержимое файла в память, прерывая чтение, как только оно превысит limit
+// (0 - без ограничения). Нужен только архивам, остальные файлы загружаются потоком.
+func readUpload(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errors.ErrUploadTooLarge
+	}
+	return data, nil
+}
+
+// parseUploadMeta разбирает meta: объект возвращается как общий, массив - как meta по файлам
+func parseUploadMeta(raw []byte) (map[string]interface{}, []map[string]interface{}, error) {
+	var shared map[string]interface{}
+	if err := json.Unmarshal(raw, &shared); err == nil && shared != nil {
+		return shared, nil, nil
+	}
+
+	var perFile []map[string]interface{}
+	if err := json.Unmarshal(raw, &perFile); err != nil || len(perFile) == 0 {
+		return nil, nil, errors.ErrInvalidRequestBody
+	}
+	for _, meta := range perFile {
+		if meta == nil {
+			return nil, nil, errors.ErrInvalidRequestBody
+		}
+	}
+	return nil, perFile, nil
+}
+
+// uploadFileMeta собирает meta для index-го файла. Отсутствующие name и mime берутся
+// из имени файла и Content-Type части, file по умолчанию true.
+func uploadFileMeta(part *multipart.Part, metaSeen bool, shared map[string]interface{}, perFile []map[string]interface{}, index int) (map[string]interface{}, error) {
+	if !metaSeen {
+		return nil, errors.ErrUploadMetaOrder
+	}
+
+	source := shared
+	if shared == nil {
+		if index >= len(perFile) {
+			return nil, errors.ErrUploadMetaMismatch
+		}
+		source = perFile[index]
+	}
+
+	meta := make(map[string]interface{}, len(source)+3)
+	for key, value := range source {
+		meta[key] = value
+	}
+	if _, ok := meta["name"]; !ok && part.FileName() != "" {
+		meta["name"] = part.FileName()
+	}
+	if _, ok := meta["file"]; !ok {
+		meta["file"] = true
+	}
+	if _, ok := meta["mime"]; !ok {
+		if contentType := part.Header.Get("Content-Type"); contentType != "" {
+			meta["mime"] = contentType
+		}
+	}
+	return meta, nil
+}
+
+// PutDoc принимает тело запроса как содержимое файла, обычно по подписанной PUT ссылке.
+// Имя берется из параметра name, тип из заголовка Content-Type.
+func (h *DocHandler) PutDoc(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrMetaNameRequired)
+		return
+	}
+
+	limit, err := h.doc.UploadLimit(c.Request.Context(), userID)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+	if limit > 0 && c.Request.ContentLength > limit {
+		response.NewErrorResponse(c, h.log, errors.ErrUploadTooLarge)
+		return
+	}
+
+	meta := map[string]interface{}{
+		"name":   name,
+		"file":   true,
+		"public": c.Query("public") == "true",
+	}
+	if contentType := c.ContentType(); contentType != "" {
+		meta["mime"] = contentType
+	}
+	if err := applyContentDigest(meta, c.GetHeader("Content-Digest")); err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	doc, err := h.doc.UploadWithID(c.Request.Context(), c.Param("id"), userID, meta, nil, c.Request.Body)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": docMeta(doc),
+	})
+}
+
+func (h *DocHandler) ListDocs(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	// Получаем список документов
+	query, err := getDocListQuery(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	fields, err := getFields(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	result, err := h.doc.List(c.Request.Context(), userID, query)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	// Преобразуем в формат ответа
+	docList := make([]gin.H, len(result.Docs))
+	for i, doc := range result.Docs {
+		meta := docMeta(doc)
+		if query.IncludeJSON && !doc.IsFile {
+			if doc.JSONData != nil {
+				meta["json"] = doc.JSONData
+			} else {
+				// JSON больше LIST_JSON_MAX_SIZE, его нужно запрашивать через GET /api/docs/:id
+				meta["json_omitted"] = true
+			}
+		}
+		docList[i] = projectFields(meta, fields)
+	}
+
+	data := gin.H{
+		"docs": docList,
+	}
+	if result.Next != "" {
+		data["next"] = result.Next
+	}
+	if result.Prev != "" {
+		data["prev"] = result.Prev
+	}
+	if result.Total != nil {
+		data["total"] = *result.Total
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+func (h *DocHandler) GetDoc(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	doc, err := h.doc.GetByID(c.Request.Context(), userID, docID)
+	if err != nil {
+		if strings.Contains(err.Error(), "access denied") {
+			response.NewErrorResponse(c, h.log, err)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			response.NewErrorResponse(c, h.log, err)
+			return
+		}
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+	defer doc.CloseContent()
+
+	if path := c.Query("select"); path != "" {
+		if err := selectJSON(doc, path); err != nil {
+			response.NewErrorResponse(c, h.log, err)
+			return
+		}
+	}
+
+	serveDoc(c, doc)
+}
+
+// selectJSON заменяет содержимое JSON-документа поддеревом по пути path
+func selectJSON(doc *entity.Document, path string) error {
+	if doc.IsFile {
+		return errors.ErrSelectOnFile
+	}
+
+	var data interface{}
+	switch raw := doc.JSONData.(type) {
+	case json.RawMessage:
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+	case []byte:
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+	default:
+		data = raw
+	}
+
+	selected, err := jsonpath.Select(data, path)
+	switch err {
+	case nil:
+		doc.JSONData = selected
+		return nil
+	case jsonpath.ErrNotFound:
+		return errors.ErrSelectNotFound
+	default:
+		return errors.ErrInvalidSelect
+	}
+}
+
+// serveDoc отдает содержимое документа: файл как есть, JSON в обертке data.
+// Поток содержимого закрывает вызывающий.
+func serveDoc(c *gin.Context, doc *entity.Document) {
+	c.Header("X-Doc-Permission", string(doc.Permission))
+	if len(doc.Attributes) > 0 {
+		if attrs, err := json.Marshal(doc.Attributes); err == nil {
+			c.Header("X-Doc-Attributes", string(attrs))
+		}
+	}
+
+	if doc.IsFile && doc.ContentHash != "" {
+		c.Header("Repr-Digest", digestHeader(doc.ContentHash))
+		if c.Request.Method != "HEAD" {
+			c.Header("Content-Digest", digestHeader(doc.ContentHash))
+		}
+	}
+
+	if c.Request.Method == "HEAD" {
+		if doc.IsFile {
+			c.Header("Content-Type", doc.Mime)
+			c.Header("Content-Length", fmt.Sprintf("%d", doc.Size))
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	// Обработка GET запроса
+	if doc.IsFile {
+		// Файл отдается потоком, при несовпадении хэша ответ обрывается
+		if doc.Content == nil {
+			c.Data(http.StatusOK, doc.Mime, nil)
+			return
+		}
+		c.DataFromReader(http.StatusOK, doc.Size, doc.Mime, doc.Content, nil)
+	} else {
+		var jsonData interface{}
+		switch data := doc.JSONData.(type) {
+		case json.RawMessage:
+			_ = json.Unmarshal(data, &jsonData)
+		case []byte:
+			_ = json.Unmarshal(data, &jsonData)
+		default:
+			// После кэша JSON уже разобран
+			jsonData = data
+		}
+		resp := gin.H{
+			"data":       jsonData,
+			"permission": doc.Permission,
+		}
+		if len(doc.Attributes) > 0 {
+			resp["attributes"] = doc.Attributes
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func (h *DocHandler) UpdateDoc(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	var req entity.DocUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	doc, err := h.doc.Update(c.Request.Context(), userID, docID, &req)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": docMeta(doc),
+	})
+}
+
+func (h *DocHandler) SetDocTags(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	var req entity.TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	doc, err := h.doc.SetTags(c.Request.Context(), userID, docID, req.Tags)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": docMeta(doc),
+	})
+}
+
+// ListTags возвращает теги документов с количеством, учитывая фильтры login и tag
+func (h *DocHandler) ListTags(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	facets, err := h.doc.TagFacets(c.Request.Context(), userID, c.Query("login"), getTagFilter(c))
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"tags": facets,
+		},
+	})
+}
+
+// GetDocText возвращает извлеченный из файла текст. Пока извлечение не завершено, отвечает 202.
+func (h *DocHandler) GetDocText(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	text, err := h.doc.GetText(c.Request.Context(), userID, docID)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	data := gin.H{
+		"id":     text.DocumentID,
+		"status": text.Status,
+	}
+	if text.Error != "" {
+		data["error"] = text.Error
+	}
+	if text.Status == entity.TextStatusDone {
+		data["text"] = text.Text
+	}
+	if text.ExtractedAt != nil {
+		data["extracted"] = text.ExtractedAt.Format("2006-01-02 15:04:05")
+	}
+
+	status := http.StatusOK
+	if text.Status == entity.TextStatusPending {
+		status = http.StatusAccepted
+	}
+
+	c.JSON(status, gin.H{
+		"data": data,
+	})
+}
+
+// SearchDocs полнотекстовый поиск по именам, атрибутам, JSON и тексту файлов
+func (h *DocHandler) SearchDocs(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	fields, err := getFields(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	q := entity.SearchQuery{
+		Query: c.Query("q"),
+		Lang:  c.Query("lang"),
+	}
+	if l := c.Query("limit"); l != "" {
+		if q.Limit, err = strconv.Atoi(l); err != nil {
+			response.NewErrorResponse(c, h.log, errors.ErrInvalidQuery)
+			return
+		}
+	}
+
+	results, err := h.doc.Search(c.Request.Context(), userID, q)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	items := make([]gin.H, 0, len(results))
+	for _, res := range results {
+		item := projectFields(docMeta(res.Doc), fields)
+		item["rank"] = res.Rank
+		item["snippet"] = res.Snippet
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"results": items,
+		},
+	})
+}
+
+func (h *DocHandler) ShareDoc(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	var req entity.ShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	doc, err := h.doc.Share(c.Request.Context(), userID, docID, req.Grant)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": docMeta(doc),
+	})
+}
+
+// BatchDocs применяет список операций к документам в одной транзакции и возвращает
+// результат по каждой операции
+func (h *DocHandler) BatchDocs(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	var req entity.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	results, committed, err := h.doc.Batch(c.Request.Context(), userID, &req)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	items := make([]gin.H, 0, len(results))
+	for _, res := range results {
+		item := gin.H{
+			"index": res.Index,
+			"id":    res.ID,
+			"op":    res.Op,
+			"ok":    res.Err == nil,
+		}
+		if res.Err != nil {
+			code := errors.CheckError(res.Err)
+			if code == http.StatusInternalServerError {
+				h.log.Errorf("batch operation %s on %s failed: %v", res.Op, res.ID, res.Err)
+			}
+			item["error"] = gin.H{"code": code, "message": res.Err.Error()}
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"committed": committed,
+			"results":   items,
+		},
+	})
+}
+
+// CheckHashes сообщает, какие из переданных SHA-256 уже есть в документах пользователя.
+// Такие файлы можно не загружать повторно: достаточно передать meta.sha256 без части file.
+func (h *DocHandler) CheckHashes(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	var req entity.HashCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	found, err := h.doc.CheckHashes(c.Request.Context(), userID, req.Hashes)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	have := make(map[string]bool, len(found))
+	for _, hash := range found {
+		have[hash] = true
+	}
+	missing := []string{}
+	for _, hash := range req.Hashes {
+		if !have[strings.ToLower(hash)] {
+			missing = append(missing, hash)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"found":   found,
+			"missing": missing,
+		},
+	})
+}
+
+func (h *DocHandler) TransferDoc(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	var req entity.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	doc, err := h.doc.Transfer(c.Request.Context(), userID, docID, req.Login)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": docMeta(doc),
+	})
+}
+
+func (h *DocHandler) DeleteDoc(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	// Удаляем документ
+	err = h.doc.Delete(c.Request.Context(), userID, docID)
+	if err != nil {
+		if strings.Contains(err.Error(), "access denied") {
+			response.NewErrorResponse(c, h.log, err)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			response.NewErrorResponse(c, h.log, err)
+			return
+		}
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response": gin.H{
+			docID: true,
+		},
+	})
+}
+
+// projectFields оставляет в метаданных только запрошенные поля, id возвращается всегда
+func projectFields(meta gin.H, fields map[string]bool) gin.H {
+	if fields == nil {
+		return meta
+	}
+	for key := range meta {
+		keep := fields[key] || key == "id" || (key == "json_omitted" && fields["json"])
+		if !keep {
+			delete(meta, key)
+		}
+	}
+	return meta
+}
+
+func docMeta(doc *entity.Document) gin.H {
+	meta := gin.H{
+		"id":         doc.ID,
+		"name":       doc.Name,
+		"file":       doc.IsFile,
+		"public":     doc.Public,
+		"created":    doc.CreatedAt.Format("2006-01-02 15:04:05"),
+		"updated":    doc.UpdatedAt.Format("2006-01-02 15:04:05"),
+		"size":       doc.Size,
+		"permission": doc.Permission,
+	}
+	if doc.Mime != "" {
+		meta["mime"] = doc.Mime
+	}
+	if len(doc.Grant) > 0 {
+		meta["grant"] = doc.Grant
+	}
+	if doc.FolderID != nil {
+		meta["folder_id"] = *doc.FolderID
+	}
+	if len(doc.Tags) > 0 {
+		meta["tags"] = doc.Tags
+	}
+	if len(doc.Attributes) > 0 {
+		meta["attributes"] = doc.Attributes
+	}
+	if doc.ContentHash != "" {
+		meta["sha256"] = doc.ContentHash
+	}
+	if doc.ThumbStatus != "" && doc.ThumbStatus != entity.ThumbnailStatusNone {
+		meta["thumbnail_status"] = doc.ThumbStatus
+	}
+	if doc.ScanStatus != "" && doc.ScanStatus != entity.ScanStatusNone {
+		meta["scan_status"] = doc.ScanStatus
+	}
+	if doc.ExpiresAt != nil {
+		meta["expires_at"] = doc.ExpiresAt
+	}
+	if doc.LegalHold {
+		meta["legal_hold"] = true
+	}
+	if doc.IsFile && doc.TextStatus != "" {
+		meta["text_status"] = doc.TextStatus
+		if doc.TextError != "" {
+			meta["text_error"] = doc.TextError
+		}
+	}
+	return meta
+}
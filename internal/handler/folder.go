@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type FolderHandler struct {
+	folder service.Folder
+	log    *logrus.Logger
+}
+
+func NewFolderHandler(folder service.Folder, log *logrus.Logger) *FolderHandler {
+	return &FolderHandler{
+		folder: folder,
+		log:    log,
+	}
+}
+
+func (h *FolderHandler) CreateFolder(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	var req entity.CreateFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	folder, err := h.folder.Create(c.Request.Context(), userID, &req)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": folderMeta(folder),
+	})
+}
+
+func (h *FolderHandler) ListRootFolder(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	contents, err := h.folder.Root(c.Request.Context(), userID)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": folderContents(contents),
+	})
+}
+
+func (h *FolderHandler) GetFolder(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	contents, err := h.folder.Get(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": folderContents(contents),
+	})
+}
+
+func (h *FolderHandler) UpdateFolder(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	var req entity.FolderUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	folder, err := h.folder.Update(c.Request.Context(), userID, c.Param("id"), &req)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": folderMeta(folder),
+	})
+}
+
+func (h *FolderHandler) ShareFolder(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	var req entity.ShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	folder, err := h.folder.Share(c.Request.Context(), userID, c.Param("id"), req.Grant)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": folderMeta(folder),
+	})
+}
+
+func (h *FolderHandler) DeleteFolder(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	folderID := c.Param("id")
+	if err := h.folder.Delete(c.Request.Context(), userID, folderID); err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response": gin.H{
+			folderID: true,
+		},
+	})
+}
+
+// GetFile ищет документ или папку по пути: документ отдается как в GetDoc, папка как список содержимого
+func (h *FolderHandler) GetFile(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	folder, doc, err := h.folder.Resolve(c.Request.Context(), userID, c.Query("login"), c.Param("path"))
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	if doc != nil {
+		defer doc.CloseContent()
+		serveDoc(c, doc)
+		return
+	}
+
+	var contents *entity.FolderContents
+	if folder == nil {
+		contents, err = h.folder.Root(c.Request.Context(), userID)
+	} else {
+		contents, err = h.folder.Get(c.Request.Context(), userID, folder.ID)
+	}
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": folderContents(contents),
+	})
+}
+
+func folderMeta(folder *entity.Folder) gin.H {
+	meta := gin.H{
+		"id":         folder.ID,
+		"name":       folder.Name,
+		"created":    folder.CreatedAt.Format("2006-01-02 15:04:05"),
+		"permission": folder.Permission,
+	}
+	if folder.ParentID != nil {
+		meta["parent_id"] = *folder.ParentID
+	}
+	if len(folder.Grant) > 0 {
+		meta["grant"] = folder.Grant
+	}
+	return meta
+}
+
+func folderContents(contents *entity.FolderContents) gin.H {
+	folders := make([]gin.H, len(contents.Folders))
+	for i, folder := range contents.Folders {
+		folders[i] = folderMeta(folder)
+	}
+
+	docs := make([]gin.H, len(contents.Docs))
+	for i, doc := range contents.Docs {
+		docs[i] = docMeta(doc)
+	}
+
+	data := gin.H{
+		"folders": folders,
+		"docs":    docs,
+	}
+	if contents.Folder != nil {
+		data["folder"] = folderMeta(contents.Folder)
+	}
+	return data
+}
@@ -1,33 +1,105 @@
-package handler
-
-import (
-	"github.com/gin-gonic/gin"
-	"github.com/paudarco/doc-storage/internal/config"
-	"github.com/paudarco/doc-storage/internal/service"
-	"github.com/sirupsen/logrus"
-)
-
-type Auth interface {
-	Authenticate(c *gin.Context)
-	Register(c *gin.Context)
-	Logout(c *gin.Context)
-}
-
-type Doc interface {
-	UploadDoc(c *gin.Context)
-	ListDocs(c *gin.Context)
-	GetDoc(c *gin.Context)
-	DeleteDoc(c *gin.Context)
-}
-
-type Handler struct {
-	Doc
-	Auth
-}
-
-func NewHandler(service *service.Service, cfg *config.Config, log *logrus.Logger) *Handler {
-	return &Handler{
-		Doc:  NewDocHandler(service.Doc, log),
-		Auth: NewAuthHandler(service.User, service.User, cfg, log),
-	}
-}
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type Auth interface {
+	Authenticate(c *gin.Context)
+	Register(c *gin.Context)
+	Logout(c *gin.Context)
+}
+
+type Doc interface {
+	UploadDoc(c *gin.Context)
+	PutDoc(c *gin.Context)
+	ListDocs(c *gin.Context)
+	GetDoc(c *gin.Context)
+	UpdateDoc(c *gin.Context)
+	ShareDoc(c *gin.Context)
+	SetDocTags(c *gin.Context)
+	ListTags(c *gin.Context)
+	SearchDocs(c *gin.Context)
+	GetDocText(c *gin.Context)
+	TransferDoc(c *gin.Context)
+	BatchDocs(c *gin.Context)
+	ArchiveDocs(c *gin.Context)
+	CheckHashes(c *gin.Context)
+	DeleteDoc(c *gin.Context)
+}
+
+type Link interface {
+	CreateLink(c *gin.Context)
+	ListLinks(c *gin.Context)
+	RevokeLink(c *gin.Context)
+	OpenLink(c *gin.Context)
+}
+
+type Presign interface {
+	PresignDownload(c *gin.Context)
+	PresignUpload(c *gin.Context)
+}
+
+type Folder interface {
+	CreateFolder(c *gin.Context)
+	ListRootFolder(c *gin.Context)
+	GetFolder(c *gin.Context)
+	UpdateFolder(c *gin.Context)
+	ShareFolder(c *gin.Context)
+	DeleteFolder(c *gin.Context)
+	GetFile(c *gin.Context)
+}
+
+type Thumbnail interface {
+	GetThumbnail(c *gin.Context)
+}
+
+type Account interface {
+	GetUsage(c *gin.Context)
+}
+
+type Admin interface {
+	TransferUserDocs(c *gin.Context)
+	GetUserQuota(c *gin.Context)
+	SetUserQuota(c *gin.Context)
+	ListQuarantine(c *gin.Context)
+	ListRetentionRules(c *gin.Context)
+	CreateRetentionRule(c *gin.Context)
+	DeleteRetentionRule(c *gin.Context)
+	SetLegalHold(c *gin.Context)
+}
+
+type Handler struct {
+	Doc
+	Auth
+	Link
+	Presign
+	Folder
+	Thumbnail
+	Account
+	Admin
+
+	presign service.Presign
+	cfg     *config.Config
+	log     *logrus.Logger
+}
+
+func NewHandler(service *service.Service, cfg *config.Config, log *logrus.Logger) *Handler {
+	return &Handler{
+		Doc:       NewDocHandler(service.Doc, log),
+		Auth:      NewAuthHandler(service.User, service.User, cfg, log),
+		Link:      NewLinkHandler(service.Link, log),
+		Presign:   NewPresignHandler(service.Presign, log),
+		Folder:    NewFolderHandler(service.Folder, log),
+		Thumbnail: NewThumbnailHandler(service.Thumbnail, cfg.ThumbnailCacheTTL, log),
+		Account:   NewAccountHandler(service.Account, log),
+		Admin:     NewAdminHandler(service.Doc, service.Account, service.Scan, service.Retention, log),
+
+		presign: service.Presign,
+		cfg:     cfg,
+		log:     log,
+	}
+}
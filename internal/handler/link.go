@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type LinkHandler struct {
+	link service.Link
+	log  *logrus.Logger
+}
+
+func NewLinkHandler(link service.Link, log *logrus.Logger) *LinkHandler {
+	return &LinkHandler{
+		link: link,
+		log:  log,
+	}
+}
+
+func (h *LinkHandler) CreateLink(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	var req entity.CreateLinkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+			return
+		}
+	}
+
+	link, err := h.link.Create(c.Request.Context(), userID, c.Param("id"), &req)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	resp := linkMeta(link)
+	resp["token"] = link.Token
+	resp["url"] = "/s/" + link.Token
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": resp,
+	})
+}
+
+func (h *LinkHandler) ListLinks(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	links, err := h.link.List(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	linkList := make([]gin.H, len(links))
+	for i, link := range links {
+		linkList[i] = linkMeta(link)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"links": linkList,
+		},
+	})
+}
+
+func (h *LinkHandler) RevokeLink(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	linkID := c.Param("linkID")
+	if err := h.link.Revoke(c.Request.Context(), userID, c.Param("id"), linkID); err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response": gin.H{
+			linkID: true,
+		},
+	})
+}
+
+// OpenLink отдает документ по публичной ссылке, авторизация не требуется. Пароль принимается
+// только в заголовке X-Link-Password или в теле POST (JSON или форма), но не в URL,
+// чтобы он не попадал в журналы доступа и историю браузера.
+func (h *LinkHandler) OpenLink(c *gin.Context) {
+	password := c.GetHeader("X-Link-Password")
+	if password == "" && c.Request.Method == http.MethodPost {
+		var req entity.OpenLinkRequest
+		if err := c.ShouldBind(&req); err != nil {
+			response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+			return
+		}
+		password = req.Password
+	}
+
+	doc, err := h.link.Open(c.Request.Context(), c.Param("token"), password)
+	if err != nil {
+		if err == errors.ErrShareLinkPassword {
+			c.Header("WWW-Authenticate", `Password realm="share"`)
+		}
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+	defer doc.CloseContent()
+
+	c.Header("Cache-Control", "no-store")
+	if doc.IsFile {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.Name}))
+	}
+
+	serveDoc(c, doc)
+}
+
+func linkMeta(link *entity.ShareLink) gin.H {
+	meta := gin.H{
+		"id":            link.ID,
+		"document_id":   link.DocumentID,
+		"expires_at":    link.ExpiresAt.Format(time.RFC3339),
+		"max_downloads": link.MaxDownloads,
+		"downloads":     link.Downloads,
+		"password":      link.HasPassword(),
+		"status":        link.Status(time.Now()),
+		"created":       link.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if link.RevokedAt != nil {
+		meta["revoked_at"] = link.RevokedAt.Format(time.RFC3339)
+	}
+	return meta
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type PresignHandler struct {
+	presign service.Presign
+	log     *logrus.Logger
+}
+
+func NewPresignHandler(presign service.Presign, log *logrus.Logger) *PresignHandler {
+	return &PresignHandler{
+		presign: presign,
+		log:     log,
+	}
+}
+
+func (h *PresignHandler) PresignDownload(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	req, ok := h.bindPresignRequest(c)
+	if !ok {
+		return
+	}
+
+	presigned, err := h.presign.PresignDownload(c.Request.Context(), userID, c.Param("id"), time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": presigned,
+	})
+}
+
+func (h *PresignHandler) PresignUpload(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, errors.ErrUnauthorized)
+		return
+	}
+
+	req, ok := h.bindPresignRequest(c)
+	if !ok {
+		return
+	}
+
+	presigned, err := h.presign.PresignUpload(c.Request.Context(), userID, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": presigned,
+	})
+}
+
+func (h *PresignHandler) bindPresignRequest(c *gin.Context) (*entity.PresignRequest, bool) {
+	var req entity.PresignRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+			return nil, false
+		}
+	}
+	return &req, true
+}
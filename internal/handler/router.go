@@ -1,34 +1,89 @@
-package handler
-
-import "github.com/gin-gonic/gin"
-
-func (h *Handler) InitRoutes() *gin.Engine {
-	router := gin.Default()
-
-	api := router.Group("/api")
-	{
-		auth := api.Group("/")
-		{
-			auth.POST("/register", h.Register)
-			auth.POST("/auth", h.Authenticate)
-		}
-
-		authorized := api.Group("/")
-		authorized.Use()
-
-		authorized.DELETE("/auth/:token", h.Logout)
-
-		docs := authorized.Group("/docs")
-		{
-			docs.POST("/", h.UploadDoc)
-			docs.GET("/", h.ListDocs)
-			docs.HEAD("/", h.ListDocs)
-			docs.GET("/:id", h.GetDoc)
-			docs.HEAD("/:id", h.GetDoc)
-			docs.DELETE("/:id", h.DeleteDoc)
-		}
-
-	}
-
-	return router
-}
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	middleware "github.com/paudarco/doc-storage/internal/handler/middleware"
+)
+
+func (h *Handler) InitRoutes() *gin.Engine {
+	router := gin.Default()
+
+	// Подписанные ссылки принимаются без bearer токена
+	presigned := middleware.PresignMiddleware(h.presign, h.log)
+
+	// Публичные ссылки доступны без авторизации
+	router.GET("/s/:token", h.OpenLink)
+	router.POST("/s/:token", h.OpenLink)
+
+	api := router.Group("/api")
+	{
+		auth := api.Group("/")
+		{
+			auth.POST("/register", h.Register)
+			auth.POST("/auth", h.Authenticate)
+		}
+
+		authorized := api.Group("/")
+		authorized.Use()
+
+		authorized.DELETE("/auth/:token", h.Logout)
+
+		docs := authorized.Group("/docs")
+		{
+			docs.POST("/", h.UploadDoc)
+			docs.GET("/", h.ListDocs)
+			docs.HEAD("/", h.ListDocs)
+			docs.GET("/tags", h.ListTags)
+			docs.GET("/search", h.SearchDocs)
+			docs.POST("/batch", h.BatchDocs)
+			docs.POST("/archive", h.ArchiveDocs)
+			docs.POST("/hashes", h.CheckHashes)
+			docs.GET("/:id", presigned, h.GetDoc)
+			docs.HEAD("/:id", presigned, h.GetDoc)
+			docs.PUT("/:id", presigned, h.PutDoc)
+			docs.POST("/presign", h.PresignUpload)
+			docs.POST("/:id/presign", h.PresignDownload)
+			docs.PATCH("/:id", h.UpdateDoc)
+			docs.PUT("/:id/grant", h.ShareDoc)
+			docs.PUT("/:id/tags", h.SetDocTags)
+			docs.GET("/:id/text", h.GetDocText)
+			docs.GET("/:id/thumbnail", h.GetThumbnail)
+			docs.POST("/:id/transfer", h.TransferDoc)
+			docs.POST("/:id/links", h.CreateLink)
+			docs.GET("/:id/links", h.ListLinks)
+			docs.DELETE("/:id/links/:linkID", h.RevokeLink)
+			docs.DELETE("/:id", h.DeleteDoc)
+		}
+
+		folders := authorized.Group("/folders")
+		{
+			folders.POST("/", h.CreateFolder)
+			folders.GET("/", h.ListRootFolder)
+			folders.GET("/:id", h.GetFolder)
+			folders.PATCH("/:id", h.UpdateFolder)
+			folders.PUT("/:id/grant", h.ShareFolder)
+			folders.DELETE("/:id", h.DeleteFolder)
+		}
+
+		authorized.GET("/account/usage", h.GetUsage)
+
+		authorized.GET("/files/*path", h.GetFile)
+		authorized.HEAD("/files/*path", h.GetFile)
+
+		admin := api.Group("/admin")
+		admin.Use(middleware.AdminMiddleware(h.cfg.AdminToken, h.log))
+		{
+			admin.POST("/users/:login/transfer", h.TransferUserDocs)
+			admin.GET("/users/:login/quota", h.GetUserQuota)
+			admin.PUT("/users/:login/quota", h.SetUserQuota)
+			admin.GET("/quarantine", h.ListQuarantine)
+			admin.GET("/retention", h.ListRetentionRules)
+			admin.POST("/retention", h.CreateRetentionRule)
+			admin.DELETE("/retention/:id", h.DeleteRetentionRule)
+			admin.PUT("/docs/:id/legal-hold", h.SetLegalHold)
+		}
+
+	}
+
+	return router
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/handler/response"
+	"github.com/paudarco/doc-storage/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type ThumbnailHandler struct {
+	thumbnail service.Thumbnail
+	maxAge    int
+	log       *logrus.Logger
+}
+
+func NewThumbnailHandler(thumbnail service.Thumbnail, maxAge int, log *logrus.Logger) *ThumbnailHandler {
+	return &ThumbnailHandler{
+		thumbnail: thumbnail,
+		maxAge:    maxAge,
+		log:       log,
+	}
+}
+
+// GetThumbnail отдает превью изображения. Пока превью строится, отвечает 202.
+func (h *ThumbnailHandler) GetThumbnail(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	docID := c.Param("id")
+	if docID == "" {
+		response.NewErrorResponse(c, h.log, errors.ErrInvalidRequestBody)
+		return
+	}
+
+	size := 0
+	if s := c.Query("size"); s != "" {
+		size, err = strconv.Atoi(s)
+		if err != nil {
+			response.NewErrorResponse(c, h.log, errors.ErrInvalidThumbnailSize)
+			return
+		}
+	}
+
+	thumb, err := h.thumbnail.Get(c.Request.Context(), userID, docID, size)
+	if err == errors.ErrThumbnailPending {
+		c.JSON(http.StatusAccepted, gin.H{
+			"data": gin.H{
+				"id":     docID,
+				"status": entity.ThumbnailStatusPending,
+			},
+		})
+		return
+	} else if err != nil {
+		response.NewErrorResponse(c, h.log, err)
+		return
+	}
+
+	// Превью доступно только авторизованным пользователям, поэтому кэшируется лишь на клиенте
+	etag := fmt.Sprintf(`"%s-%d-%d"`, thumb.DocumentID, thumb.Size, thumb.CreatedAt.Unix())
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", h.maxAge))
+	c.Header("Last-Modified", thumb.CreatedAt.UTC().Format(http.TimeFormat))
+	c.Header("Vary", "Authorization")
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, thumb.Mime, thumb.Data)
+}
@@ -0,0 +1,22 @@
+package repository
+
+// accessibleFoldersCTE папки, документы в которых доступны пользователю $1 (логин $2):
+// собственные папки, папки с грантом на логин и все вложенные в них.
+// Используется как часть WITH RECURSIVE.
+const accessibleFoldersCTE = `accessible_folders AS (
+	SELECT f.id FROM folders f WHERE f.user_id = $1
+	UNION
+	SELECT g.folder_id FROM folder_grants g WHERE g.login = $2
+	UNION
+	SELECT c.id FROM folders c JOIN accessible_folders a ON c.parent_id = a.id
+)`
+
+// liveDocCondition скрывает документы с истекшим сроком жизни до того, как их удалит фоновая очистка
+const liveDocCondition = `(d.expires_at IS NULL OR d.expires_at > now())`
+
+// readableDocCondition условие на документ d, доступный на чтение пользователю $1 (логин $2).
+// Требует accessibleFoldersCTE в запросе.
+const readableDocCondition = `(d.user_id = $1
+	OR d.public
+	OR EXISTS (SELECT 1 FROM document_grants dg WHERE dg.document_id = d.id AND dg.login = $2)
+	OR d.folder_id IN (SELECT id FROM accessible_folders))`
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+// GetByIDs возвращает существующие документы из ids вместе с грантами и тегами, без содержимого
+func (r *DocRepository) GetByIDs(ctx context.Context, ids []string) ([]*entity.Document, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := `SELECT ` + docColumns + ` FROM documents d WHERE d.id = ANY($1::uuid[]) AND ` + liveDocCondition
+	return r.queryDocs(ctx, query, ids)
+}
+
+// Batch выполняет операции в одной транзакции и возвращает ошибку для каждой из них (nil - успех).
+// В режиме atomic первая ошибка откатывает всю транзакцию, остальные операции получают
+// ErrBatchRolledBack. Иначе каждая операция выполняется в своей точке сохранения
+// и ее ошибка не влияет на остальные.
+func (r *DocRepository) Batch(ctx context.Context, ops []entity.BatchOperation, atomic bool) ([]error, error) {
+	results := make([]error, len(ops))
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	for i := range ops {
+		if atomic {
+			if err := applyBatchOp(ctx, tx, &ops[i]); err != nil {
+				for j := range results {
+					results[j] = errors.ErrBatchRolledBack
+				}
+				results[i] = err
+				return results, nil
+			}
+			continue
+		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyBatchOp(ctx, sp, &ops[i]); err != nil {
+			results[i] = err
+			if rbErr := sp.Rollback(ctx); rbErr != nil {
+				return nil, rbErr
+			}
+			continue
+		}
+		if err := sp.Commit(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func applyBatchOp(ctx context.Context, tx pgx.Tx, op *entity.BatchOperation) error {
+	switch op.Op {
+	case entity.BatchDelete:
+		result, err := tx.Exec(ctx, `DELETE FROM documents WHERE id = $1`, op.ID)
+		if err != nil {
+			return retentionError(err)
+		}
+		if result.RowsAffected() == 0 {
+			return errors.ErrDocNotFound
+		}
+		return nil
+
+	case entity.BatchSetPublic:
+		result, err := tx.Exec(ctx, `UPDATE documents SET public = $2, updated_at = now() WHERE id = $1`, op.ID, *op.Public)
+		if err != nil {
+			return retentionError(err)
+		}
+		if result.RowsAffected() == 0 {
+			return errors.ErrDocNotFound
+		}
+		return nil
+
+	case entity.BatchMove:
+		var folderID *string
+		if *op.FolderID != "" {
+			folderID = op.FolderID
+		}
+		result, err := tx.Exec(ctx, `UPDATE documents SET folder_id = $2, updated_at = now() WHERE id = $1`, op.ID, folderID)
+		if err != nil {
+			return retentionError(err)
+		}
+		if result.RowsAffected() == 0 {
+			return errors.ErrDocNotFound
+		}
+		return nil
+
+	case entity.BatchAddGrant:
+		if err := touchDoc(ctx, tx, op.ID); err != nil {
+			return err
+		}
+		return insertGrants(ctx, tx, op.ID, []entity.Grant{*op.Grant})
+
+	case entity.BatchRemoveGrant:
+		if err := touchDoc(ctx, tx, op.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM document_grants WHERE document_id = $1 AND login = $2`, op.ID, op.Login)
+		return err
+
+	case entity.BatchAddTags:
+		if err := touchDoc(ctx, tx, op.ID); err != nil {
+			return err
+		}
+		return insertTags(ctx, tx, op.ID, op.Tags)
+
+	case entity.BatchRemoveTags:
+		if err := touchDoc(ctx, tx, op.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `SELECT check_document_tags_retention($1, ARRAY(
+		                            SELECT tag::text FROM document_tags WHERE document_id = $1 AND tag <> ALL($2)))`, op.ID, op.Tags)
+		if err != nil {
+			return retentionError(err)
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM document_tags WHERE document_id = $1 AND tag = ANY($2)`, op.ID, op.Tags)
+		return err
+	}
+
+	return errors.ErrInvalidBatchOp
+}
@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+)
+
+// loWriteSize размер блока записи и чтения large object
+const loWriteSize = 1 << 20
+
+type BlobRepository struct {
+	db   *pgxpool.Pool
+	keys *envelope.Keyring
+}
+
+func NewBlobRepository(db *pgxpool.Pool, keys *envelope.Keyring) *BlobRepository {
+	return &BlobRepository{db: db, keys: keys}
+}
+
+// Open открывает расшифрованное содержимое блоба для чтения потоком. Потоковые загрузки лежат
+// в large object и читаются блоками в транзакции только для чтения, ранние блобы - из data.
+// Транзакцию завершает Content.Close. Блоб в карантине открывается с Corrupt, решение
+// отдавать ли его остается за вызывающим.
+func (r *BlobRepository) Open(ctx context.Context, hash string) (*entity.Blob, error) {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	blob, err := r.openTx(ctx, tx, hash)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return blob, nil
+}
+
+func (r *BlobRepository) openTx(ctx context.Context, tx pgx.Tx, hash string) (*entity.Blob, error) {
+	blob := &entity.Blob{Hash: hash}
+	var data []byte
+	var lo *uint32
+	key := &contentKey{}
+	err := tx.QueryRow(ctx, `SELECT b.size, b.data, b.lo, b.corrupt_at IS NOT NULL, `+blobKeyColumns+` FROM blobs b`+blobKeyJoin+`
+	                         WHERE b.hash = $1`, hash).
+		Scan(append([]interface{}{&blob.Size, &data, &lo, &blob.Corrupt}, key.dest()...)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrBlobNotFound
+		}
+		return nil, err
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if lo != nil {
+		objects := tx.LargeObjects()
+		obj, err := objects.Open(ctx, *lo, pgx.LargeObjectModeRead)
+		if err != nil {
+			return nil, err
+		}
+		// Каждое чтение large object - отдельный запрос, поэтому читаем крупными блоками
+		src = bufio.NewReaderSize(obj, loWriteSize)
+	}
+
+	cek, err := key.open(r.keys)
+	if err != nil {
+		return nil, err
+	}
+	if cek != nil {
+		if src, err = envelope.NewReader(src, cek); err != nil {
+			return nil, err
+		}
+	}
+
+	blob.Content = &txReader{Reader: src, ctx: ctx, tx: tx}
+	return blob, nil
+}
+
+// txReader поток содержимого, Close завершает транзакцию, в которой он читается
+type txReader struct {
+	io.Reader
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func (r *txReader) Close() error {
+	return r.tx.Rollback(r.ctx)
+}
+
+// Get возвращает содержимое по хэшу целиком, блоб в карантине не отдается
+func (r *BlobRepository) Get(ctx context.Context, hash string) ([]byte, error) {
+	blob, err := r.Open(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Content.Close()
+	if blob.Corrupt {
+		return nil, errors.ErrContentCorrupt
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, blob.Size))
+	if _, err := buf.ReadFrom(blob.Content); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetOwned возвращает содержимое, только если на него ссылается документ владельца ownerID.
+// Так хэш чужого файла не дает доступа к его содержимому.
+func (r *BlobRepository) GetOwned(ctx context.Context, ownerID, hash string) ([]byte, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM documents d WHERE d.user_id = $1 AND d.content_hash = $2 AND ` + liveDocCondition + `)`
+	var owned bool
+	if err := r.db.QueryRow(ctx, query, ownerID, hash).Scan(&owned); err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, errors.ErrBlobNotFound
+	}
+
+	data, err := r.Get(ctx, hash)
+	if err == errors.ErrContentCorrupt {
+		return nil, errors.ErrBlobNotFound
+	}
+	return data, err
+}
+
+// Owned возвращает те из hashes, на которые ссылаются документы владельца ownerID
+func (r *BlobRepository) Owned(ctx context.Context, ownerID string, hashes []string) ([]string, error) {
+	query := `SELECT DISTINCT d.content_hash FROM documents d
+	          WHERE d.user_id = $1 AND d.content_hash = ANY($2) AND ` + liveDocCondition
+	rows, err := r.db.Query(ctx, query, ownerID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		found = append(found, hash)
+	}
+	return found, rows.Err()
+}
+
+// Collect удаляет до limit блобов, на которые никто не ссылается дольше grace, и возвращает их число.
+// Повторная проверка ref_count в DELETE защищает от загрузки, успевшей сослаться на блоб.
+func (r *BlobRepository) Collect(ctx context.Context, grace time.Duration, limit int) (int64, error) {
+	query := `DELETE FROM blobs WHERE hash IN (
+	              SELECT hash FROM blobs
+	              WHERE ref_count = 0 AND unreferenced_at < now() - make_interval(secs => $1)
+	              LIMIT $2
+	              FOR UPDATE SKIP LOCKED
+	          ) AND ref_count = 0`
+	result, err := r.db.Exec(ctx, query, grace.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// Scan возвращает до limit блобов (хэш и размер, без содержимого) с хэшем больше after в порядке хэша
+func (r *BlobRepository) Scan(ctx context.Context, after string, limit int) ([]*entity.Blob, error) {
+	rows, err := r.db.Query(ctx, `SELECT hash, size FROM blobs WHERE hash > $1 ORDER BY hash LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blobs := []*entity.Blob{}
+	for rows.Next() {
+		blob := &entity.Blob{}
+		if err := rows.Scan(&blob.Hash, &blob.Size); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs, rows.Err()
+}
+
+// MarkVerified отмечает блобы проверенными и снимает карантин
+func (r *BlobRepository) MarkVerified(ctx context.Context, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(ctx, `UPDATE blobs SET verified_at = now(), corrupt_at = NULL WHERE hash = ANY($1)`, hashes)
+	return err
+}
+
+// MarkCorrupt помещает блоб в карантин: его содержимое больше не отдается
+func (r *BlobRepository) MarkCorrupt(ctx context.Context, hash string) error {
+	_, err := r.db.Exec(ctx, `UPDATE blobs SET corrupt_at = COALESCE(corrupt_at, now()) WHERE hash = $1`, hash)
+	return err
+}
+
+// References возвращает документы, ссылающиеся на блоб
+func (r *BlobRepository) References(ctx context.Context, hash string) ([]entity.BlobRef, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, user_id, name FROM documents WHERE content_hash = $1 ORDER BY id`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := []entity.BlobRef{}
+	for rows.Next() {
+		var ref entity.BlobRef
+		if err := rows.Scan(&ref.DocumentID, &ref.UserID, &ref.Name); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// Put записывает содержимое r потоком в large object, считая SHA-256 по ходу записи, и возвращает
+// хэш и размер. check вызывается до фиксации и может отклонить содержимое (размер, ожидаемый хэш),
+// тогда ничего не сохраняется. Если блоб с таким хэшем уже есть, записанная копия отбрасывается.
+// Новый блоб до вставки документа не имеет ссылок и удаляется сборщиком через BLOB_GC_GRACE,
+// если документ так и не будет создан.
+func (r *BlobRepository) Put(ctx context.Context, src io.Reader, check func(hash string, size int64) error) (string, int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	objects := tx.LargeObjects()
+	oid, err := objects.Create(ctx, 0)
+	if err != nil {
+		return "", 0, err
+	}
+	obj, err := objects.Open(ctx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Каждая запись в large object - отдельный запрос, поэтому пишем крупными блоками
+	buf := bufio.NewWriterSize(obj, loWriteSize)
+	var dst io.Writer = buf
+	var enc *envelope.Writer
+	keyID, wrapped := (*string)(nil), []byte(nil)
+	if r.keys.Enabled() {
+		id, dek, w, err := r.keys.NewKey()
+		if err != nil {
+			return "", 0, err
+		}
+		if enc, err = envelope.NewWriter(buf, dek); err != nil {
+			return "", 0, err
+		}
+		dst, keyID, wrapped = enc, &id, w
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(dst, io.TeeReader(src, hasher))
+	if err != nil {
+		return "", 0, err
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return "", 0, err
+	}
+	if err := obj.Close(); err != nil {
+		return "", 0, err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if err := check(hash, size); err != nil {
+		return "", 0, err
+	}
+
+	// Существующий блоб получает новый срок до сборки, чтобы дожить до вставки документа.
+	// Блоб в карантине или с потерянным ключом заменяется записанной копией.
+	var replace bool
+	err = tx.QueryRow(ctx, `UPDATE blobs b SET unreferenced_at = CASE WHEN ref_count = 0 THEN now() END
+	                        WHERE b.hash = $1 RETURNING b.corrupt_at IS NOT NULL OR `+blobLost, hash).Scan(&replace)
+	switch {
+	case err == nil && !replace:
+		err = objects.Unlink(ctx, oid)
+	case err == nil:
+		_, err = tx.Exec(ctx, `UPDATE blobs SET data = NULL, lo = $2, size = $3, key_id = $4, wrapped_key = $5,
+		                              sealed = false, corrupt_at = NULL, verified_at = now()
+		                       WHERE hash = $1`, hash, oid, size, keyID, wrapped)
+	case err == pgx.ErrNoRows:
+		var result pgconn.CommandTag
+		result, err = tx.Exec(ctx, `INSERT INTO blobs (hash, size, lo, key_id, wrapped_key, unreferenced_at)
+		                            VALUES ($1, $2, $3, $4, $5, now())
+		                            ON CONFLICT (hash) DO NOTHING`, hash, size, oid, keyID, wrapped)
+		// Тот же файл успели сохранить параллельно
+		if err == nil && result.RowsAffected() == 0 {
+			err = objects.Unlink(ctx, oid)
+		}
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// storeBlob сохраняет содержимое, если блоба с таким хэшем еще нет, и возвращает ключ содержимого
+// (nil - блоб хранится открытым). Счетчик ссылок увеличивает триггер при вставке документа.
+// UPDATE существующего блоба блокирует строку до конца транзакции, поэтому сборщик мусора не удалит
+// его до вставки документа. Блоб в карантине или с потерянным ключом перезаписывается загруженным
+// содержимым, хэш которого уже проверен. data == nil означает, что содержимое уже записано Put,
+// и блоб должен существовать.
+// Новый блоб хранит ключ содержимого обернутым мастер-ключом, пока документ не сохранит свою копию
+// (releaseBlobKey): одно содержимое делят все ссылающиеся документы.
+func storeBlob(ctx context.Context, tx pgx.Tx, keys *envelope.Keyring, hash string, data []byte) ([]byte, error) {
+	var replace bool
+	err := tx.QueryRow(ctx, `UPDATE blobs b SET unreferenced_at = NULL WHERE b.hash = $1
+	                         RETURNING b.corrupt_at IS NOT NULL OR `+blobLost, hash).Scan(&replace)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && !replace {
+		return blobContentKey(ctx, tx, keys, hash)
+	}
+	if data == nil {
+		return nil, errors.ErrBlobNotFound
+	}
+
+	stored, keyID, wrapped, cek := data, (*string)(nil), []byte(nil), []byte(nil)
+	if keys.Enabled() {
+		id, dek, w, err := keys.NewKey()
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := envelope.Encrypt(dek, data)
+		if err != nil {
+			return nil, err
+		}
+		stored, keyID, wrapped, cek = ciphertext, &id, w, dek
+	}
+
+	if replace {
+		_, err = tx.Exec(ctx, `UPDATE blobs SET data = $2, lo = NULL, size = $3, key_id = $4, wrapped_key = $5,
+		                              sealed = false, corrupt_at = NULL, verified_at = now()
+		                       WHERE hash = $1`, hash, stored, len(data), keyID, wrapped)
+		return cek, err
+	}
+
+	result, err := tx.Exec(ctx, `INSERT INTO blobs (hash, size, data, key_id, wrapped_key) VALUES ($1, $2, $3, $4, $5)
+	                             ON CONFLICT (hash) DO NOTHING`, hash, len(data), stored, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	// Тот же файл успели сохранить параллельно, у него свой ключ
+	if result.RowsAffected() == 0 {
+		return blobContentKey(ctx, tx, keys, hash)
+	}
+	return cek, nil
+}
+
+// blobContentKey возвращает ключ содержимого существующего блоба. При выключенном шифровании
+// ключ не нужен: документ ссылается на блоб без своей копии ключа.
+func blobContentKey(ctx context.Context, tx pgx.Tx, keys *envelope.Keyring, hash string) ([]byte, error) {
+	if !keys.Enabled() {
+		return nil, nil
+	}
+	key := &contentKey{}
+	err := tx.QueryRow(ctx, `SELECT `+blobKeyColumns+` FROM blobs b`+blobKeyJoin+` WHERE b.hash = $1`, hash).Scan(key.dest()...)
+	if err != nil {
+		return nil, err
+	}
+	return key.open(keys)
+}
+
+// releaseBlobKey убирает у блоба ключ, обернутый мастер-ключом, когда копия ключа содержимого
+// есть у всех ссылающихся документов. Дальше содержимое читается только через ключ документа.
+func releaseBlobKey(ctx context.Context, tx pgx.Tx, hash string) error {
+	_, err := tx.Exec(ctx, `UPDATE blobs b SET key_id = NULL, wrapped_key = NULL, sealed = true
+	                        WHERE b.hash = $1 AND b.key_id IS NOT NULL
+	                          AND NOT EXISTS (SELECT 1 FROM documents d WHERE d.content_hash = b.hash AND d.content_key IS NULL)`, hash)
+	return err
+}
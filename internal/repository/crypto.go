@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+)
+
+// sealedJSON содержимое JSON-документа в том виде, в каком оно лежит в базе: открытым текстом
+// в json_data или зашифрованным в json_enc вместе с обернутым ключом данных
+type sealedJSON struct {
+	plain   []byte
+	enc     []byte
+	keyID   *string
+	wrapped []byte
+}
+
+// sealJSON шифрует содержимое ключом данных документа, без ключа (шифрование выключено) оставляет как есть
+func sealJSON(key *docKey, data json.RawMessage) (*sealedJSON, error) {
+	if data == nil || key == nil {
+		return &sealedJSON{plain: data}, nil
+	}
+	enc, err := key.seal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &sealedJSON{enc: enc, keyID: &key.keyID, wrapped: key.wrapped}, nil
+}
+
+// dest цели для Scan в порядке колонок json_data, json_enc, key_id, wrapped_key
+func (s *sealedJSON) dest() []interface{} {
+	return []interface{}{&s.plain, &s.enc, &s.keyID, &s.wrapped}
+}
+
+// open возвращает содержимое в открытом виде, nil - у документа нет JSON
+func (s *sealedJSON) open(keys *envelope.Keyring) ([]byte, error) {
+	if s.enc == nil || s.keyID == nil {
+		return s.plain, nil
+	}
+	return keys.Open(*s.keyID, s.wrapped, s.enc)
+}
+
+// openInto расшифровывает содержимое в doc.JSONData, документ без содержимого не меняется
+func (s *sealedJSON) openInto(keys *envelope.Keyring, doc *entity.Document) error {
+	data, err := s.open(keys)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		doc.JSONData = json.RawMessage(data)
+	}
+	return nil
+}
+
+// jsonValue значение для колонки jsonb, пустой срез должен попасть в базу как NULL
+func (s *sealedJSON) jsonValue() interface{} {
+	if s.plain == nil {
+		return nil
+	}
+	return json.RawMessage(s.plain)
+}
+
+// docKey ключ данных документа. Им шифруются JSON, извлеченный текст, превью и копия ключа
+// содержимого файла.
+type docKey struct {
+	keyID   string
+	wrapped []byte
+	dek     []byte
+}
+
+// newDocKey создает ключ данных нового документа, nil - шифрование выключено
+func newDocKey(keys *envelope.Keyring) (*docKey, error) {
+	if !keys.Enabled() {
+		return nil, nil
+	}
+	keyID, dek, wrapped, err := keys.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	return &docKey{keyID: keyID, wrapped: wrapped, dek: dek}, nil
+}
+
+// openDocKey расшифровывает ключ данных документа, nil - у документа нет ключа
+func openDocKey(keys *envelope.Keyring, keyID *string, wrapped []byte) (*docKey, error) {
+	if keyID == nil {
+		return nil, nil
+	}
+	dek, err := keys.Unwrap(*keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return &docKey{keyID: *keyID, wrapped: wrapped, dek: dek}, nil
+}
+
+// columns значения колонок key_id и wrapped_key
+func (k *docKey) columns() (*string, []byte) {
+	if k == nil {
+		return nil, nil
+	}
+	return &k.keyID, k.wrapped
+}
+
+// seal шифрует data ключом документа. Без ключа или данных возвращает nil: вызывающий
+// сохраняет данные открытыми.
+func (k *docKey) seal(data []byte) ([]byte, error) {
+	if k == nil || data == nil {
+		return nil, nil
+	}
+	return envelope.Encrypt(k.dek, data)
+}
+
+func (k *docKey) open(data []byte) ([]byte, error) {
+	return envelope.Decrypt(k.dek, data)
+}
+
+// errContentKeyLost содержимое зашифровано ключом документов, а ссылающихся документов не осталось.
+// Для проверки целостности такое содержимое неотличимо от поврежденного.
+var errContentKeyLost = fmt.Errorf("%w: content key is lost, no document references the blob", envelope.ErrDecrypt)
+
+// blobKeyColumns колонки contentKey, запрос должен присоединить blobKeyJoin к blobs b
+const blobKeyColumns = `b.key_id, b.wrapped_key, b.sealed, k.key_id, k.wrapped_key, k.content_key`
+
+// blobKeyJoin ключ содержимого берется у любого ссылающегося документа, у всех он один
+const blobKeyJoin = ` LEFT JOIN LATERAL (
+	                      SELECT d.key_id, d.wrapped_key, d.content_key FROM documents d
+	                      WHERE d.content_hash = b.hash AND d.content_key IS NOT NULL LIMIT 1
+	                  ) k ON b.sealed`
+
+// blobLost условие для блоба с потерянным ключом: ссылающихся документов с ключом содержимого
+// не осталось. Такой блоб ждет сборщика и перезаписывается новой загрузкой того же файла.
+const blobLost = `(b.sealed AND b.key_id IS NULL AND NOT EXISTS (
+	                  SELECT 1 FROM documents d WHERE d.content_hash = b.hash AND d.content_key IS NOT NULL))`
+
+// contentKey откуда взять ключ содержимого блоба: у самого блоба, обернутый мастер-ключом
+// (на блоб еще не сослался документ или он записан до ключей документов), или у документа,
+// зашифрованный его ключом данных
+type contentKey struct {
+	keyID      *string
+	wrapped    []byte
+	sealed     bool
+	docKeyID   *string
+	docWrapped []byte
+	docContent []byte
+}
+
+// dest цели для Scan в порядке blobKeyColumns
+func (k *contentKey) dest() []interface{} {
+	return []interface{}{&k.keyID, &k.wrapped, &k.sealed, &k.docKeyID, &k.docWrapped, &k.docContent}
+}
+
+// open возвращает ключ содержимого, nil - содержимое хранится открытым
+func (k *contentKey) open(keys *envelope.Keyring) ([]byte, error) {
+	switch {
+	case k.keyID != nil:
+		return keys.Unwrap(*k.keyID, k.wrapped)
+	case !k.sealed:
+		return nil, nil
+	case k.docKeyID == nil:
+		return nil, errContentKeyLost
+	}
+	doc, err := openDocKey(keys, k.docKeyID, k.docWrapped)
+	if err != nil {
+		return nil, err
+	}
+	return doc.open(k.docContent)
+}
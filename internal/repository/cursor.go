@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+// docCursor позиция в списке документов: значения ключей сортировки крайнего документа страницы.
+// Prev означает движение к началу списка от этой позиции.
+type docCursor struct {
+	Sort   string   `json:"s"`
+	Desc   bool     `json:"d,omitempty"`
+	Values []string `json:"v"`
+	Prev   bool     `json:"p,omitempty"`
+}
+
+func encodeCursor(cursor docCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор и проверяет, что он выдан для той же сортировки
+func decodeCursor(raw string, sort entity.DocSort, keys int) (*docCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	var cursor docCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.ErrInvalidCursor
+	}
+	if cursor.Sort != sort.Field || cursor.Desc != sort.Desc || len(cursor.Values) != keys {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// sortKey выражение сортировки и тип, к которому приводится значение из курсора
+type sortKey struct {
+	expr string
+	cast string
+	desc bool
+}
+
+// docSortKeys ключи сортировки списка, последним всегда идет id, чтобы порядок был однозначным.
+// attrParam - плейсхолдер с ключом атрибута для сортировки attr.<key>.
+func docSortKeys(sort entity.DocSort, attrParam string) []sortKey {
+	var keys []sortKey
+	switch sort.Field {
+	case entity.SortCreated:
+		keys = []sortKey{{expr: "d.created_at", cast: "timestamp", desc: sort.Desc}}
+	case entity.SortSize:
+		keys = []sortKey{{expr: "d.size", cast: "bigint", desc: sort.Desc}}
+	case entity.SortUpdated:
+		keys = []sortKey{{expr: "d.updated_at", cast: "timestamp", desc: sort.Desc}}
+	case entity.SortName:
+		keys = []sortKey{{expr: "d.name", cast: "text", desc: sort.Desc}}
+	default:
+		// Документы без атрибута всегда в конце списка, независимо от направления
+		value := "d.attributes ->> " + attrParam
+		keys = []sortKey{
+			{expr: "(" + value + ") IS NULL", cast: "boolean"},
+			{expr: "COALESCE(" + value + ", '')", cast: "text", desc: sort.Desc},
+		}
+	}
+	return append(keys, sortKey{expr: "d.id", cast: "uuid", desc: sort.Desc})
+}
+
+// reverseKeys меняет направление сортировки для перехода на предыдущую страницу
+func reverseKeys(keys []sortKey) []sortKey {
+	reversed := make([]sortKey, len(keys))
+	for i, key := range keys {
+		key.desc = !key.desc
+		reversed[i] = key
+	}
+	return reversed
+}
+
+func orderByKeys(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if key.desc {
+			direction = "DESC"
+		}
+		parts[i] = key.expr + " " + direction
+	}
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// selectKeys колонки со значениями ключей сортировки в текстовом виде для построения курсора
+func selectKeys(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = "(" + key.expr + ")::text"
+	}
+	return ", " + strings.Join(parts, ", ")
+}
+
+// keysetCondition условие "строго после курсора" для ключей с разными направлениями:
+// (k1 > $1) OR (k1 = $1 AND k2 > $2) OR ...
+func keysetCondition(keys []sortKey, firstArg int) string {
+	var alternatives []string
+	for i, key := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("(%s) = $%d::%s", keys[j].expr, firstArg+j, keys[j].cast))
+		}
+		op := ">"
+		if key.desc {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("(%s) %s $%d::%s", key.expr, op, firstArg+i, key.cast))
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor docCursor
+		sort   entity.DocSort
+	}{
+		{
+			name:   "created desc",
+			cursor: docCursor{Sort: entity.SortCreated, Desc: true, Values: []string{"2024-01-02 03:04:05.123456", "0b6c1f3e-5a8e-4c9b-9d7c-1d2e3f4a5b6c"}},
+			sort:   entity.DocSort{Field: entity.SortCreated, Desc: true},
+		},
+		{
+			name:   "name asc prev",
+			cursor: docCursor{Sort: entity.SortName, Values: []string{"отчет, \"итог\".pdf", "0b6c1f3e-5a8e-4c9b-9d7c-1d2e3f4a5b6c"}, Prev: true},
+			sort:   entity.DocSort{Field: entity.SortName},
+		},
+		{
+			name:   "attribute",
+			cursor: docCursor{Sort: "attr.project", Values: []string{"false", "", "0b6c1f3e-5a8e-4c9b-9d7c-1d2e3f4a5b6c"}},
+			sort:   entity.DocSort{Field: "attr.project"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := encodeCursor(tt.cursor)
+			got, err := decodeCursor(raw, tt.sort, len(tt.cursor.Values))
+			if err != nil {
+				t.Fatalf("decode %q: %v", raw, err)
+			}
+			if !reflect.DeepEqual(*got, tt.cursor) {
+				t.Fatalf("got %+v, want %+v", *got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	valid := encodeCursor(docCursor{Sort: entity.SortSize, Desc: true, Values: []string{"10", "0b6c1f3e-5a8e-4c9b-9d7c-1d2e3f4a5b6c"}})
+	sizeDesc := entity.DocSort{Field: entity.SortSize, Desc: true}
+
+	tests := []struct {
+		name string
+		raw  string
+		sort entity.DocSort
+		keys int
+	}{
+		{name: "not base64", raw: "%%%", sort: sizeDesc, keys: 2},
+		{name: "padded base64", raw: base64.URLEncoding.EncodeToString([]byte(`{"s":"size"}`)), sort: sizeDesc, keys: 2},
+		{name: "not json", raw: base64.RawURLEncoding.EncodeToString([]byte("size:10")), sort: sizeDesc, keys: 2},
+		{name: "other field", raw: valid, sort: entity.DocSort{Field: entity.SortName, Desc: true}, keys: 2},
+		{name: "other direction", raw: valid, sort: entity.DocSort{Field: entity.SortSize}, keys: 2},
+		{name: "wrong key count", raw: valid, sort: sizeDesc, keys: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.raw, tt.sort, tt.keys); err != errors.ErrInvalidCursor {
+				t.Fatalf("error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	keys := []sortKey{
+		{expr: "d.size", cast: "bigint", desc: true},
+		{expr: "d.id", cast: "uuid", desc: true},
+	}
+
+	want := "(((d.size) < $3::bigint) OR ((d.size) = $3::bigint AND (d.id) < $4::uuid))"
+	if got := keysetCondition(keys, 3); got != want {
+		t.Errorf("keysetCondition = %s, want %s", got, want)
+	}
+
+	// Предыдущая страница: те же ключи в обратном направлении
+	want = "(((d.size) > $1::bigint) OR ((d.size) = $1::bigint AND (d.id) > $2::uuid))"
+	if got := keysetCondition(reverseKeys(keys), 1); got != want {
+		t.Errorf("reversed keysetCondition = %s, want %s", got, want)
+	}
+	if got := orderByKeys(reverseKeys(keys)); got != " ORDER BY d.size ASC, d.id ASC" {
+		t.Errorf("reversed orderByKeys = %s", got)
+	}
+}
+
+func TestDocSortKeys(t *testing.T) {
+	tests := []struct {
+		sort  entity.DocSort
+		order string
+	}{
+		{entity.DocSort{Field: entity.SortName}, " ORDER BY d.name ASC, d.id ASC"},
+		{entity.DocSort{Field: entity.SortUpdated, Desc: true}, " ORDER BY d.updated_at DESC, d.id DESC"},
+		// Документы без атрибута в конце списка при любом направлении
+		{entity.DocSort{Field: "attr.project", Desc: true},
+			" ORDER BY (d.attributes ->> $5) IS NULL ASC, COALESCE(d.attributes ->> $5, '') DESC, d.id DESC"},
+	}
+
+	for _, tt := range tests {
+		if got := orderByKeys(docSortKeys(tt.sort, "$5")); got != tt.order {
+			t.Errorf("docSortKeys(%+v) = %s, want %s", tt.sort, got, tt.order)
+		}
+	}
+}
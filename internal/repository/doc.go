@@ -1,115 +1,971 @@
-package repository
-
-import (
-	"context"
-	"fmt"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/paudarco/doc-storage/internal/entity"
-	"github.com/paudarco/doc-storage/internal/errors"
-)
-
-type DocRepository struct {
-	db *pgxpool.Pool
-}
-
-func NewDocRepository(db *pgxpool.Pool) *DocRepository {
-	return &DocRepository{
-		db: db,
-	}
-}
-
-func (r *DocRepository) Create(ctx context.Context, doc *entity.Document) error {
-	query := `INSERT INTO documents (id, user_id, name, is_file, public, mime, grant_list, created_at) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := r.db.Exec(ctx, query, doc.ID, doc.UserID, doc.Name, doc.IsFile, doc.Public, doc.Mime, doc.Grant, doc.CreatedAt)
-	return err
-}
-
-func (r *DocRepository) GetByID(ctx context.Context, id string) (*entity.Document, error) {
-	query := `SELECT d.id, d.user_id, d.name, d.is_file, d.public, d.mime, d.grant_list, d.created_at,
-	                 u.login
-	          FROM documents d
-	          JOIN users u ON d.user_id = u.id
-	          WHERE d.id = $1`
-	doc := &entity.Document{}
-	var ownerLogin string
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&doc.ID, &doc.UserID, &doc.Name, &doc.IsFile, &doc.Public,
-		&doc.Mime, &doc.Grant, &doc.CreatedAt, &ownerLogin,
-	)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, errors.ErrDocNotFound
-		}
-		return nil, err
-	}
-	return doc, nil
-}
-
-func (r *DocRepository) List(ctx context.Context, userID, loginFilter, keyFilter, valueFilter string, limit int) ([]*entity.Document, error) {
-	targetUserID := userID
-	if loginFilter != "" && loginFilter != userID {
-		var userUUID string
-		err := r.db.QueryRow(ctx, `SELECT id FROM users WHERE login = $1`, loginFilter).Scan(&userUUID)
-		if err != nil {
-			if err == pgx.ErrNoRows {
-				return []*entity.Document{}, nil
-			}
-			return nil, err
-		}
-		targetUserID = userUUID
-	}
-
-	baseQuery := `SELECT id, user_id, name, is_file, public, mime, grant_list, created_at FROM documents WHERE user_id = $1`
-	args := []interface{}{targetUserID}
-	argIndex := 2
-
-	if keyFilter != "" && valueFilter != "" && keyFilter == "name" {
-		baseQuery += fmt.Sprintf(" AND name ILIKE $%d", argIndex)
-		args = append(args, "%"+valueFilter+"%")
-		argIndex++
-	}
-
-	baseQuery += " ORDER BY name ASC, created_at DESC"
-
-	if limit > 0 {
-		baseQuery += fmt.Sprintf(" LIMIT $%d", argIndex)
-		args = append(args, limit)
-	}
-
-	rows, err := r.db.Query(ctx, baseQuery, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var docs []*entity.Document
-	for rows.Next() {
-		doc := &entity.Document{}
-		err := rows.Scan(&doc.ID, &doc.UserID, &doc.Name, &doc.IsFile, &doc.Public, &doc.Mime, &doc.Grant, &doc.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		docs = append(docs, doc)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return docs, nil
-}
-
-func (r *DocRepository) Delete(ctx context.Context, id string) error {
-	query := `DELETE FROM documents WHERE id = $1`
-	result, err := r.db.Exec(ctx, query, id)
-	if err != nil {
-		return err
-	}
-	if result.RowsAffected() == 0 {
-		return errors.ErrDocNotFound
-	}
-	return nil
-}
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+)
+
+// docColumns общий список колонок документа, порядок соответствует scanDoc
+const docColumns = `d.id, d.user_id, d.name, d.is_file, d.public, d.mime, d.folder_id, d.attributes, d.text_status, d.text_error, d.thumbnail_status, d.scan_status, d.size, COALESCE(d.content_hash, '') AS content_hash, d.expires_at, d.legal_hold, d.created_at, d.updated_at`
+
+type DocRepository struct {
+	db    *pgxpool.Pool
+	keys  *envelope.Keyring
+	quota entity.Quota
+	// indexEncrypted - индексировать содержимое зашифрованных документов. Индекс и сниппеты
+	// строятся по открытому тексту в Postgres, поэтому по умолчанию выключено.
+	indexEncrypted bool
+}
+
+func NewDocRepository(db *pgxpool.Pool, keys *envelope.Keyring, quota entity.Quota, indexEncrypted bool) *DocRepository {
+	return &DocRepository{
+		db:             db,
+		keys:           keys,
+		quota:          quota,
+		indexEncrypted: indexEncrypted,
+	}
+}
+
+func (r *DocRepository) Create(ctx context.Context, doc *entity.Document) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	jsonData, err := rawJSON(doc.JSONData)
+	if err != nil {
+		return err
+	}
+	key, err := newDocKey(r.keys)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealJSON(key, jsonData)
+	if err != nil {
+		return err
+	}
+
+	// Документ хранит свою копию ключа содержимого, зашифрованную ключом документа
+	var contentKey []byte
+	if doc.ContentHash != "" {
+		cek, err := storeBlob(ctx, tx, r.keys, doc.ContentHash, doc.FileData)
+		if err != nil {
+			return err
+		}
+		if contentKey, err = key.seal(cek); err != nil {
+			return err
+		}
+	}
+	keyID, wrapped := key.columns()
+	contentText := doc.ContentText
+	textEnc, err := key.seal([]byte(doc.ContentText))
+	if err != nil {
+		return err
+	}
+	if textEnc != nil {
+		contentText = ""
+	}
+	// У зашифрованного документа без индекса ищутся только имя и атрибуты
+	indexJSON, indexText := jsonData, doc.ContentText
+	if key != nil && !r.indexEncrypted {
+		indexJSON, indexText = nil, ""
+	}
+
+	query := `INSERT INTO documents (id, user_id, name, is_file, public, mime, folder_id, attributes, created_at,
+	                                 json_data, content_text, search_lang, text_status, thumbnail_status, size, updated_at,
+	                                 content_hash, json_enc, key_id, wrapped_key, scan_status, expires_at, search_json, search_text,
+	                                 content_key, text_enc)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), COALESCE(NULLIF($12, ''), 'simple')::regconfig,
+	                  COALESCE(NULLIF($13, ''), 'none'), COALESCE(NULLIF($14, ''), 'none'), $15, $9, NULLIF($16, ''),
+	                  $17, $18, $19, COALESCE(NULLIF($20, ''), 'none'), $21,
+	                  jsonb_to_tsvector(COALESCE(NULLIF($12, ''), 'simple')::regconfig, $22::jsonb, '["string"]'),
+	                  to_tsvector(COALESCE(NULLIF($12, ''), 'simple')::regconfig, left(NULLIF($24, ''), 262144)), $23, $25)`
+	// Поисковый индекс считается по открытому содержимому, для зашифрованного - только с indexEncrypted
+	_, err = tx.Exec(ctx, query, doc.ID, doc.UserID, doc.Name, doc.IsFile, doc.Public, doc.Mime, doc.FolderID,
+		attributesOrEmpty(doc.Attributes), doc.CreatedAt, sealed.jsonValue(), contentText, doc.SearchLang, doc.TextStatus, doc.ThumbStatus,
+		doc.Size, doc.ContentHash, sealed.enc, keyID, wrapped, doc.ScanStatus, doc.ExpiresAt, indexJSON, contentKey, indexText, textEnc)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.ErrDocAlreadyExist
+		}
+		if isUndefinedObject(err) {
+			return errors.ErrInvalidSearchLang
+		}
+		return err
+	}
+
+	if contentKey != nil {
+		if err := releaseBlobKey(ctx, tx, doc.ContentHash); err != nil {
+			return err
+		}
+	}
+
+	if err := insertGrants(ctx, tx, doc.ID, doc.Grant); err != nil {
+		return err
+	}
+
+	if err := insertTags(ctx, tx, doc.ID, doc.Tags); err != nil {
+		return err
+	}
+
+	if err := checkQuota(ctx, tx, doc.UserID, doc.Size, r.quota); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *DocRepository) GetByID(ctx context.Context, id string) (*entity.Document, error) {
+	query := `SELECT ` + docColumns + `, d.json_data, d.json_enc, d.key_id, d.wrapped_key
+	          FROM documents d
+	          WHERE d.id = $1 AND ` + liveDocCondition
+	doc := &entity.Document{}
+	sealed := &sealedJSON{}
+	err := scanDoc(r.db.QueryRow(ctx, query, id), doc, sealed.dest()...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrDocNotFound
+		}
+		return nil, err
+	}
+	if err := sealed.openInto(r.keys, doc); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelations(ctx, []*entity.Document{doc}); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// List возвращает страницу документов владельца ownerID, доступных на чтение viewerID (логин viewerLogin),
+// с keyset-пагинацией по ключам сортировки
+func (r *DocRepository) List(ctx context.Context, viewerID, viewerLogin, ownerID string, q *entity.DocListQuery) (*entity.DocPage, error) {
+	f := &queryBuilder{}
+	prefix := ""
+	if ownerID == viewerID {
+		f.where("d.user_id = " + f.arg(ownerID))
+	} else {
+		// $1 и $2 используются в accessibleFoldersCTE и readableDocCondition
+		f.arg(viewerID)
+		f.arg(viewerLogin)
+		prefix = `WITH RECURSIVE ` + accessibleFoldersCTE + ` `
+		f.where("d.user_id = " + f.arg(ownerID))
+		f.where(readableDocCondition)
+	}
+	f.where(liveDocCondition)
+	applyDocFilters(f, q)
+
+	page := q.Page
+	result := &entity.DocPage{Docs: []*entity.Document{}}
+	if page.Count {
+		var total int
+		if err := r.db.QueryRow(ctx, prefix+`SELECT count(*) FROM documents d`+f.whereClause(), f.args...).Scan(&total); err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	attrParam := ""
+	if key, ok := q.Sort.AttrKey(); ok {
+		attrParam = f.arg(key)
+	}
+	keys := docSortKeys(q.Sort, attrParam)
+
+	var cursor *docCursor
+	if page.Cursor != "" {
+		var err error
+		if cursor, err = decodeCursor(page.Cursor, q.Sort, len(keys)); err != nil {
+			return nil, err
+		}
+		if cursor.Prev {
+			keys = reverseKeys(keys)
+		}
+		f.where(keysetCondition(keys, len(f.args)+1))
+		for _, value := range cursor.Values {
+			f.arg(value)
+		}
+	}
+
+	jsonColumn := ""
+	if q.IncludeJSON {
+		included := `NOT d.is_file AND d.size <= ` + f.arg(q.JSONMaxSize)
+		jsonColumn = `, CASE WHEN ` + included + ` THEN d.json_data END, CASE WHEN ` + included + ` THEN d.json_enc END, d.key_id, d.wrapped_key`
+	}
+
+	// Берем на один документ больше, чтобы понять, есть ли следующая страница
+	query := prefix + `SELECT ` + docColumns + jsonColumn + selectKeys(keys) + ` FROM documents d` + f.whereClause() +
+		orderByKeys(keys) + ` LIMIT ` + f.arg(page.Limit+1)
+
+	rows, err := r.db.Query(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions [][]string
+	for rows.Next() {
+		doc := &entity.Document{}
+		sealed := &sealedJSON{}
+		values := make([]string, len(keys))
+		var dest []interface{}
+		if q.IncludeJSON {
+			dest = append(dest, sealed.dest()...)
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := scanDoc(rows, doc, dest...); err != nil {
+			return nil, err
+		}
+		if err := sealed.openInto(r.keys, doc); err != nil {
+			return nil, err
+		}
+		result.Docs = append(result.Docs, doc)
+		positions = append(positions, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	hasMore := len(result.Docs) > page.Limit
+	if hasMore {
+		result.Docs = result.Docs[:page.Limit]
+		positions = positions[:page.Limit]
+	}
+
+	hasNext, hasPrev := hasMore, cursor != nil
+	if cursor != nil && cursor.Prev {
+		reverseDocs(result.Docs, positions)
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if n := len(result.Docs); n > 0 {
+		if hasNext {
+			result.Next = encodeCursor(docCursor{Sort: q.Sort.Field, Desc: q.Sort.Desc, Values: positions[n-1]})
+		}
+		if hasPrev {
+			result.Prev = encodeCursor(docCursor{Sort: q.Sort.Field, Desc: q.Sort.Desc, Values: positions[0], Prev: true})
+		}
+	}
+
+	if err := r.loadRelations(ctx, result.Docs); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// applyDocFilters переводит фильтры списка в условия, которые покрываются индексами documents
+func applyDocFilters(f *queryBuilder, q *entity.DocListQuery) {
+	if q.Name != "" {
+		f.where("d.name ILIKE " + f.arg("%"+q.Name+"%"))
+	}
+
+	if q.Mime != "" {
+		if prefix, ok := strings.CutSuffix(q.Mime, "*"); ok {
+			// Диапазон вместо LIKE, чтобы индекс varchar_pattern_ops использовался и в generic-планах
+			f.where("d.mime ~>=~ " + f.arg(prefix) + " AND d.mime ~<~ " + f.arg(prefixUpperBound(prefix)))
+		} else {
+			f.where("d.mime = " + f.arg(q.Mime))
+		}
+	}
+
+	if q.CreatedAfter != nil {
+		f.where("d.created_at >= " + f.arg(*q.CreatedAfter))
+	}
+	if q.CreatedBefore != nil {
+		f.where("d.created_at < " + f.arg(*q.CreatedBefore))
+	}
+	if q.MinSize != nil {
+		f.where("d.size >= " + f.arg(*q.MinSize))
+	}
+	if q.MaxSize != nil {
+		f.where("d.size <= " + f.arg(*q.MaxSize))
+	}
+	if q.Public != nil {
+		f.where("d.public = " + f.arg(*q.Public))
+	}
+	if q.File != nil {
+		f.where("d.is_file = " + f.arg(*q.File))
+	}
+	if q.GrantedTo != "" {
+		f.where("EXISTS (SELECT 1 FROM document_grants fg WHERE fg.document_id = d.id AND fg.login = " + f.arg(q.GrantedTo) + ")")
+	}
+
+	if len(q.Tags.Tags) > 0 {
+		if q.Tags.MatchAll {
+			f.where(`d.id IN (
+				SELECT document_id FROM document_tags WHERE tag = ANY(` + f.arg(q.Tags.Tags) + `)
+				GROUP BY document_id HAVING count(*) = ` + f.arg(len(q.Tags.Tags)) + `)`)
+		} else {
+			f.where(`EXISTS (
+				SELECT 1 FROM document_tags t WHERE t.document_id = d.id AND t.tag = ANY(` + f.arg(q.Tags.Tags) + `))`)
+		}
+	}
+
+	// Ключи сортируются, чтобы текст запроса был стабильным для кэша подготовленных выражений
+	attrKeys := make([]string, 0, len(q.Attrs))
+	for key := range q.Attrs {
+		attrKeys = append(attrKeys, key)
+	}
+	sort.Strings(attrKeys)
+	for _, key := range attrKeys {
+		f.where("d.attributes ->> " + f.arg(key) + " = " + f.arg(q.Attrs[key]))
+	}
+}
+
+// prefixUpperBound наименьшая строка, большая всех строк с префиксом prefix (побайтово)
+func prefixUpperBound(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
+
+func reverseDocs(docs []*entity.Document, positions [][]string) {
+	for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+		docs[i], docs[j] = docs[j], docs[i]
+		positions[i], positions[j] = positions[j], positions[i]
+	}
+}
+
+// headlineOptions параметры ts_headline для сниппетов поиска
+const headlineOptions = `'StartSel=<b>, StopSel=</b>, MaxFragments=2, MaxWords=20, MinWords=5'`
+
+// Search ищет документы по search_vector среди доступных на чтение userID (логин login).
+// Результаты упорядочены по релевантности, сниппеты строятся только для попавших в выдачу документов.
+// Для зашифрованного содержимого (текст или JSON) сниппет строится отдельным запросом после расшифровки,
+// если его содержимое индексируется, иначе только по имени.
+func (r *DocRepository) Search(ctx context.Context, userID, login string, q entity.SearchQuery) ([]*entity.SearchResult, error) {
+	query := `WITH RECURSIVE ` + accessibleFoldersCTE + `, matched AS (
+	              SELECT ` + docColumns + `, d.content_text, d.text_enc, d.json_data, d.json_enc, d.key_id, d.wrapped_key,
+	                     ts_rank_cd(d.search_vector, q.query) AS rank, q.query
+	              FROM documents d, websearch_to_tsquery($3::regconfig, $4) AS q(query)
+	              WHERE d.search_vector @@ q.query AND ` + readableDocCondition + ` AND ` + liveDocCondition + `
+	              ORDER BY rank DESC, d.created_at DESC
+	              LIMIT $5
+	          )
+	          SELECT id, user_id, name, is_file, public, mime, folder_id, attributes, text_status, text_error, thumbnail_status, scan_status, size, content_hash, expires_at, legal_hold, created_at, updated_at, rank,
+	                 ts_headline($3::regconfig,
+	                             name || ' ' || coalesce(left(content_text, 65536), json_data::text, ''),
+	                             query, ` + headlineOptions + `),
+	                 CASE WHEN content_text IS NULL THEN coalesce(text_enc, CASE WHEN json_data IS NULL THEN json_enc END) END,
+	                 key_id, wrapped_key
+	          FROM matched
+	          ORDER BY rank DESC, created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID, login, q.Lang, q.Query, q.Limit)
+	if err != nil {
+		if isUndefinedObject(err) {
+			return nil, errors.ErrInvalidSearchLang
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*entity.SearchResult{}
+	docs := []*entity.Document{}
+	encrypted := []*sealedJSON{}
+	for rows.Next() {
+		doc := &entity.Document{}
+		res := &entity.SearchResult{Doc: doc}
+		sealed := &sealedJSON{}
+		if err := scanDoc(rows, doc, &res.Rank, &res.Snippet, &sealed.enc, &sealed.keyID, &sealed.wrapped); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+		docs = append(docs, doc)
+		encrypted = append(encrypted, sealed)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i, res := range results {
+		if encrypted[i].enc == nil || !r.indexEncrypted {
+			continue
+		}
+		content, err := encrypted[i].open(r.keys)
+		if err != nil {
+			return nil, err
+		}
+		if res.Snippet, err = r.headline(ctx, q, res.Doc.Name, string(content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.loadRelations(ctx, docs); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ListByFolder возвращает документы папки, для корня (folderID == nil) документы владельца вне папок
+func (r *DocRepository) ListByFolder(ctx context.Context, ownerID string, folderID *string) ([]*entity.Document, error) {
+	if folderID == nil {
+		query := `SELECT ` + docColumns + ` FROM documents d
+		          WHERE d.user_id = $1 AND d.folder_id IS NULL AND ` + liveDocCondition + `
+		          ORDER BY d.name ASC, d.created_at DESC`
+		return r.queryDocs(ctx, query, ownerID)
+	}
+
+	query := `SELECT ` + docColumns + ` FROM documents d
+	          WHERE d.folder_id = $1 AND ` + liveDocCondition + `
+	          ORDER BY d.name ASC, d.created_at DESC`
+	return r.queryDocs(ctx, query, *folderID)
+}
+
+// GetByName ищет документ по имени в папке, при совпадении имен берется самый новый
+func (r *DocRepository) GetByName(ctx context.Context, ownerID string, folderID *string, name string) (*entity.Document, error) {
+	var docs []*entity.Document
+	var err error
+	if folderID == nil {
+		query := `SELECT ` + docColumns + ` FROM documents d
+		          WHERE d.user_id = $1 AND d.folder_id IS NULL AND d.name = $2 AND ` + liveDocCondition + `
+		          ORDER BY d.created_at DESC LIMIT 1`
+		docs, err = r.queryDocs(ctx, query, ownerID, name)
+	} else {
+		query := `SELECT ` + docColumns + ` FROM documents d
+		          WHERE d.folder_id = $1 AND d.name = $2 AND ` + liveDocCondition + `
+		          ORDER BY d.created_at DESC LIMIT 1`
+		docs, err = r.queryDocs(ctx, query, *folderID, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, errors.ErrDocNotFound
+	}
+	return docs[0], nil
+}
+
+func (r *DocRepository) Update(ctx context.Context, doc *entity.Document) error {
+	query := `UPDATE documents SET name = $2, public = $3, mime = $4, folder_id = $5, attributes = $6, updated_at = now()
+	          WHERE id = $1
+	          RETURNING updated_at`
+	err := r.db.QueryRow(ctx, query, doc.ID, doc.Name, doc.Public, doc.Mime, doc.FolderID, attributesOrEmpty(doc.Attributes)).
+		Scan(&doc.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return errors.ErrDocNotFound
+		}
+		return retentionError(err)
+	}
+	return nil
+}
+
+// SetTags полностью заменяет теги документа. Новый набор не должен выводить документ
+// из-под действующего правила хранения.
+func (r *DocRepository) SetTags(ctx context.Context, docID string, tags []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := touchDoc(ctx, tx, docID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT check_document_tags_retention($1, $2)`, docID, tags); err != nil {
+		return retentionError(err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM document_tags WHERE document_id = $1`, docID); err != nil {
+		return err
+	}
+
+	if err := insertTags(ctx, tx, docID, tags); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// TagFacets считает документы пользователя ownerID по тегам. Учитываются только документы,
+// доступные на чтение userID (логин login), и, если задан filter, подходящие под него.
+func (r *DocRepository) TagFacets(ctx context.Context, userID, login, ownerID string, filter entity.TagFilter) ([]entity.TagCount, error) {
+	query := `WITH RECURSIVE ` + accessibleFoldersCTE + `
+	          SELECT t.tag, count(*) FROM document_tags t
+	          JOIN documents d ON d.id = t.document_id
+	          WHERE d.user_id = $3 AND ` + readableDocCondition + ` AND ` + liveDocCondition
+	args := []interface{}{userID, login, ownerID}
+
+	if len(filter.Tags) > 0 {
+		if filter.MatchAll {
+			query += ` AND d.id IN (
+				SELECT document_id FROM document_tags WHERE tag = ANY($4)
+				GROUP BY document_id HAVING count(*) = $5)`
+			args = append(args, filter.Tags, len(filter.Tags))
+		} else {
+			query += ` AND EXISTS (
+				SELECT 1 FROM document_tags ft WHERE ft.document_id = d.id AND ft.tag = ANY($4))`
+			args = append(args, filter.Tags)
+		}
+	}
+
+	query += ` GROUP BY t.tag ORDER BY count(*) DESC, t.tag ASC`
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := []entity.TagCount{}
+	for rows.Next() {
+		var tc entity.TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, err
+		}
+		facets = append(facets, tc)
+	}
+
+	return facets, rows.Err()
+}
+
+// SetGrants полностью заменяет список грантов документа
+func (r *DocRepository) SetGrants(ctx context.Context, docID string, grants []entity.Grant) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := touchDoc(ctx, tx, docID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM document_grants WHERE document_id = $1`, docID); err != nil {
+		return err
+	}
+
+	if err := insertGrants(ctx, tx, docID, grants); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Transfer меняет владельца документа и пишет запись в журнал. Пустой actorID означает администратора.
+// Документ переносится в корень нового владельца и учитывается в квотах получателя.
+func (r *DocRepository) Transfer(ctx context.Context, docID, fromUserID, toUserID, actorID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `UPDATE documents SET user_id = $2, folder_id = NULL, updated_at = now() WHERE id = $1 AND user_id = $3`, docID, toUserID, fromUserID)
+	if err != nil {
+		return retentionError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrDocNotFound
+	}
+
+	// Администратор переносит документы без учета квот получателя
+	if actorID != "" {
+		var size int64
+		if err := tx.QueryRow(ctx, `SELECT size FROM documents WHERE id = $1`, docID).Scan(&size); err != nil {
+			return err
+		}
+		if err := checkQuota(ctx, tx, toUserID, size, r.quota); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO document_audit (document_id, actor_id, action, from_user_id, to_user_id)
+	          VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5)`
+	if _, err := tx.Exec(ctx, query, docID, actorID, entity.AuditActionTransfer, fromUserID, toUserID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// TransferAll передает все документы и папки пользователя другому и возвращает ID перенесенных документов.
+// Структура папок сохраняется, корневые папки с совпадающими именами получают суффикс suffix.
+func (r *DocRepository) TransferAll(ctx context.Context, fromUserID, toUserID, actorID, suffix string) ([]string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	folderQuery := `UPDATE folders f
+	                SET user_id = $2,
+	                    name = CASE WHEN f.parent_id IS NULL AND EXISTS (
+	                               SELECT 1 FROM folders t
+	                               WHERE t.user_id = $2 AND t.parent_id IS NULL AND t.name = f.name
+	                           ) THEN f.name || ' (' || $3 || ')' ELSE f.name END
+	                WHERE f.user_id = $1`
+	if _, err := tx.Exec(ctx, folderQuery, fromUserID, toUserID, suffix); err != nil {
+		return nil, err
+	}
+
+	query := `WITH moved AS (
+	              UPDATE documents SET user_id = $2, updated_at = now() WHERE user_id = $1 RETURNING id
+	          ), audit AS (
+	              INSERT INTO document_audit (document_id, actor_id, action, from_user_id, to_user_id)
+	              SELECT id, NULLIF($3, '')::uuid, $4, $1, $2 FROM moved
+	          )
+	          SELECT id FROM moved`
+	rows, err := tx.Query(ctx, query, fromUserID, toUserID, actorID, entity.AuditActionTransfer)
+	if err != nil {
+		return nil, retentionError(err)
+	}
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, retentionError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// headline строит сниппет по расшифрованному содержимому так же, как Search для открытого
+func (r *DocRepository) headline(ctx context.Context, q entity.SearchQuery, name, content string) (string, error) {
+	query := `SELECT ts_headline($1::regconfig, $2 || ' ' || left($3, 65536), websearch_to_tsquery($1::regconfig, $4), ` + headlineOptions + `)`
+	var snippet string
+	err := r.db.QueryRow(ctx, query, q.Lang, name, content, q.Query).Scan(&snippet)
+	return snippet, err
+}
+
+// GetText возвращает извлеченный текст файла и статус извлечения
+func (r *DocRepository) GetText(ctx context.Context, docID string) (*entity.DocText, error) {
+	query := `SELECT id, text_status, text_error, COALESCE(content_text, ''), text_extracted_at, text_enc, key_id, wrapped_key
+	          FROM documents WHERE id = $1`
+	text := &entity.DocText{}
+	sealed := &sealedJSON{}
+	err := r.db.QueryRow(ctx, query, docID).
+		Scan(&text.DocumentID, &text.Status, &text.Error, &text.Text, &text.ExtractedAt, &sealed.enc, &sealed.keyID, &sealed.wrapped)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrDocNotFound
+		}
+		return nil, err
+	}
+	if sealed.enc != nil {
+		plain, err := sealed.open(r.keys)
+		if err != nil {
+			return nil, err
+		}
+		text.Text = string(plain)
+	}
+	return text, nil
+}
+
+// SetText сохраняет результат извлечения текста вместе с его частью поискового индекса.
+// У документа с ключом данных текст хранится зашифрованным в text_enc и индексируется,
+// только если включен indexEncrypted.
+func (r *DocRepository) SetText(ctx context.Context, docID, status, text, errMsg string) error {
+	var keyID *string
+	var wrapped []byte
+	err := r.db.QueryRow(ctx, `SELECT key_id, wrapped_key FROM documents WHERE id = $1`, docID).Scan(&keyID, &wrapped)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return errors.ErrDocNotFound
+		}
+		return err
+	}
+	key, err := openDocKey(r.keys, keyID, wrapped)
+	if err != nil {
+		return err
+	}
+	var enc []byte
+	plain, indexed := text, text
+	if text != "" {
+		if enc, err = key.seal([]byte(text)); err != nil {
+			return err
+		}
+		if enc != nil {
+			plain = ""
+			if !r.indexEncrypted {
+				indexed = ""
+			}
+		}
+	}
+
+	query := `UPDATE documents
+	          SET text_status = $2, content_text = NULLIF($3, ''), text_enc = $5, text_error = $4, text_extracted_at = now(),
+	              search_text = to_tsvector(search_lang, left(NULLIF($6, ''), 262144))
+	          WHERE id = $1`
+	result, err := r.db.Exec(ctx, query, docID, status, plain, errMsg, enc, indexed)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrDocNotFound
+	}
+	return nil
+}
+
+func (r *DocRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM documents WHERE id = $1`
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return retentionError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrDocNotFound
+	}
+	return nil
+}
+
+// DeleteExpired удаляет до limit документов с истекшим сроком жизни и возвращает их ID и владельцев.
+// Документы под legal hold или правилом хранения пропускаются и остаются скрытыми до снятия ограничения.
+// Содержимое освобождается счетчиком ссылок блобов, миниатюры удаляются каскадно.
+func (r *DocRepository) DeleteExpired(ctx context.Context, limit int) ([]*entity.Document, error) {
+	query := `DELETE FROM documents WHERE id IN (
+	              SELECT id FROM documents
+	              WHERE expires_at IS NOT NULL AND expires_at <= now() AND NOT legal_hold
+	                AND document_retained_until(id, user_id, folder_id, mime) IS NULL
+	              ORDER BY expires_at
+	              LIMIT $1
+	              FOR UPDATE SKIP LOCKED
+	          )
+	          RETURNING id, user_id`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []*entity.Document{}
+	for rows.Next() {
+		doc := &entity.Document{}
+		if err := rows.Scan(&doc.ID, &doc.UserID); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// scanDoc читает docColumns в doc, extra получают дополнительные колонки, идущие следом
+func scanDoc(row pgx.Row, doc *entity.Document, extra ...interface{}) error {
+	dest := []interface{}{&doc.ID, &doc.UserID, &doc.Name, &doc.IsFile, &doc.Public, &doc.Mime, &doc.FolderID,
+		&doc.Attributes, &doc.TextStatus, &doc.TextError, &doc.ThumbStatus, &doc.ScanStatus, &doc.Size, &doc.ContentHash, &doc.ExpiresAt, &doc.LegalHold, &doc.CreatedAt, &doc.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// rawJSON приводит содержимое JSON-документа к виду для колонки jsonb, пустое содержимое хранится как NULL
+func rawJSON(data interface{}) (json.RawMessage, error) {
+	switch v := data.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		return v, nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+func attributesOrEmpty(attrs map[string]interface{}) map[string]interface{} {
+	if attrs == nil {
+		return map[string]interface{}{}
+	}
+	return attrs
+}
+
+// queryDocs выполняет запрос, выбирающий docColumns, и подгружает гранты
+func (r *DocRepository) queryDocs(ctx context.Context, query string, args ...interface{}) ([]*entity.Document, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []*entity.Document
+	for rows.Next() {
+		doc := &entity.Document{}
+		if err := scanDoc(rows, doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelations(ctx, docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// touchDoc обновляет updated_at документа, ErrDocNotFound если документа нет
+func touchDoc(ctx context.Context, tx pgx.Tx, docID string) error {
+	result, err := tx.Exec(ctx, `UPDATE documents SET updated_at = now() WHERE id = $1`, docID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrDocNotFound
+	}
+	return nil
+}
+
+func insertGrants(ctx context.Context, tx pgx.Tx, docID string, grants []entity.Grant) error {
+	query := `INSERT INTO document_grants (document_id, login, permission) VALUES ($1, $2, $3)
+	          ON CONFLICT (document_id, login) DO UPDATE SET permission = EXCLUDED.permission`
+	for _, g := range grants {
+		if _, err := tx.Exec(ctx, query, docID, g.Login, g.Permission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertTags(ctx context.Context, tx pgx.Tx, docID string, tags []string) error {
+	query := `INSERT INTO document_tags (document_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	for _, tag := range tags {
+		if _, err := tx.Exec(ctx, query, docID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRelations подгружает гранты и теги документов
+func (r *DocRepository) loadRelations(ctx context.Context, docs []*entity.Document) error {
+	if err := r.loadGrants(ctx, docs); err != nil {
+		return err
+	}
+	return r.loadTags(ctx, docs)
+}
+
+func (r *DocRepository) loadTags(ctx context.Context, docs []*entity.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(docs))
+	byID := make(map[string]*entity.Document, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+		byID[doc.ID] = doc
+	}
+
+	query := `SELECT document_id, tag FROM document_tags
+	          WHERE document_id = ANY($1::uuid[])
+	          ORDER BY tag`
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var docID, tag string
+		if err := rows.Scan(&docID, &tag); err != nil {
+			return err
+		}
+		if doc, ok := byID[docID]; ok {
+			doc.Tags = append(doc.Tags, tag)
+		}
+	}
+
+	return rows.Err()
+}
+
+// loadGrants подгружает гранты для набора документов одним запросом
+func (r *DocRepository) loadGrants(ctx context.Context, docs []*entity.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(docs))
+	byID := make(map[string]*entity.Document, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+		byID[doc.ID] = doc
+	}
+
+	query := `SELECT document_id, login, permission FROM document_grants
+	          WHERE document_id = ANY($1::uuid[])
+	          ORDER BY login`
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var docID string
+		var g entity.Grant
+		if err := rows.Scan(&docID, &g.Login, &g.Permission); err != nil {
+			return err
+		}
+		if doc, ok := byID[docID]; ok {
+			doc.Grant = append(doc.Grant, g)
+		}
+	}
+
+	return rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return stderrors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// retentionError заменяет ошибки триггера documents_retention на ErrLegalHold и ErrRetentionActive
+func retentionError(err error) error {
+	var pgErr *pgconn.PgError
+	if stderrors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "DSH01":
+			return errors.ErrLegalHold
+		case "DSR01":
+			return errors.ErrRetentionActive
+		}
+	}
+	return err
+}
+
+// isUndefinedObject ошибка приведения к несуществующему объекту, например неизвестной конфигурации поиска
+func isUndefinedObject(err error) bool {
+	var pgErr *pgconn.PgError
+	return stderrors.As(err, &pgErr) && pgErr.Code == "42704"
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+const folderColumns = `f.id, f.user_id, f.parent_id, f.name, f.created_at`
+
+type FolderRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFolderRepository(db *pgxpool.Pool) *FolderRepository {
+	return &FolderRepository{db: db}
+}
+
+func (r *FolderRepository) Create(ctx context.Context, folder *entity.Folder) error {
+	query := `INSERT INTO folders (id, user_id, parent_id, name, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(ctx, query, folder.ID, folder.UserID, folder.ParentID, folder.Name, folder.CreatedAt)
+	if err != nil && isUniqueViolation(err) {
+		return errors.ErrFolderAlreadyExist
+	}
+	return err
+}
+
+func (r *FolderRepository) GetByID(ctx context.Context, id string) (*entity.Folder, error) {
+	query := `SELECT ` + folderColumns + ` FROM folders f WHERE f.id = $1`
+	folder := &entity.Folder{}
+	err := scanFolder(r.db.QueryRow(ctx, query, id), folder)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrFolderNotFound
+		}
+		return nil, err
+	}
+
+	if err := r.loadGrants(ctx, []*entity.Folder{folder}); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// GetByName ищет папку по имени у родителя, parentID == nil означает корень владельца
+func (r *FolderRepository) GetByName(ctx context.Context, ownerID string, parentID *string, name string) (*entity.Folder, error) {
+	query := `SELECT ` + folderColumns + ` FROM folders f
+	          WHERE f.user_id = $1 AND f.parent_id IS NOT DISTINCT FROM $2 AND f.name = $3`
+	folder := &entity.Folder{}
+	err := scanFolder(r.db.QueryRow(ctx, query, ownerID, parentID, name), folder)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrFolderNotFound
+		}
+		return nil, err
+	}
+
+	if err := r.loadGrants(ctx, []*entity.Folder{folder}); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+func (r *FolderRepository) ListChildren(ctx context.Context, ownerID string, parentID *string) ([]*entity.Folder, error) {
+	query := `SELECT ` + folderColumns + ` FROM folders f
+	          WHERE f.user_id = $1 AND f.parent_id IS NOT DISTINCT FROM $2
+	          ORDER BY f.name ASC`
+	rows, err := r.db.Query(ctx, query, ownerID, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []*entity.Folder{}
+	for rows.Next() {
+		folder := &entity.Folder{}
+		if err := scanFolder(rows, folder); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadGrants(ctx, folders); err != nil {
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+func (r *FolderRepository) Update(ctx context.Context, folder *entity.Folder) error {
+	query := `UPDATE folders SET name = $2, parent_id = $3 WHERE id = $1`
+	result, err := r.db.Exec(ctx, query, folder.ID, folder.Name, folder.ParentID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.ErrFolderAlreadyExist
+		}
+		return retentionError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrFolderNotFound
+	}
+	return nil
+}
+
+// IsDescendant проверяет, что candidateID совпадает с folderID или лежит внутри него
+func (r *FolderRepository) IsDescendant(ctx context.Context, folderID, candidateID string) (bool, error) {
+	query := `WITH RECURSIVE tree AS (
+	              SELECT id FROM folders WHERE id = $1
+	              UNION
+	              SELECT f.id FROM folders f JOIN tree t ON f.parent_id = t.id
+	          )
+	          SELECT EXISTS (SELECT 1 FROM tree WHERE id = $2)`
+	var found bool
+	err := r.db.QueryRow(ctx, query, folderID, candidateID).Scan(&found)
+	return found, err
+}
+
+// Delete удаляет папку со всем содержимым и возвращает затронутые документы (ID и владельца).
+// Удаляются только документы владельца папки, документы других пользователей переносятся
+// в корень их владельцев.
+func (r *FolderRepository) Delete(ctx context.Context, id string) ([]*entity.Document, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tree := `WITH RECURSIVE tree AS (
+	             SELECT id, user_id FROM folders WHERE id = $1
+	             UNION
+	             SELECT f.id, f.user_id FROM folders f JOIN tree t ON f.parent_id = t.id
+	         )`
+
+	moved, err := queryDocOwners(ctx, tx, tree+`
+	    UPDATE documents d SET folder_id = NULL, updated_at = now()
+	    FROM tree t WHERE d.folder_id = t.id AND d.user_id <> t.user_id
+	    RETURNING d.id, d.user_id`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Документы удаляются до папок: правила хранения по папке проверяются, пока дерево папок на месте.
+	// Вложенные папки удаляются каскадно.
+	docs, err := queryDocOwners(ctx, tx, tree+`
+	    DELETE FROM documents d WHERE d.folder_id IN (SELECT id FROM tree) RETURNING d.id, d.user_id`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM folders WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errors.ErrFolderNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return append(docs, moved...), nil
+}
+
+// queryDocOwners выполняет запрос, возвращающий id и user_id документов
+func queryDocOwners(ctx context.Context, tx pgx.Tx, query string, args ...interface{}) ([]*entity.Document, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, retentionError(err)
+	}
+	defer rows.Close()
+
+	docs := []*entity.Document{}
+	for rows.Next() {
+		doc := &entity.Document{}
+		if err := rows.Scan(&doc.ID, &doc.UserID); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, retentionError(err)
+	}
+	return docs, nil
+}
+
+func (r *FolderRepository) SetGrants(ctx context.Context, folderID string, grants []entity.Grant) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM folder_grants WHERE folder_id = $1`, folderID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO folder_grants (folder_id, login, permission) VALUES ($1, $2, $3)
+	          ON CONFLICT (folder_id, login) DO UPDATE SET permission = EXCLUDED.permission`
+	for _, g := range grants {
+		if _, err := tx.Exec(ctx, query, folderID, g.Login, g.Permission); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Permissions вычисляет право пользователя на каждую из папок с учетом грантов родительских папок.
+// Владелец дерева папок получает право owner. OwnerID заполняется для всех найденных папок.
+func (r *FolderRepository) Permissions(ctx context.Context, folderIDs []string, userID, login string) (map[string]entity.FolderAccess, error) {
+	result := make(map[string]entity.FolderAccess, len(folderIDs))
+	if len(folderIDs) == 0 {
+		return result, nil
+	}
+
+	query := `WITH RECURSIVE chain AS (
+	              SELECT f.id AS start_id, f.id, f.parent_id, f.user_id FROM folders f WHERE f.id = ANY($1::uuid[])
+	              UNION ALL
+	              SELECT c.start_id, p.id, p.parent_id, p.user_id FROM folders p JOIN chain c ON p.id = c.parent_id
+	          )
+	          SELECT c.start_id, c.user_id::text, CASE WHEN c.user_id = $2 THEN 'owner' ELSE '' END
+	          FROM chain c WHERE c.id = c.start_id
+	          UNION ALL
+	          SELECT c.start_id, '', g.permission FROM chain c JOIN folder_grants g ON g.folder_id = c.id
+	          WHERE g.login = $3`
+	rows, err := r.db.Query(ctx, query, folderIDs, userID, login)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var folderID, ownerID string
+		var perm entity.Permission
+		if err := rows.Scan(&folderID, &ownerID, &perm); err != nil {
+			return nil, err
+		}
+		access := result[folderID]
+		if ownerID != "" {
+			access.OwnerID = ownerID
+		}
+		if perm.Level() > access.Permission.Level() {
+			access.Permission = perm
+		}
+		result[folderID] = access
+	}
+
+	return result, rows.Err()
+}
+
+func scanFolder(row pgx.Row, folder *entity.Folder) error {
+	return row.Scan(&folder.ID, &folder.UserID, &folder.ParentID, &folder.Name, &folder.CreatedAt)
+}
+
+func (r *FolderRepository) loadGrants(ctx context.Context, folders []*entity.Folder) error {
+	if len(folders) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(folders))
+	byID := make(map[string]*entity.Folder, len(folders))
+	for i, folder := range folders {
+		ids[i] = folder.ID
+		byID[folder.ID] = folder
+	}
+
+	query := `SELECT folder_id, login, permission FROM folder_grants
+	          WHERE folder_id = ANY($1::uuid[])
+	          ORDER BY login`
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var folderID string
+		var g entity.Grant
+		if err := rows.Scan(&folderID, &g.Login, &g.Permission); err != nil {
+			return err
+		}
+		if folder, ok := byID[folderID]; ok {
+			folder.Grant = append(folder.Grant, g)
+		}
+	}
+
+	return rows.Err()
+}
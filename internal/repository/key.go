@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+)
+
+// KeyRepository читает и обновляет обернутые ключи данных. Зашифрованное содержимое
+// при этом не читается, поэтому ротация не зависит от размера файлов. Отдельно
+// SealDoc и SealBlob шифруют данные, записанные открытыми или до ключей документов.
+type KeyRepository struct {
+	db   *pgxpool.Pool
+	keys *envelope.Keyring
+	// indexEncrypted - зашифрованные документы сохраняют поисковый индекс по содержимому
+	indexEncrypted bool
+}
+
+func NewKeyRepository(db *pgxpool.Pool, keys *envelope.Keyring, indexEncrypted bool) *KeyRepository {
+	return &KeyRepository{db: db, keys: keys, indexEncrypted: indexEncrypted}
+}
+
+// keyTable таблица и ключевая колонка владельца ключа
+func keyTable(owner string) (string, string, error) {
+	switch owner {
+	case entity.KeyOwnerBlob:
+		return "blobs", "hash", nil
+	case entity.KeyOwnerDocument:
+		return "documents", "id::text", nil
+	default:
+		return "", "", fmt.Errorf("unknown key owner %q", owner)
+	}
+}
+
+// Stale возвращает до limit ключей данных владельца owner, обернутых не мастер-ключом activeID,
+// с идентификатором больше after в порядке идентификатора
+func (r *KeyRepository) Stale(ctx context.Context, owner, activeID, after string, limit int) ([]*entity.WrappedKey, error) {
+	table, id, err := keyTable(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + id + `, key_id, wrapped_key FROM ` + table + `
+	          WHERE key_id IS NOT NULL AND key_id <> $1 AND ` + id + ` > $2
+	          ORDER BY ` + id + ` LIMIT $3`
+	rows, err := r.db.Query(ctx, query, activeID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*entity.WrappedKey{}
+	for rows.Next() {
+		key := &entity.WrappedKey{Owner: owner}
+		if err := rows.Scan(&key.ID, &key.KeyID, &key.Wrapped); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Replace заменяет обернутый ключ, если он не изменился с момента чтения. Возвращает false,
+// если строку успели удалить или перезаписать.
+func (r *KeyRepository) Replace(ctx context.Context, key *entity.WrappedKey, keyID string, wrapped []byte) (bool, error) {
+	table, id, err := keyTable(key.Owner)
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE ` + table + ` SET key_id = $2, wrapped_key = $3
+	          WHERE ` + id + ` = $1 AND key_id = $4 AND wrapped_key = $5`
+	result, err := r.db.Exec(ctx, query, key.ID, keyID, wrapped, key.KeyID, key.Wrapped)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// plainDocCondition документ с данными, не зашифрованными его ключом: нет ключа, открытые JSON, текст
+// или превью, ключ содержимого файла остался только у блоба. Без indexEncrypted сюда же попадают
+// документы с открытым поисковым индексом по содержимому, с ним - JSON, зашифрованный до
+// отдельного индекса.
+func (r *KeyRepository) plainDocCondition() string {
+	index := `d.search_json IS NOT NULL OR d.search_text IS NOT NULL`
+	if r.indexEncrypted {
+		index = `d.json_enc IS NOT NULL AND d.search_json IS NULL`
+	}
+	return `(d.key_id IS NULL OR d.json_data IS NOT NULL OR d.content_text IS NOT NULL OR (` + index + `)
+	  OR EXISTS (SELECT 1 FROM document_thumbnails t WHERE t.document_id = d.id AND NOT t.encrypted)
+	  OR (d.content_key IS NULL AND EXISTS (SELECT 1 FROM blobs b WHERE b.hash = d.content_hash AND b.key_id IS NOT NULL)))`
+}
+
+// PlainDocs возвращает до limit документов с ID больше after, данные которых нужно зашифровать
+func (r *KeyRepository) PlainDocs(ctx context.Context, after string, limit int) ([]string, error) {
+	query := `SELECT d.id::text FROM documents d
+	          WHERE d.id::text > $1 AND ` + r.plainDocCondition() + `
+	          ORDER BY d.id::text LIMIT $2`
+	return r.queryIDs(ctx, query, after, limit)
+}
+
+// SealDoc шифрует открытые данные документа его ключом, создавая ключ при необходимости,
+// и сохраняет копию ключа содержимого файла. Без indexEncrypted поисковый индекс по содержимому
+// очищается, с ним индекс JSON, которого нет у документов, зашифрованных раньше, считается
+// по расшифрованным данным. Возвращает false, если документ удален.
+func (r *KeyRepository) SealDoc(ctx context.Context, id string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var keyID, contentText, contentHash *string
+	var wrapped, jsonData, jsonEnc []byte
+	var noSearchJSON, noContentKey bool
+	err = tx.QueryRow(ctx, `SELECT key_id, wrapped_key, json_data, json_enc, content_text, search_json IS NULL,
+	                               content_hash, content_key IS NULL
+	                        FROM documents WHERE id = $1 FOR UPDATE`, id).
+		Scan(&keyID, &wrapped, &jsonData, &jsonEnc, &contentText, &noSearchJSON, &contentHash, &noContentKey)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	key, err := openDocKey(r.keys, keyID, wrapped)
+	if err != nil {
+		return false, err
+	}
+	if key == nil {
+		if key, err = newDocKey(r.keys); err != nil {
+			return false, err
+		}
+	}
+
+	// Открытый JSON нужен только для поискового индекса
+	plainJSON := jsonData
+	if jsonData != nil {
+		if jsonEnc, err = key.seal(jsonData); err != nil {
+			return false, err
+		}
+	} else if jsonEnc != nil && noSearchJSON && r.indexEncrypted {
+		if plainJSON, err = key.open(jsonEnc); err != nil {
+			return false, err
+		}
+	}
+
+	var textEnc []byte
+	if contentText != nil {
+		if textEnc, err = key.seal([]byte(*contentText)); err != nil {
+			return false, err
+		}
+	}
+
+	var contentKey []byte
+	if contentHash != nil && noContentKey {
+		// У открытого блоба ключа нет, его зашифрует SealBlob
+		cek, err := blobContentKey(ctx, tx, r.keys, *contentHash)
+		if err != nil && err != errContentKeyLost {
+			return false, err
+		}
+		if contentKey, err = key.seal(cek); err != nil {
+			return false, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE documents
+	                       SET key_id = $2, wrapped_key = $3, json_data = NULL, json_enc = $4, content_text = NULL,
+	                           text_enc = COALESCE($5, text_enc), content_key = COALESCE(content_key, $6),
+	                           search_json = CASE WHEN $8 THEN COALESCE(search_json, jsonb_to_tsvector(search_lang, $7::jsonb, '["string"]')) END,
+	                           search_text = CASE WHEN $8 THEN search_text END
+	                       WHERE id = $1`, id, key.keyID, key.wrapped, jsonEnc, textEnc, contentKey, plainJSON, r.indexEncrypted)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.sealThumbnails(ctx, tx, id, key); err != nil {
+		return false, err
+	}
+
+	if contentKey != nil {
+		if err := releaseBlobKey(ctx, tx, *contentHash); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// sealThumbnails шифрует открытые превью документа
+func (r *KeyRepository) sealThumbnails(ctx context.Context, tx pgx.Tx, docID string, key *docKey) error {
+	rows, err := tx.Query(ctx, `SELECT size, data FROM document_thumbnails WHERE document_id = $1 AND NOT encrypted`, docID)
+	if err != nil {
+		return err
+	}
+	sizes, thumbs := []int{}, [][]byte{}
+	for rows.Next() {
+		var size int
+		var data []byte
+		if err := rows.Scan(&size, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		sizes, thumbs = append(sizes, size), append(thumbs, data)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, size := range sizes {
+		enc, err := key.seal(thumbs[i])
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `UPDATE document_thumbnails SET data = $3, encrypted = true
+		                       WHERE document_id = $1 AND size = $2`, docID, size, enc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlainBlobs возвращает до limit открытых блобов с хэшем больше after, на которые ссылаются документы.
+// Блобы в карантине пропускаются: их содержимое не совпадает с хэшем.
+func (r *KeyRepository) PlainBlobs(ctx context.Context, after string, limit int) ([]string, error) {
+	query := `SELECT hash FROM blobs
+	          WHERE hash > $1 AND NOT sealed AND key_id IS NULL AND ref_count > 0 AND corrupt_at IS NULL
+	          ORDER BY hash LIMIT $2`
+	return r.queryIDs(ctx, query, after, limit)
+}
+
+// SealBlob шифрует открытое содержимое блоба новым ключом содержимого и раздает копии ключа
+// всем ссылающимся документам. Содержимое переписывается потоком в новый large object,
+// хэш проверяется по ходу записи. Возвращает false, если блоб уже зашифрован или удален,
+// у ссылающегося документа еще нет ключа, набор документов изменился или содержимое
+// не совпало с хэшем (такой блоб найдет scrub).
+func (r *KeyRepository) SealBlob(ctx context.Context, hash string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Документы блокируются раньше блоба, в том же порядке, что и при удалении документа
+	rows, err := tx.Query(ctx, `SELECT id::text, key_id, wrapped_key FROM documents
+	                            WHERE content_hash = $1 ORDER BY id FOR UPDATE`, hash)
+	if err != nil {
+		return false, err
+	}
+	docIDs, docKeys := []string{}, []*docKey{}
+	for rows.Next() {
+		var id string
+		var keyID *string
+		var wrapped []byte
+		if err := rows.Scan(&id, &keyID, &wrapped); err != nil {
+			rows.Close()
+			return false, err
+		}
+		key, err := openDocKey(r.keys, keyID, wrapped)
+		if err != nil {
+			rows.Close()
+			return false, err
+		}
+		docIDs, docKeys = append(docIDs, id), append(docKeys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	for _, key := range docKeys {
+		if key == nil {
+			return false, nil
+		}
+	}
+	if len(docIDs) == 0 {
+		return false, nil
+	}
+
+	var data []byte
+	var lo *uint32
+	err = tx.QueryRow(ctx, `SELECT data, lo FROM blobs
+	                        WHERE hash = $1 AND NOT sealed AND key_id IS NULL AND corrupt_at IS NULL
+	                        FOR UPDATE`, hash).Scan(&data, &lo)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	// Документ, вставленный пока блоб ждал блокировки, не получил бы копию ключа
+	var refs int
+	if err := tx.QueryRow(ctx, `SELECT count(*) FROM documents WHERE content_hash = $1`, hash).Scan(&refs); err != nil {
+		return false, err
+	}
+	if refs != len(docIDs) {
+		return false, nil
+	}
+
+	objects := tx.LargeObjects()
+	var src io.Reader = bytes.NewReader(data)
+	if lo != nil {
+		obj, err := objects.Open(ctx, *lo, pgx.LargeObjectModeRead)
+		if err != nil {
+			return false, err
+		}
+		defer obj.Close()
+		src = obj
+	}
+
+	oid, err := objects.Create(ctx, 0)
+	if err != nil {
+		return false, err
+	}
+	obj, err := objects.Open(ctx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return false, err
+	}
+	_, cek, _, err := r.keys.NewKey()
+	if err != nil {
+		return false, err
+	}
+	buf := bufio.NewWriterSize(obj, loWriteSize)
+	enc, err := envelope.NewWriter(buf, cek)
+	if err != nil {
+		return false, err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(enc, io.TeeReader(bufio.NewReaderSize(src, loWriteSize), hasher)); err != nil {
+		return false, err
+	}
+	if err := enc.Close(); err != nil {
+		return false, err
+	}
+	if err := buf.Flush(); err != nil {
+		return false, err
+	}
+	if err := obj.Close(); err != nil {
+		return false, err
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != hash {
+		return false, nil
+	}
+
+	// Старый large object удаляет триггер blobs_unlink
+	if _, err := tx.Exec(ctx, `UPDATE blobs SET data = NULL, lo = $2, sealed = true WHERE hash = $1`, hash, oid); err != nil {
+		return false, err
+	}
+	for i, id := range docIDs {
+		contentKey, err := docKeys[i].seal(cek)
+		if err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE documents SET content_key = $2 WHERE id = $1`, id, contentKey); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// queryIDs выполняет запрос, возвращающий одну текстовую колонку
+func (r *KeyRepository) queryIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+type ShareLinkRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewShareLinkRepository(db *pgxpool.Pool) *ShareLinkRepository {
+	return &ShareLinkRepository{db: db}
+}
+
+func (r *ShareLinkRepository) Create(ctx context.Context, link *entity.ShareLink) error {
+	query := `INSERT INTO share_links (id, document_id, created_by, token_hash, password_hash, expires_at, max_downloads, created_at)
+	          VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)`
+	_, err := r.db.Exec(ctx, query, link.ID, link.DocumentID, link.CreatedBy, link.TokenHash,
+		link.PasswordHash, link.ExpiresAt, link.MaxDownloads, link.CreatedAt)
+	return err
+}
+
+func (r *ShareLinkRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*entity.ShareLink, error) {
+	query := `SELECT id, document_id, created_by, token_hash, COALESCE(password_hash, ''), expires_at,
+	                 max_downloads, revoked_at, created_at
+	          FROM share_links
+	          WHERE token_hash = $1`
+	link := &entity.ShareLink{}
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(
+		&link.ID, &link.DocumentID, &link.CreatedBy, &link.TokenHash, &link.PasswordHash,
+		&link.ExpiresAt, &link.MaxDownloads, &link.RevokedAt, &link.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrShareLinkNotFound
+		}
+		return nil, err
+	}
+	return link, nil
+}
+
+func (r *ShareLinkRepository) ListByDoc(ctx context.Context, docID string) ([]*entity.ShareLink, error) {
+	query := `SELECT id, document_id, created_by, token_hash, COALESCE(password_hash, ''), expires_at,
+	                 max_downloads, revoked_at, created_at
+	          FROM share_links
+	          WHERE document_id = $1
+	          ORDER BY created_at DESC`
+	rows, err := r.db.Query(ctx, query, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []*entity.ShareLink{}
+	for rows.Next() {
+		link := &entity.ShareLink{}
+		err := rows.Scan(
+			&link.ID, &link.DocumentID, &link.CreatedBy, &link.TokenHash, &link.PasswordHash,
+			&link.ExpiresAt, &link.MaxDownloads, &link.RevokedAt, &link.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+func (r *ShareLinkRepository) Revoke(ctx context.Context, docID, linkID string) error {
+	query := `UPDATE share_links SET revoked_at = CURRENT_TIMESTAMP
+	          WHERE id = $1 AND document_id = $2 AND revoked_at IS NULL`
+	result, err := r.db.Exec(ctx, query, linkID, docID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrShareLinkNotFound
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// queryBuilder собирает условия WHERE и нумерует аргументы запроса
+type queryBuilder struct {
+	conds []string
+	args  []interface{}
+}
+
+// arg добавляет аргумент и возвращает его плейсхолдер
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *queryBuilder) where(cond string) {
+	b.conds = append(b.conds, cond)
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
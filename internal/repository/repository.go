@@ -1,33 +1,131 @@
-package repository
-
-import (
-	"context"
-
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/paudarco/doc-storage/internal/entity"
-)
-
-type User interface {
-	Create(ctx context.Context, user *entity.User) error
-	GetByLogin(ctx context.Context, login string) (*entity.User, error)
-	GetByID(ctx context.Context, id string) (*entity.User, error)
-}
-
-type Doc interface {
-	Create(ctx context.Context, doc *entity.Document) error
-	GetByID(ctx context.Context, id string) (*entity.Document, error)
-	List(ctx context.Context, userID, loginFilter, keyFilter, valueFilter string, limit int) ([]*entity.Document, error)
-	Delete(ctx context.Context, id string) error
-}
-
-type Repository struct {
-	User
-	Doc
-}
-
-func NewRepository(db *pgxpool.Pool) *Repository {
-	return &Repository{
-		User: NewUserRepository(db),
-		Doc:  NewDocRepository(db),
-	}
-}
+package repository
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+)
+
+type User interface {
+	Create(ctx context.Context, user *entity.User) error
+	GetByLogin(ctx context.Context, login string) (*entity.User, error)
+	GetByID(ctx context.Context, id string) (*entity.User, error)
+}
+
+type Doc interface {
+	Create(ctx context.Context, doc *entity.Document) error
+	GetByID(ctx context.Context, id string) (*entity.Document, error)
+	GetByIDs(ctx context.Context, ids []string) ([]*entity.Document, error)
+	List(ctx context.Context, viewerID, viewerLogin, ownerID string, q *entity.DocListQuery) (*entity.DocPage, error)
+	Update(ctx context.Context, doc *entity.Document) error
+	SetGrants(ctx context.Context, docID string, grants []entity.Grant) error
+	SetTags(ctx context.Context, docID string, tags []string) error
+	Search(ctx context.Context, userID, login string, q entity.SearchQuery) ([]*entity.SearchResult, error)
+	TagFacets(ctx context.Context, userID, login, ownerID string, filter entity.TagFilter) ([]entity.TagCount, error)
+	Transfer(ctx context.Context, docID, fromUserID, toUserID, actorID string) error
+	TransferAll(ctx context.Context, fromUserID, toUserID, actorID, suffix string) ([]string, error)
+	ListByFolder(ctx context.Context, ownerID string, folderID *string) ([]*entity.Document, error)
+	GetByName(ctx context.Context, ownerID string, folderID *string, name string) (*entity.Document, error)
+	GetText(ctx context.Context, docID string) (*entity.DocText, error)
+	SetText(ctx context.Context, docID, status, text, errMsg string) error
+	Delete(ctx context.Context, id string) error
+	DeleteExpired(ctx context.Context, limit int) ([]*entity.Document, error)
+	Batch(ctx context.Context, ops []entity.BatchOperation, atomic bool) ([]error, error)
+}
+
+type ShareLink interface {
+	Create(ctx context.Context, link *entity.ShareLink) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*entity.ShareLink, error)
+	ListByDoc(ctx context.Context, docID string) ([]*entity.ShareLink, error)
+	Revoke(ctx context.Context, docID, linkID string) error
+}
+
+type Folder interface {
+	Create(ctx context.Context, folder *entity.Folder) error
+	GetByID(ctx context.Context, id string) (*entity.Folder, error)
+	GetByName(ctx context.Context, ownerID string, parentID *string, name string) (*entity.Folder, error)
+	ListChildren(ctx context.Context, ownerID string, parentID *string) ([]*entity.Folder, error)
+	Update(ctx context.Context, folder *entity.Folder) error
+	IsDescendant(ctx context.Context, folderID, candidateID string) (bool, error)
+	Delete(ctx context.Context, id string) ([]*entity.Document, error)
+	SetGrants(ctx context.Context, folderID string, grants []entity.Grant) error
+	Permissions(ctx context.Context, folderIDs []string, userID, login string) (map[string]entity.FolderAccess, error)
+}
+
+type Thumbnail interface {
+	Save(ctx context.Context, docID string, thumbs []*entity.Thumbnail) error
+	SetStatus(ctx context.Context, docID, status string) error
+	Get(ctx context.Context, docID string, size int) (*entity.Thumbnail, error)
+}
+
+type Blob interface {
+	Open(ctx context.Context, hash string) (*entity.Blob, error)
+	Get(ctx context.Context, hash string) ([]byte, error)
+	GetOwned(ctx context.Context, ownerID, hash string) ([]byte, error)
+	Owned(ctx context.Context, ownerID string, hashes []string) ([]string, error)
+	Put(ctx context.Context, src io.Reader, check func(hash string, size int64) error) (string, int64, error)
+	Collect(ctx context.Context, grace time.Duration, limit int) (int64, error)
+	Scan(ctx context.Context, after string, limit int) ([]*entity.Blob, error)
+	MarkVerified(ctx context.Context, hashes []string) error
+	MarkCorrupt(ctx context.Context, hash string) error
+	References(ctx context.Context, hash string) ([]entity.BlobRef, error)
+}
+
+type Scan interface {
+	SetStatus(ctx context.Context, docID, status, signature string) error
+	Pending(ctx context.Context, olderThan time.Duration, limit int) ([]*entity.Document, error)
+	Quarantine(ctx context.Context, statuses []string, limit int) ([]*entity.QuarantinedDoc, error)
+}
+
+type Usage interface {
+	Get(ctx context.Context, userID string) (*entity.Usage, error)
+	SetQuota(ctx context.Context, userID string, override *entity.QuotaOverride) error
+}
+
+type Retention interface {
+	CreateRule(ctx context.Context, rule *entity.RetentionRule) error
+	ListRules(ctx context.Context) ([]*entity.RetentionRule, error)
+	DeleteRule(ctx context.Context, id string) error
+	SetLegalHold(ctx context.Context, docID string, hold bool) (string, error)
+	RetainedUntil(ctx context.Context, docID string) (*time.Time, error)
+}
+
+type Key interface {
+	Stale(ctx context.Context, owner, activeID, after string, limit int) ([]*entity.WrappedKey, error)
+	Replace(ctx context.Context, key *entity.WrappedKey, keyID string, wrapped []byte) (bool, error)
+	PlainDocs(ctx context.Context, after string, limit int) ([]string, error)
+	SealDoc(ctx context.Context, id string) (bool, error)
+	PlainBlobs(ctx context.Context, after string, limit int) ([]string, error)
+	SealBlob(ctx context.Context, hash string) (bool, error)
+}
+
+type Repository struct {
+	User
+	Doc
+	ShareLink
+	Folder
+	Thumbnail
+	Blob
+	Usage
+	Scan
+	Retention
+}
+
+// indexEncrypted включает поисковый индекс по содержимому зашифрованных документов
+func NewRepository(db *pgxpool.Pool, keys *envelope.Keyring, quota entity.Quota, indexEncrypted bool) *Repository {
+	return &Repository{
+		User:      NewUserRepository(db),
+		Doc:       NewDocRepository(db, keys, quota, indexEncrypted),
+		ShareLink: NewShareLinkRepository(db),
+		Folder:    NewFolderRepository(db),
+		Thumbnail: NewThumbnailRepository(db, keys),
+		Blob:      NewBlobRepository(db, keys),
+		Usage:     NewUsageRepository(db, quota),
+		Scan:      NewScanRepository(db),
+		Retention: NewRetentionRepository(db),
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+// RetentionRepository правила хранения и legal hold. Сами ограничения проверяет триггер
+// documents_retention, ошибки которого переводит retentionError.
+type RetentionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRetentionRepository(db *pgxpool.Pool) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+func (r *RetentionRepository) CreateRule(ctx context.Context, rule *entity.RetentionRule) error {
+	query := `INSERT INTO retention_rules (owner_id, tag, folder_id, mime, retain_until, reason)
+	          VALUES (NULLIF($1, '')::uuid, NULLIF($2, ''), NULLIF($3, '')::uuid, NULLIF($4, ''), $5, $6)
+	          RETURNING id, created_at`
+	return r.db.QueryRow(ctx, query, rule.OwnerID, rule.Tag, rule.FolderID, rule.Mime, rule.RetainUntil, rule.Reason).
+		Scan(&rule.ID, &rule.CreatedAt)
+}
+
+// ListRules возвращает все правила, включая истекшие, начиная с действующих дольше всех
+func (r *RetentionRepository) ListRules(ctx context.Context) ([]*entity.RetentionRule, error) {
+	query := `SELECT r.id, COALESCE(r.owner_id::text, ''), COALESCE(u.login, ''), COALESCE(r.tag, ''),
+	                 COALESCE(r.folder_id::text, ''), COALESCE(r.mime, ''), r.retain_until, r.reason, r.created_at
+	          FROM retention_rules r
+	          LEFT JOIN users u ON u.id = r.owner_id
+	          ORDER BY r.retain_until DESC, r.created_at DESC`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*entity.RetentionRule{}
+	for rows.Next() {
+		rule := &entity.RetentionRule{}
+		if err := rows.Scan(&rule.ID, &rule.OwnerID, &rule.Owner, &rule.Tag, &rule.FolderID, &rule.Mime,
+			&rule.RetainUntil, &rule.Reason, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *RetentionRepository) DeleteRule(ctx context.Context, id string) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM retention_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrRetentionRuleNotFound
+	}
+	return nil
+}
+
+// SetLegalHold ставит или снимает legal hold и возвращает владельца документа.
+// Документ с истекшим сроком жизни тоже можно удержать, пока его не удалила очистка.
+func (r *RetentionRepository) SetLegalHold(ctx context.Context, docID string, hold bool) (string, error) {
+	var ownerID string
+	err := r.db.QueryRow(ctx, `UPDATE documents SET legal_hold = $2 WHERE id = $1 RETURNING user_id`, docID, hold).
+		Scan(&ownerID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", errors.ErrDocNotFound
+		}
+		return "", err
+	}
+	return ownerID, nil
+}
+
+// RetainedUntil возвращает самый поздний срок хранения документа по действующим правилам или nil
+func (r *RetentionRepository) RetainedUntil(ctx context.Context, docID string) (*time.Time, error) {
+	var until *time.Time
+	query := `SELECT document_retained_until(id, user_id, folder_id, mime) FROM documents WHERE id = $1`
+	if err := r.db.QueryRow(ctx, query, docID).Scan(&until); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrDocNotFound
+		}
+		return nil, err
+	}
+	return until, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+type ScanRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewScanRepository(db *pgxpool.Pool) *ScanRepository {
+	return &ScanRepository{db: db}
+}
+
+// SetStatus сохраняет результат проверки документа
+func (r *ScanRepository) SetStatus(ctx context.Context, docID, status, signature string) error {
+	query := `UPDATE documents SET scan_status = $2, scan_signature = $3, scanned_at = now() WHERE id = $1`
+	result, err := r.db.Exec(ctx, query, docID, status, signature)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrDocNotFound
+	}
+	return nil
+}
+
+// Pending возвращает до limit документов, ожидающих проверки дольше olderThan, начиная со старых
+func (r *ScanRepository) Pending(ctx context.Context, olderThan time.Duration, limit int) ([]*entity.Document, error) {
+	query := `SELECT ` + docColumns + ` FROM documents d
+	          WHERE d.scan_status = $1 AND d.created_at < now() - make_interval(secs => $2)
+	          ORDER BY d.created_at LIMIT $3`
+	rows, err := r.db.Query(ctx, query, entity.ScanStatusPending, olderThan.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []*entity.Document{}
+	for rows.Next() {
+		doc := &entity.Document{}
+		if err := scanDoc(rows, doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// Quarantine возвращает до limit документов со статусом из statuses, начиная с новых
+func (r *ScanRepository) Quarantine(ctx context.Context, statuses []string, limit int) ([]*entity.QuarantinedDoc, error) {
+	query := `SELECT id, user_id, name, COALESCE(mime, ''), size, COALESCE(content_hash, ''), scan_status, scan_signature, scanned_at, created_at
+	          FROM documents
+	          WHERE scan_status = ANY($1)
+	          ORDER BY created_at DESC LIMIT $2`
+	rows, err := r.db.Query(ctx, query, statuses, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []*entity.QuarantinedDoc{}
+	for rows.Next() {
+		doc := &entity.QuarantinedDoc{}
+		if err := rows.Scan(&doc.ID, &doc.UserID, &doc.Name, &doc.Mime, &doc.Size, &doc.Hash, &doc.Status,
+			&doc.Signature, &doc.ScannedAt, &doc.CreatedAt); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
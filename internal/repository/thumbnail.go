@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+)
+
+type ThumbnailRepository struct {
+	db   *pgxpool.Pool
+	keys *envelope.Keyring
+}
+
+func NewThumbnailRepository(db *pgxpool.Pool, keys *envelope.Keyring) *ThumbnailRepository {
+	return &ThumbnailRepository{db: db, keys: keys}
+}
+
+// Save сохраняет превью документа и отмечает генерацию завершенной. Превью документа с ключом
+// данных шифруются этим ключом.
+func (r *ThumbnailRepository) Save(ctx context.Context, docID string, thumbs []*entity.Thumbnail) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var keyID *string
+	var wrapped []byte
+	err = tx.QueryRow(ctx, `SELECT key_id, wrapped_key FROM documents WHERE id = $1`, docID).Scan(&keyID, &wrapped)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return errors.ErrDocNotFound
+		}
+		return err
+	}
+	key, err := openDocKey(r.keys, keyID, wrapped)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO document_thumbnails (document_id, size, mime, width, height, data, encrypted, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	          ON CONFLICT (document_id, size) DO UPDATE
+	          SET mime = EXCLUDED.mime, width = EXCLUDED.width, height = EXCLUDED.height,
+	              data = EXCLUDED.data, encrypted = EXCLUDED.encrypted, created_at = EXCLUDED.created_at`
+	for _, t := range thumbs {
+		data := t.Data
+		if key != nil {
+			if data, err = key.seal(t.Data); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(ctx, query, docID, t.Size, t.Mime, t.Width, t.Height, data, key != nil, t.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(ctx, `UPDATE documents SET thumbnail_status = $2 WHERE id = $1`, docID, entity.ThumbnailStatusDone)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrDocNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *ThumbnailRepository) SetStatus(ctx context.Context, docID, status string) error {
+	result, err := r.db.Exec(ctx, `UPDATE documents SET thumbnail_status = $2 WHERE id = $1`, docID, status)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrDocNotFound
+	}
+	return nil
+}
+
+func (r *ThumbnailRepository) Get(ctx context.Context, docID string, size int) (*entity.Thumbnail, error) {
+	query := `SELECT t.document_id, t.size, t.mime, t.width, t.height, t.data, t.created_at,
+	                 t.encrypted, d.key_id, d.wrapped_key
+	          FROM document_thumbnails t
+	          JOIN documents d ON d.id = t.document_id
+	          WHERE t.document_id = $1 AND t.size = $2`
+	t := &entity.Thumbnail{}
+	var encrypted bool
+	var keyID *string
+	var wrapped []byte
+	err := r.db.QueryRow(ctx, query, docID, size).
+		Scan(&t.DocumentID, &t.Size, &t.Mime, &t.Width, &t.Height, &t.Data, &t.CreatedAt, &encrypted, &keyID, &wrapped)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrThumbnailNotFound
+		}
+		return nil, err
+	}
+	if encrypted {
+		key, err := openDocKey(r.keys, keyID, wrapped)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, envelope.ErrDecrypt
+		}
+		if t.Data, err = key.open(t.Data); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
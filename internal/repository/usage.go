@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+type UsageRepository struct {
+	db       *pgxpool.Pool
+	defaults entity.Quota
+}
+
+func NewUsageRepository(db *pgxpool.Pool, defaults entity.Quota) *UsageRepository {
+	return &UsageRepository{db: db, defaults: defaults}
+}
+
+// Get возвращает использование хранилища пользователем, у пользователя без документов оно нулевое
+func (r *UsageRepository) Get(ctx context.Context, userID string) (*entity.Usage, error) {
+	return getUsage(ctx, r.db, userID, r.defaults)
+}
+
+// SetQuota задает квоты пользователя, nil в поле возвращает квоту по умолчанию
+func (r *UsageRepository) SetQuota(ctx context.Context, userID string, override *entity.QuotaOverride) error {
+	query := `INSERT INTO user_usage (user_id, max_bytes, max_docs, max_upload_size) VALUES ($1, $2, $3, $4)
+	          ON CONFLICT (user_id) DO UPDATE
+	          SET max_bytes = EXCLUDED.max_bytes, max_docs = EXCLUDED.max_docs, max_upload_size = EXCLUDED.max_upload_size`
+	_, err := r.db.Exec(ctx, query, userID, override.MaxBytes, override.MaxDocs, override.MaxUploadSize)
+	return err
+}
+
+// rowQuerier общий для пула и транзакции метод, getUsage вызывается и там, и там
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func getUsage(ctx context.Context, db rowQuerier, userID string, defaults entity.Quota) (*entity.Usage, error) {
+	usage := &entity.Usage{Quota: defaults}
+	var maxBytes, maxDocs, maxUploadSize *int64
+	err := db.QueryRow(ctx, `SELECT bytes, docs, max_bytes, max_docs, max_upload_size FROM user_usage WHERE user_id = $1`, userID).
+		Scan(&usage.Bytes, &usage.Docs, &maxBytes, &maxDocs, &maxUploadSize)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	if maxBytes != nil {
+		usage.MaxBytes = *maxBytes
+		usage.Override = true
+	}
+	if maxDocs != nil {
+		usage.MaxDocs = *maxDocs
+		usage.Override = true
+	}
+	if maxUploadSize != nil {
+		usage.MaxUploadSize = *maxUploadSize
+		usage.Override = true
+	}
+	return usage, nil
+}
+
+// checkQuota проверяет квоты userID после изменения документов в транзакции tx. Строку user_usage
+// уже заблокировал триггер, поэтому параллельные загрузки проверяются по очереди. size - размер
+// добавленного документа: если он один больше квоты, загрузка не поместится никогда.
+func checkQuota(ctx context.Context, tx pgx.Tx, userID string, size int64, defaults entity.Quota) error {
+	usage, err := getUsage(ctx, tx, userID, defaults)
+	if err != nil {
+		return err
+	}
+	if usage.MaxBytes > 0 && usage.Bytes > usage.MaxBytes {
+		if size > usage.MaxBytes {
+			return errors.ErrDocExceedsQuota
+		}
+		return errors.ErrQuotaExceeded
+	}
+	if usage.MaxDocs > 0 && usage.Docs > usage.MaxDocs {
+		return errors.ErrDocQuotaExceeded
+	}
+	return nil
+}
@@ -1,64 +1,64 @@
-package repository
-
-import (
-	"context"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/paudarco/doc-storage/internal/entity"
-	"github.com/paudarco/doc-storage/internal/errors"
-)
-
-type UserRepository struct {
-	db *pgxpool.Pool
-}
-
-func NewUserRepository(db *pgxpool.Pool) *UserRepository {
-	return &UserRepository{db: db}
-}
-
-func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
-	query := `
-		INSERT INTO users (id, login, password, created_at) 
-		VALUES ($1, $2, $3, $4)
-	`
-	_, err := r.db.Exec(ctx,
-		query,
-		user.ID,
-		user.Login,
-		user.Password,
-		user.CreatedAt)
-
-	return err
-}
-
-func (r *UserRepository) GetByLogin(ctx context.Context, login string) (*entity.User, error) {
-	query := `
-		SELECT id, login, password, created_at FROM users WHERE login = $1
-	`
-	user := &entity.User{}
-	err := r.db.QueryRow(ctx, query, login).Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, errors.ErrUserNotFound
-		}
-		return nil, err
-	}
-
-	return user, nil
-}
-
-func (r *UserRepository) GetByID(ctx context.Context, id string) (*entity.User, error) {
-	query := `
-		SELECT id, login, password, created_at FROM users WHERE id = $1
-	`
-	user := &entity.User{}
-	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, errors.ErrUserNotFound
-		}
-		return nil, err
-	}
-	return user, nil
-}
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+type UserRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserRepository(db *pgxpool.Pool) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	query := `
+		INSERT INTO users (id, login, password, created_at) 
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := r.db.Exec(ctx,
+		query,
+		user.ID,
+		user.Login,
+		user.Password,
+		user.CreatedAt)
+
+	return err
+}
+
+func (r *UserRepository) GetByLogin(ctx context.Context, login string) (*entity.User, error) {
+	query := `
+		SELECT id, login, password, created_at FROM users WHERE login = $1
+	`
+	user := &entity.User{}
+	err := r.db.QueryRow(ctx, query, login).Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*entity.User, error) {
+	query := `
+		SELECT id, login, password, created_at FROM users WHERE id = $1
+	`
+	user := &entity.User{}
+	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
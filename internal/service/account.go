@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+)
+
+type AccountService struct {
+	usageRepo repository.Usage
+	userRepo  repository.User
+}
+
+func NewAccountService(usageRepo repository.Usage, userRepo repository.User) *AccountService {
+	return &AccountService{
+		usageRepo: usageRepo,
+		userRepo:  userRepo,
+	}
+}
+
+// Usage возвращает использование хранилища и квоты пользователя
+func (s *AccountService) Usage(ctx context.Context, userID string) (*entity.Usage, error) {
+	return s.usageRepo.Get(ctx, userID)
+}
+
+// UserUsage административная операция: использование и квоты пользователя login
+func (s *AccountService) UserUsage(ctx context.Context, login string) (*entity.Usage, error) {
+	user, err := s.userRepo.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	return s.usageRepo.Get(ctx, user.ID.String())
+}
+
+// SetQuota административная операция: задает квоты пользователя login. Уже загруженные документы
+// не удаляются, но пока использование выше квоты, новые загрузки отклоняются.
+func (s *AccountService) SetQuota(ctx context.Context, login string, override *entity.QuotaOverride) (*entity.Usage, error) {
+	for _, limit := range []*int64{override.MaxBytes, override.MaxDocs, override.MaxUploadSize} {
+		if limit != nil && *limit < 0 {
+			return nil, errors.ErrInvalidQuota
+		}
+	}
+
+	user, err := s.userRepo.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.usageRepo.SetQuota(ctx, user.ID.String(), override); err != nil {
+		return nil, err
+	}
+	return s.usageRepo.Get(ctx, user.ID.String())
+}
+
+func isQuotaError(err error) bool {
+	return err == errors.ErrQuotaExceeded || err == errors.ErrDocQuotaExceeded || err == errors.ErrDocExceedsQuota
+}
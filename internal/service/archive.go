@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+// ArchiveVisitor получает документ с открытым потоком содержимого и путь папок внутри архива
+// (пустой для корня). Поток закрывается после возврата, ошибка visitor прерывает сборку архива.
+type ArchiveVisitor func(dir []string, doc *entity.Document) error
+
+// Archive выбирает документы по req (или по фильтрам q) и по одному передает их visitor,
+// не загружая весь набор в память. Каждый документ проверяется через checkAccess,
+// отсутствующие и недоступные документы попадают в result.Skipped.
+func (s *DocService) Archive(ctx context.Context, userID string, req *entity.ArchiveRequest, q *entity.DocListQuery, visit ArchiveVisitor) (*entity.ArchiveResult, error) {
+	a := &archiver{
+		docs:   s,
+		userID: userID,
+		visit:  visit,
+		limit:  s.cfg.ArchiveMaxDocs,
+		seen:   make(map[string]struct{}),
+		result: &entity.ArchiveResult{Skipped: []entity.ArchiveSkipped{}},
+	}
+
+	switch {
+	case len(req.IDs) > 0:
+		for _, id := range req.IDs {
+			if err := a.add(ctx, nil, id); err != nil {
+				return nil, err
+			}
+		}
+
+	case req.FolderID != "":
+		folder, err := s.authorizeFolder(ctx, userID, req.FolderID, entity.PermissionRead)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.addFolder(ctx, folder, nil); err != nil {
+			return nil, err
+		}
+
+	default:
+		q.Page = entity.PageRequest{Limit: entity.MaxPageLimit}
+		q.IncludeJSON = false
+		for !a.result.Truncated {
+			page, err := s.List(ctx, userID, q)
+			if err != nil {
+				return nil, err
+			}
+			for _, doc := range page.Docs {
+				if err := a.add(ctx, nil, doc.ID); err != nil {
+					return nil, err
+				}
+			}
+			if page.Next == "" {
+				break
+			}
+			q.Page.Cursor = page.Next
+		}
+	}
+
+	return a.result, nil
+}
+
+type archiver struct {
+	docs   *DocService
+	userID string
+	visit  ArchiveVisitor
+	limit  int
+	seen   map[string]struct{}
+	result *entity.ArchiveResult
+}
+
+// add читает документ в обход кэша, проверяет право на чтение и передает его visitor
+func (a *archiver) add(ctx context.Context, dir []string, docID string) error {
+	if a.result.Truncated {
+		return nil
+	}
+	if _, ok := a.seen[docID]; ok {
+		return nil
+	}
+	a.seen[docID] = struct{}{}
+
+	if _, err := uuid.Parse(docID); err != nil {
+		a.result.Skipped = append(a.result.Skipped, entity.ArchiveSkipped{ID: docID, Err: errors.ErrDocNotFound})
+		return nil
+	}
+
+	doc, err := a.docs.authorize(ctx, a.userID, docID, entity.PermissionRead)
+	if err == errors.ErrDocNotFound || err == errors.ErrAccessDenied {
+		a.result.Skipped = append(a.result.Skipped, entity.ArchiveSkipped{ID: docID, Err: err})
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if a.limit > 0 && a.result.Added >= a.limit {
+		a.result.Truncated = true
+		return nil
+	}
+	if err := a.docs.loadContent(ctx, doc, a.userID); err == errors.ErrScanPending || err == errors.ErrScanFailed || err == errors.ErrContentInfected {
+		a.result.Skipped = append(a.result.Skipped, entity.ArchiveSkipped{ID: docID, Err: err})
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer doc.CloseContent()
+
+	if err := a.visit(dir, doc); err != nil {
+		return err
+	}
+	a.result.Added++
+	return nil
+}
+
+// addFolder добавляет документы папки и рекурсивно вложенных папок
+func (a *archiver) addFolder(ctx context.Context, folder *entity.Folder, dir []string) error {
+	docs, err := a.docs.docRepo.ListByFolder(ctx, folder.UserID, &folder.ID)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := a.add(ctx, dir, doc.ID); err != nil {
+			return err
+		}
+	}
+
+	children, err := a.docs.folderRepo.ListChildren(ctx, folder.UserID, &folder.ID)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if a.result.Truncated {
+			return nil
+		}
+		childDir := append(append([]string{}, dir...), child.Name)
+		if err := a.addFolder(ctx, child, childDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
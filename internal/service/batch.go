@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+// Batch применяет к документам список операций в одной транзакции. Права проверяются до записи
+// по состоянию документов на начало пакета, с учетом предыдущих операций пакета над тем же документом.
+// Возвращает результат каждой операции и признак того, что изменения зафиксированы.
+func (s *DocService) Batch(ctx context.Context, userID string, req *entity.BatchRequest) ([]*entity.BatchResult, bool, error) {
+	if len(req.Operations) == 0 || len(req.Operations) > entity.MaxBatchOperations {
+		return nil, false, errors.ErrInvalidBatch
+	}
+
+	currentUser, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var ids []string
+	seen := make(map[string]struct{})
+	for _, op := range req.Operations {
+		if _, err := uuid.Parse(op.ID); err != nil {
+			continue
+		}
+		if _, ok := seen[op.ID]; !ok {
+			seen[op.ID] = struct{}{}
+			ids = append(ids, op.ID)
+		}
+	}
+
+	found, err := s.docRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := s.filterReadable(ctx, found, userID, currentUser.Login); err != nil {
+		return nil, false, err
+	}
+	docs := make(map[string]*entity.Document, len(found))
+	ownerOf := make(map[string]string, len(found))
+	for _, doc := range found {
+		docs[doc.ID] = doc
+		ownerOf[doc.ID] = doc.UserID
+	}
+
+	results := make([]*entity.BatchResult, len(req.Operations))
+	planned := make([]entity.BatchOperation, 0, len(req.Operations))
+	plannedIdx := make([]int, 0, len(req.Operations))
+	folders := make(map[string]error)
+	failed := false
+
+	for i, op := range req.Operations {
+		results[i] = &entity.BatchResult{Index: i, ID: op.ID, Op: op.Op}
+
+		prepared, err := s.prepareBatchOp(ctx, userID, op, docs, folders)
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		planned = append(planned, *prepared)
+		plannedIdx = append(plannedIdx, i)
+	}
+
+	if req.Atomic && failed {
+		for _, res := range results {
+			if res.Err == nil {
+				res.Err = errors.ErrBatchRolledBack
+			}
+		}
+		return results, false, nil
+	}
+
+	if len(planned) == 0 {
+		return results, false, nil
+	}
+
+	opErrs, err := s.docRepo.Batch(ctx, planned, req.Atomic)
+	if err != nil {
+		s.log.Errorf("batch of %d operations failed: %v", len(planned), err)
+		return nil, false, err
+	}
+
+	committed := false
+	var changedIDs []string
+	owners := make(map[string]struct{})
+	for j, opErr := range opErrs {
+		i := plannedIdx[j]
+		results[i].Err = opErr
+		if opErr != nil {
+			continue
+		}
+		committed = true
+		changedIDs = append(changedIDs, planned[j].ID)
+		owners[ownerOf[planned[j].ID]] = struct{}{}
+	}
+
+	_ = s.cache.DeleteDocs(ctx, changedIDs)
+	for ownerID := range owners {
+		_ = s.cache.InvalidateUserDocLists(ctx, ownerID)
+	}
+
+	return results, committed, nil
+}
+
+// prepareBatchOp проверяет аргументы операции и права пользователя, нормализует аргументы
+// и применяет операцию к копии документа в docs, чтобы следующие операции видели ее результат
+func (s *DocService) prepareBatchOp(ctx context.Context, userID string, op entity.BatchOperation, docs map[string]*entity.Document, folders map[string]error) (*entity.BatchOperation, error) {
+	doc, ok := docs[op.ID]
+	if !ok {
+		return nil, errors.ErrDocNotFound
+	}
+	if err := checkHold(doc); err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case entity.BatchDelete:
+		if !doc.Permission.Allows(entity.PermissionOwner) {
+			return nil, errors.ErrAccessDenied
+		}
+		delete(docs, op.ID)
+
+	case entity.BatchSetPublic:
+		if op.Public == nil {
+			return nil, errors.ErrInvalidBatchOp
+		}
+		// Видимость документа - решение о доступе, как и гранты
+		if !doc.Permission.Allows(entity.PermissionShare) {
+			return nil, errors.ErrAccessDenied
+		}
+		doc.Public = *op.Public
+
+	case entity.BatchAddGrant, entity.BatchRemoveGrant:
+		if !doc.Permission.Allows(entity.PermissionShare) {
+			return nil, errors.ErrAccessDenied
+		}
+
+		var grants []entity.Grant
+		if op.Op == entity.BatchAddGrant {
+			if op.Grant == nil {
+				return nil, errors.ErrInvalidBatchOp
+			}
+			normalized, err := normalizeGrants([]entity.Grant{*op.Grant})
+			if err != nil {
+				return nil, err
+			}
+			op.Grant = &normalized[0]
+			grants = append(grants, *op.Grant)
+			for _, g := range doc.Grant {
+				if g.Login != op.Grant.Login {
+					grants = append(grants, g)
+				}
+			}
+		} else {
+			if op.Login == "" {
+				return nil, errors.ErrInvalidBatchOp
+			}
+			for _, g := range doc.Grant {
+				if g.Login != op.Login {
+					grants = append(grants, g)
+				}
+			}
+		}
+
+		if err := checkGrantChange(doc.Permission, doc.Grant, grants); err != nil {
+			return nil, err
+		}
+		doc.Grant = grants
+
+	case entity.BatchAddTags, entity.BatchRemoveTags:
+		tags, err := normalizeTags(op.Tags)
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) == 0 {
+			return nil, errors.ErrInvalidBatchOp
+		}
+		if !doc.Permission.Allows(entity.PermissionWrite) {
+			return nil, errors.ErrAccessDenied
+		}
+		op.Tags = tags
+
+	case entity.BatchMove:
+		if op.FolderID == nil {
+			return nil, errors.ErrInvalidBatchOp
+		}
+		// Как и в Update, переносить документ может только его владелец
+		if doc.UserID != userID {
+			return nil, errors.ErrAccessDenied
+		}
+		if *op.FolderID != "" {
+			folderErr, checked := folders[*op.FolderID]
+			if !checked {
+				_, folderErr = s.authorizeFolder(ctx, userID, *op.FolderID, entity.PermissionWrite)
+				folders[*op.FolderID] = folderErr
+			}
+			if folderErr != nil {
+				return nil, folderErr
+			}
+		}
+
+	default:
+		return nil, errors.ErrInvalidBatchOp
+	}
+
+	return &op, nil
+}
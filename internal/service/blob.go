@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const maxHashesPerCheck = 1000
+
+// BlobCollector периодически удаляет содержимое, на которое не ссылается ни один документ
+type BlobCollector struct {
+	blobRepo repository.Blob
+	cfg      *config.Config
+	log      *logrus.Logger
+}
+
+func NewBlobCollector(blobRepo repository.Blob, cfg *config.Config, log *logrus.Logger) *BlobCollector {
+	return &BlobCollector{
+		blobRepo: blobRepo,
+		cfg:      cfg,
+		log:      log,
+	}
+}
+
+// Run запускает сборку раз в BLOB_GC_INTERVAL и блокируется до отмены ctx
+func (c *BlobCollector) Run(ctx context.Context) {
+	if c.cfg.BlobGCInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(c.cfg.BlobGCInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.collect(ctx)
+		}
+	}
+}
+
+func (c *BlobCollector) collect(ctx context.Context) {
+	batch := c.cfg.BlobGCBatch
+	if batch <= 0 {
+		batch = 1000
+	}
+	grace := time.Duration(c.cfg.BlobGCGrace) * time.Second
+
+	var total int64
+	for ctx.Err() == nil {
+		n, err := c.blobRepo.Collect(ctx, grace, batch)
+		if err != nil {
+			c.log.Errorf("blob garbage collection failed: %v", err)
+			break
+		}
+		total += n
+		if n < int64(batch) {
+			break
+		}
+	}
+	if total > 0 {
+		c.log.Infof("removed %d unreferenced blobs", total)
+	}
+}
+
+// CheckHashes возвращает те из hashes, содержимое которых уже есть в документах пользователя.
+// Такой файл можно не загружать, а создать документ с meta.sha256 без части file.
+func (s *DocService) CheckHashes(ctx context.Context, userID string, hashes []string) ([]string, error) {
+	if len(hashes) == 0 || len(hashes) > maxHashesPerCheck {
+		return nil, errors.ErrInvalidHash
+	}
+
+	normalized := make([]string, len(hashes))
+	for i, hash := range hashes {
+		hash = strings.ToLower(hash)
+		if !validHash(hash) {
+			return nil, errors.ErrInvalidHash
+		}
+		normalized[i] = hash
+	}
+
+	return s.blobRepo.Owned(ctx, userID, normalized)
+}
+
+// loadContent открывает поток содержимого файла документа для пользователя userID (пустой - доступ
+// по публичной ссылке): непроверенное содержимое отдается только владельцу. Хэш сверяется
+// по ходу чтения, поток закрывает получатель через doc.CloseContent.
+func (s *DocService) loadContent(ctx context.Context, doc *entity.Document, userID string) error {
+	if !doc.IsFile || doc.ContentHash == "" || doc.Content != nil {
+		return nil
+	}
+	if err := checkScan(doc, userID); err != nil {
+		return err
+	}
+
+	blob, err := s.blobRepo.Open(ctx, doc.ContentHash)
+	if err != nil {
+		s.log.Errorf("failed to load content %s of document %s: %v", doc.ContentHash, doc.ID, err)
+		return err
+	}
+	if blob.Corrupt {
+		blob.Content.Close()
+		s.log.Errorf("content %s of document %s is quarantined as corrupt", doc.ContentHash, doc.ID)
+		return errors.ErrContentCorrupt
+	}
+	doc.Size = blob.Size
+	doc.Content = &verifiedContent{
+		src:      blob.Content,
+		hasher:   sha256.New(),
+		expected: doc.ContentHash,
+		corrupt: func() {
+			s.log.Errorf("content %s of document %s does not match its hash", doc.ContentHash, doc.ID)
+		},
+	}
+	return nil
+}
+
+// verifyBlockSize размер блока, который verifiedContent придерживает до следующего чтения
+const verifyBlockSize = 64 << 10
+
+// verifiedContent сверяет хэш содержимого по ходу чтения. Последний блок отдается только после
+// проверки, поэтому при несовпадении ответ обрывается, не дойдя до Content-Length, и клиент
+// не получает поврежденный файл целиком.
+type verifiedContent struct {
+	src      io.ReadCloser
+	hasher   hash.Hash
+	expected string
+	corrupt  func()
+
+	out  []byte // блок, отдаваемый читателю
+	held []byte // прочитанный, но придержанный блок
+	err  error  // ошибка или io.EOF после out
+}
+
+func (v *verifiedContent) Read(p []byte) (int, error) {
+	for len(v.out) == 0 {
+		if v.err != nil {
+			return 0, v.err
+		}
+		v.fill()
+	}
+	n := copy(p, v.out)
+	v.out = v.out[n:]
+	return n, nil
+}
+
+// fill читает следующий блок и отпускает придержанный. В конце содержимого сверяет хэш
+// и отдает остаток только при совпадении.
+func (v *verifiedContent) fill() {
+	block := make([]byte, verifyBlockSize)
+	n, err := io.ReadFull(v.src, block)
+	v.hasher.Write(block[:n])
+	switch err {
+	case nil:
+		v.out, v.held = v.held, block
+	case io.EOF, io.ErrUnexpectedEOF:
+		if hex.EncodeToString(v.hasher.Sum(nil)) != v.expected {
+			v.corrupt()
+			v.held, v.err = nil, errors.ErrContentCorrupt
+			return
+		}
+		v.out, v.held, v.err = append(v.held, block[:n]...), nil, io.EOF
+	default:
+		v.err = err
+	}
+}
+
+func (v *verifiedContent) Close() error {
+	return v.src.Close()
+}
+
+// verifyContent сверяет содержимое с meta.sha256, если он передан. Пустое содержимое
+// с meta.sha256 означает, что клиент пропустил загрузку файла, уже имеющегося у него,
+// тогда возвращается сохраненное содержимое.
+func (s *DocService) verifyContent(ctx context.Context, userID string, meta map[string]interface{}, data []byte) ([]byte, error) {
+	expected, err := expectedHash(meta)
+	if err != nil || expected == "" {
+		return data, err
+	}
+
+	if len(data) == 0 {
+		return s.blobRepo.GetOwned(ctx, userID, expected)
+	}
+	if hashContent(data) != expected {
+		return nil, errors.ErrDigestMismatch
+	}
+	return data, nil
+}
+
+// expectedHash возвращает meta.sha256 в нижнем регистре, пустую строку - если хэш не передан
+func expectedHash(meta map[string]interface{}) (string, error) {
+	raw, ok := meta["sha256"]
+	if !ok || raw == nil {
+		return "", nil
+	}
+	expected, ok := raw.(string)
+	expected = strings.ToLower(expected)
+	if !ok || !validHash(expected) {
+		return "", errors.ErrInvalidDigest
+	}
+	return expected, nil
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
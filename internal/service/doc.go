@@ -1,209 +1,926 @@
-package service
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"time"
-
-	"github.com/google/uuid"
-	"github.com/paudarco/doc-storage/internal/cache"
-	"github.com/paudarco/doc-storage/internal/entity"
-	"github.com/paudarco/doc-storage/internal/errors"
-	"github.com/paudarco/doc-storage/internal/repository"
-	"github.com/sirupsen/logrus"
-)
-
-type DocService struct {
-	docRepo  repository.Doc
-	userRepo repository.User
-	cache    cache.Doc
-	log      *logrus.Logger
-}
-
-func NewDocService(docRepo repository.Doc, userRepo repository.User, cache cache.Doc, log *logrus.Logger) *DocService {
-	return &DocService{
-		docRepo:  docRepo,
-		userRepo: userRepo,
-		cache:    cache,
-		log:      log,
-	}
-}
-
-func (s *DocService) Create(ctx context.Context, userID string, meta map[string]interface{}, jsonData json.RawMessage, fileData []byte) (*entity.Document, error) {
-	doc := &entity.Document{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		CreatedAt: time.Now(),
-	}
-
-	if name, ok := meta["name"].(string); ok {
-		doc.Name = name
-	} else {
-		return nil, errors.ErrMetaNameRequired
-	}
-
-	if isFile, ok := meta["file"].(bool); ok {
-		doc.IsFile = isFile
-	} else {
-		doc.IsFile = false
-	}
-
-	if public, ok := meta["public"].(bool); ok {
-		doc.Public = public
-	} else {
-		doc.Public = false
-	}
-
-	if mime, ok := meta["mime"].(string); ok && doc.IsFile {
-		doc.Mime = mime
-	}
-
-	if grantList, ok := meta["grant"].([]interface{}); ok {
-		for _, g := range grantList {
-			if login, ok := g.(string); ok {
-				doc.Grant = append(doc.Grant, login)
-			}
-		}
-	}
-
-	doc.JSONData = jsonData
-	doc.FileData = fileData
-
-	err := s.docRepo.Create(ctx, doc)
-	if err != nil {
-		s.log.Errorf("failed to create document in DB: %v", err)
-		return nil, fmt.Errorf("failed to create document in DB")
-	}
-
-	_ = s.cache.InvalidateUserDocLists(ctx, userID)
-
-	return doc, nil
-}
-
-func (s *DocService) List(ctx context.Context, userID, loginFilter, keyFilter, valueFilter string, limit int) ([]*entity.Document, error) {
-	targetUserID := userID
-	if loginFilter != "" {
-		var userUUID uuid.UUID
-		_, err := s.userRepo.GetByID(ctx, loginFilter)
-		if err != nil {
-			user, err := s.userRepo.GetByLogin(ctx, loginFilter)
-			if err != nil {
-				return []*entity.Document{}, nil
-			}
-			userUUID = user.ID
-		} else {
-			userUUID = uuid.MustParse(loginFilter)
-		}
-		targetUserID = userUUID.String()
-	}
-
-	cacheKey := cache.BuildDocListCacheKey(userID, loginFilter, keyFilter, valueFilter, limit)
-
-	cachedData, err := s.cache.GetDocList(ctx, cacheKey)
-	if err != nil {
-		s.log.Printf("Error getting doc list from cache: %v", err)
-	} else if cachedData != nil {
-		var docs []*entity.Document
-		if err := json.Unmarshal(*cachedData, &docs); err != nil {
-			s.log.Printf("Error unmarshalling cached doc list: %v", err)
-		} else {
-			return docs, nil
-		}
-	}
-
-	docs, err := s.docRepo.List(ctx, targetUserID, loginFilter, keyFilter, valueFilter, limit)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get document list from DB: %w", err)
-	}
-
-	dataToCache, err := json.Marshal(docs)
-	if err != nil {
-		s.log.Errorf("Error marshalling doc list for cache: %v", err)
-	} else {
-		_ = s.cache.SetDocList(ctx, cacheKey, dataToCache)
-	}
-
-	return docs, nil
-}
-
-func (s *DocService) GetByID(ctx context.Context, userID, docID string) (*entity.Document, error) {
-	cachedData, err := s.cache.GetDoc(ctx, docID)
-	if err != nil {
-
-		s.log.Errorf("Error getting doc from cache: %v", err)
-	} else if cachedData != nil {
-		var doc entity.Document
-		if err := json.Unmarshal(*cachedData, &doc); err != nil {
-			s.log.Printf("Error unmarshalling cached doc: %v", err)
-		} else {
-			if accessErr := s.checkAccess(ctx, &doc, userID); accessErr != nil {
-				return nil, accessErr
-			}
-
-			return &doc, nil
-		}
-	}
-
-	doc, err := s.docRepo.GetByID(ctx, docID)
-	if err != nil {
-		return nil, err
-	}
-
-	if accessErr := s.checkAccess(ctx, doc, userID); accessErr != nil {
-		return nil, accessErr
-	}
-
-	dataToCache, err := json.Marshal(doc)
-	if err != nil {
-		s.log.Errorf("Error marshalling doc for cache: %v", err)
-	} else {
-		_ = s.cache.SetDoc(ctx, docID, dataToCache)
-	}
-
-	return doc, nil
-}
-
-func (s *DocService) checkAccess(ctx context.Context, doc *entity.Document, userID string) error {
-	if doc.UserID != userID {
-		if !doc.Public {
-			hasAccess := false
-			for _, grantedLogin := range doc.Grant {
-				currentUser, err := s.userRepo.GetByID(ctx, userID)
-				if err != nil {
-					return err
-				}
-				if currentUser.Login == grantedLogin {
-					hasAccess = true
-					break
-				}
-			}
-			if !hasAccess {
-				return errors.ErrAccessDenied
-			}
-		}
-	}
-	return nil
-}
-
-func (s *DocService) Delete(ctx context.Context, userID, docID string) error {
-
-	doc, err := s.docRepo.GetByID(ctx, docID)
-	if err != nil {
-		return err
-	}
-
-	if doc.UserID != userID {
-		return errors.ErrAccessDenied
-	}
-
-	err = s.docRepo.Delete(ctx, docID)
-	if err != nil {
-		return err
-	}
-
-	_ = s.cache.DeleteDoc(ctx, docID)
-	_ = s.cache.InvalidateUserDocLists(ctx, doc.UserID)
-
-	return nil
-}
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+type DocService struct {
+	docRepo    repository.Doc
+	userRepo   repository.User
+	folderRepo repository.Folder
+	blobRepo   repository.Blob
+	usageRepo  repository.Usage
+	cache      cache.Doc
+	text       *TextExtractor
+	thumbs     *ThumbnailGenerator
+	scans      *ContentScanner
+	cfg        *config.Config
+	log        *logrus.Logger
+}
+
+func NewDocService(docRepo repository.Doc, userRepo repository.User, folderRepo repository.Folder, blobRepo repository.Blob, usageRepo repository.Usage, cache cache.Doc, text *TextExtractor, thumbs *ThumbnailGenerator, scans *ContentScanner, cfg *config.Config, log *logrus.Logger) *DocService {
+	return &DocService{
+		docRepo:    docRepo,
+		userRepo:   userRepo,
+		folderRepo: folderRepo,
+		blobRepo:   blobRepo,
+		usageRepo:  usageRepo,
+		cache:      cache,
+		text:       text,
+		thumbs:     thumbs,
+		scans:      scans,
+		cfg:        cfg,
+		log:        log,
+	}
+}
+
+func (s *DocService) Create(ctx context.Context, userID string, meta map[string]interface{}, jsonData json.RawMessage, fileData []byte) (*entity.Document, error) {
+	return s.CreateWithID(ctx, uuid.New().String(), userID, meta, jsonData, fileData)
+}
+
+// CreateWithID создает документ с заранее выданным ID (например, для загрузки по подписанной ссылке)
+func (s *DocService) CreateWithID(ctx context.Context, docID, userID string, meta map[string]interface{}, jsonData json.RawMessage, fileData []byte) (*entity.Document, error) {
+	doc, err := s.newDoc(ctx, docID, userID, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.IsFile {
+		if fileData, err = s.verifyContent(ctx, userID, meta, fileData); err != nil {
+			return nil, err
+		}
+		if err := s.checkUpload(ctx, doc, fileData); err != nil {
+			return nil, err
+		}
+		doc.ContentHash = hashContent(fileData)
+		doc.FileData = fileData
+		doc.Size = int64(len(fileData))
+	}
+
+	return s.create(ctx, doc, jsonData)
+}
+
+// newDoc собирает документ из meta и проверяет право записи в папку
+func (s *DocService) newDoc(ctx context.Context, docID, userID string, meta map[string]interface{}) (*entity.Document, error) {
+	if _, err := uuid.Parse(docID); err != nil {
+		return nil, errors.ErrInvalidRequestBody
+	}
+
+	doc := &entity.Document{
+		ID:        docID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+
+	if name, ok := meta["name"].(string); ok {
+		doc.Name = name
+	} else {
+		return nil, errors.ErrMetaNameRequired
+	}
+
+	if isFile, ok := meta["file"].(bool); ok {
+		doc.IsFile = isFile
+	} else {
+		doc.IsFile = false
+	}
+
+	if public, ok := meta["public"].(bool); ok {
+		doc.Public = public
+	} else {
+		doc.Public = false
+	}
+
+	if mime, ok := meta["mime"].(string); ok && doc.IsFile {
+		doc.Mime = mime
+	}
+
+	grants, err := parseGrants(meta["grant"])
+	if err != nil {
+		return nil, err
+	}
+	doc.Grant = grants
+
+	tags, err := parseTags(meta["tags"])
+	if err != nil {
+		return nil, err
+	}
+	doc.Tags = tags
+
+	expiresAt, err := parseExpiry(meta["expires_at"], meta["ttl"], doc.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	doc.ExpiresAt = expiresAt
+
+	// Все остальные ключи meta сохраняем как атрибуты документа
+	for key, value := range meta {
+		if _, reserved := entity.ReservedMetaKeys[key]; reserved {
+			continue
+		}
+		if doc.Attributes == nil {
+			doc.Attributes = make(map[string]interface{})
+		}
+		doc.Attributes[key] = value
+	}
+
+	if folderID, ok := meta["folder_id"].(string); ok && folderID != "" {
+		if _, err := s.authorizeFolder(ctx, userID, folderID, entity.PermissionWrite); err != nil {
+			return nil, err
+		}
+		doc.FolderID = &folderID
+	}
+
+	doc.SearchLang = s.cfg.SearchLanguage
+	if lang, ok := meta["lang"].(string); ok && lang != "" {
+		doc.SearchLang = lang
+	}
+
+	return doc, nil
+}
+
+// create сохраняет собранный документ, содержимое файла к этому моменту проверено,
+// а для потоковой загрузки уже записано в хранилище блобов
+func (s *DocService) create(ctx context.Context, doc *entity.Document, jsonData json.RawMessage) (*entity.Document, error) {
+	doc.JSONData = jsonData
+	if !doc.IsFile {
+		doc.Size = int64(len(jsonData))
+	}
+	doc.TextStatus = s.text.Status(doc)
+	doc.ThumbStatus = s.thumbs.Status(doc)
+	doc.ScanStatus = s.scans.Status(doc)
+
+	err := s.docRepo.Create(ctx, doc)
+	if err == errors.ErrDocAlreadyExist || err == errors.ErrInvalidSearchLang || isQuotaError(err) {
+		return nil, err
+	} else if err != nil {
+		s.log.Errorf("failed to create document in DB: %v", err)
+		return nil, fmt.Errorf("failed to create document in DB")
+	}
+
+	_ = s.cache.InvalidateUserDocLists(ctx, doc.UserID)
+
+	s.text.Enqueue(ctx, doc)
+	s.thumbs.Enqueue(ctx, doc)
+	s.scans.Enqueue(ctx, doc)
+
+	doc.Permission = entity.PermissionOwner
+
+	return doc, nil
+}
+
+func (s *DocService) List(ctx context.Context, userID string, q *entity.DocListQuery) (*entity.DocPage, error) {
+	targetUserID := userID
+	if q.Login != "" {
+		var userUUID uuid.UUID
+		_, err := s.userRepo.GetByID(ctx, q.Login)
+		if err != nil {
+			user, err := s.userRepo.GetByLogin(ctx, q.Login)
+			if err != nil {
+				return &entity.DocPage{Docs: []*entity.Document{}}, nil
+			}
+			userUUID = user.ID
+		} else {
+			userUUID = uuid.MustParse(q.Login)
+		}
+		targetUserID = userUUID.String()
+	}
+
+	normalizedTags, err := normalizeTags(q.Tags.Tags)
+	if err != nil {
+		return nil, err
+	}
+	q.Tags.Tags = normalizedTags
+
+	if q.Page.Limit <= 0 || q.Page.Limit > entity.MaxPageLimit {
+		q.Page.Limit = entity.DefaultPageLimit
+	}
+	if q.IncludeJSON {
+		q.JSONMaxSize = s.cfg.ListJSONMaxSize
+	}
+
+	cacheKey := cache.BuildDocListCacheKey(userID, q)
+
+	currentUser, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedData, err := s.cache.GetDocList(ctx, cacheKey)
+	if err != nil {
+		s.log.Printf("Error getting doc list from cache: %v", err)
+	} else if cachedData != nil {
+		var cached entity.DocPage
+		if err := json.Unmarshal(*cachedData, &cached); err != nil {
+			s.log.Printf("Error unmarshalling cached doc list: %v", err)
+		} else {
+			return s.readablePage(ctx, &cached, userID, currentUser.Login)
+		}
+	}
+
+	result, err := s.docRepo.List(ctx, userID, currentUser.Login, targetUserID, q)
+	if err == errors.ErrInvalidCursor {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get document list from DB: %w", err)
+	}
+
+	dataToCache, err := json.Marshal(result)
+	if err != nil {
+		s.log.Errorf("Error marshalling doc list for cache: %v", err)
+	} else {
+		_ = s.cache.SetDocList(ctx, cacheKey, dataToCache, earliestExpiry(result.Docs))
+	}
+
+	return s.readablePage(ctx, result, userID, currentUser.Login)
+}
+
+// readablePage оставляет на странице только доступные пользователю документы
+func (s *DocService) readablePage(ctx context.Context, page *entity.DocPage, userID, login string) (*entity.DocPage, error) {
+	docs, err := s.filterReadable(ctx, page.Docs, userID, login)
+	if err != nil {
+		return nil, err
+	}
+	page.Docs = docs
+	return page, nil
+}
+
+// Search выполняет полнотекстовый поиск по доступным пользователю документам
+func (s *DocService) Search(ctx context.Context, userID string, q entity.SearchQuery) ([]*entity.SearchResult, error) {
+	q.Query = strings.TrimSpace(q.Query)
+	if q.Query == "" {
+		return nil, errors.ErrSearchQueryRequired
+	}
+	if q.Lang == "" {
+		q.Lang = s.cfg.SearchLanguage
+	}
+	if q.Limit <= 0 {
+		q.Limit = entity.SearchDefaultLimit
+	}
+	if q.Limit > entity.SearchMaxLimit {
+		q.Limit = entity.SearchMaxLimit
+	}
+
+	currentUser, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.docRepo.Search(ctx, userID, currentUser.Login, q)
+	if err == errors.ErrInvalidSearchLang {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to search documents in DB: %w", err)
+	}
+
+	docs := make([]*entity.Document, len(results))
+	for i, res := range results {
+		docs[i] = res.Doc
+	}
+	// Права уже проверены в запросе, здесь только вычисляем эффективное право для ответа
+	if _, err := s.filterReadable(ctx, docs, userID, currentUser.Login); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetText возвращает извлеченный из файла текст и статус извлечения
+func (s *DocService) GetText(ctx context.Context, userID, docID string) (*entity.DocText, error) {
+	if _, err := s.authorize(ctx, userID, docID, entity.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	return s.docRepo.GetText(ctx, docID)
+}
+
+// filterReadable проставляет документам эффективное право пользователя (с учетом грантов папок)
+// и отбрасывает недоступные
+func (s *DocService) filterReadable(ctx context.Context, docs []*entity.Document, userID, login string) ([]*entity.Document, error) {
+	var folderIDs []string
+	seen := make(map[string]struct{})
+	for _, doc := range docs {
+		if doc.FolderID == nil || doc.UserID == userID {
+			continue
+		}
+		if _, ok := seen[*doc.FolderID]; !ok {
+			seen[*doc.FolderID] = struct{}{}
+			folderIDs = append(folderIDs, *doc.FolderID)
+		}
+	}
+
+	folderAccess, err := s.folderRepo.Permissions(ctx, folderIDs, userID, login)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*entity.Document, 0, len(docs))
+	for _, doc := range docs {
+		var folder entity.FolderAccess
+		if doc.FolderID != nil {
+			folder = folderAccess[*doc.FolderID]
+		}
+		doc.Permission = effectivePermission(doc, userID, login, folder)
+		if doc.Permission.Allows(entity.PermissionRead) {
+			result = append(result, doc)
+		}
+	}
+	return result, nil
+}
+
+func (s *DocService) GetByID(ctx context.Context, userID, docID string) (*entity.Document, error) {
+	cachedData, err := s.cache.GetDoc(ctx, docID)
+	if err != nil {
+
+		s.log.Errorf("Error getting doc from cache: %v", err)
+	} else if cachedData != nil {
+		var doc entity.Document
+		if err := json.Unmarshal(*cachedData, &doc); err != nil {
+			s.log.Printf("Error unmarshalling cached doc: %v", err)
+		} else if doc.Expired(time.Now()) {
+			return nil, errors.ErrDocNotFound
+		} else {
+			if accessErr := s.checkAccess(ctx, &doc, userID, entity.PermissionRead); accessErr != nil {
+				return nil, accessErr
+			}
+			if err := s.loadContent(ctx, &doc, userID); err != nil {
+				return nil, err
+			}
+
+			return &doc, nil
+		}
+	}
+
+	doc, err := s.docRepo.GetByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	if accessErr := s.checkAccess(ctx, doc, userID, entity.PermissionRead); accessErr != nil {
+		return nil, accessErr
+	}
+
+	dataToCache, err := json.Marshal(doc)
+	if err != nil {
+		s.log.Errorf("Error marshalling doc for cache: %v", err)
+	} else {
+		_ = s.cache.SetDoc(ctx, docID, dataToCache, doc.ExpiresAt)
+	}
+
+	if err := s.loadContent(ctx, doc, userID); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// checkAccess вычисляет эффективное право пользователя на документ, сохраняет его в doc.Permission
+// и возвращает ErrAccessDenied, если оно ниже требуемого
+func (s *DocService) checkAccess(ctx context.Context, doc *entity.Document, userID string, required entity.Permission) error {
+	doc.Permission = entity.PermissionNone
+	if doc.UserID == userID {
+		doc.Permission = entity.PermissionOwner
+		return nil
+	}
+
+	login := ""
+	if len(doc.Grant) > 0 || doc.FolderID != nil {
+		currentUser, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		login = currentUser.Login
+	}
+
+	var folder entity.FolderAccess
+	if doc.FolderID != nil {
+		access, err := s.folderRepo.Permissions(ctx, []string{*doc.FolderID}, userID, login)
+		if err != nil {
+			return err
+		}
+		folder = access[*doc.FolderID]
+	}
+
+	doc.Permission = effectivePermission(doc, userID, login, folder)
+	if !doc.Permission.Allows(required) {
+		return errors.ErrAccessDenied
+	}
+	return nil
+}
+
+// authorize читает документ из БД в обход кэша и проверяет право пользователя на него
+func (s *DocService) authorize(ctx context.Context, userID, docID string, required entity.Permission) (*entity.Document, error) {
+	doc, err := s.docRepo.GetByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkAccess(ctx, doc, userID, required); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// effectivePermission максимальное из прав: владелец, публичный доступ, гранты документа
+// и унаследованное от папки право. Право owner наследуется только на документы владельца
+// дерева папок: чужие документы в папке дают не больше share, чтобы владелец папки или
+// получатель гранта на нее не могли удалить или передать их.
+func effectivePermission(doc *entity.Document, userID, login string, folder entity.FolderAccess) entity.Permission {
+	if doc.UserID == userID {
+		return entity.PermissionOwner
+	}
+
+	perm := folder.Permission
+	if perm == entity.PermissionOwner && doc.UserID != folder.OwnerID {
+		perm = entity.PermissionShare
+	}
+	if doc.Public && perm.Level() < entity.PermissionRead.Level() {
+		perm = entity.PermissionRead
+	}
+	for _, g := range doc.Grant {
+		if g.Login == login && g.Permission.Level() > perm.Level() {
+			perm = g.Permission
+		}
+	}
+	return perm
+}
+
+// checkFolderAccess вычисляет право пользователя на папку с учетом родительских папок
+func (s *DocService) checkFolderAccess(ctx context.Context, folder *entity.Folder, userID string, required entity.Permission) error {
+	folder.Permission = entity.PermissionNone
+	if folder.UserID == userID {
+		folder.Permission = entity.PermissionOwner
+		return nil
+	}
+
+	currentUser, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	access, err := s.folderRepo.Permissions(ctx, []string{folder.ID}, userID, currentUser.Login)
+	if err != nil {
+		return err
+	}
+
+	folder.Permission = access[folder.ID].Permission
+	if !folder.Permission.Allows(required) {
+		return errors.ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *DocService) authorizeFolder(ctx context.Context, userID, folderID string, required entity.Permission) (*entity.Folder, error) {
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkFolderAccess(ctx, folder, userID, required); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+func (s *DocService) Update(ctx context.Context, userID, docID string, upd *entity.DocUpdate) (*entity.Document, error) {
+	doc, err := s.authorize(ctx, userID, docID, entity.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHold(doc); err != nil {
+		return nil, err
+	}
+
+	if upd.Name != nil {
+		if *upd.Name == "" {
+			return nil, errors.ErrMetaNameRequired
+		}
+		doc.Name = *upd.Name
+	}
+	if upd.Public != nil {
+		// Публикация открывает документ всем пользователям, поэтому требует права share, как и гранты
+		if !doc.Permission.Allows(entity.PermissionShare) {
+			return nil, errors.ErrAccessDenied
+		}
+		doc.Public = *upd.Public
+	}
+	if upd.Mime != nil && doc.IsFile {
+		doc.Mime = *upd.Mime
+	}
+	for key, value := range upd.Attributes {
+		if doc.Attributes == nil {
+			doc.Attributes = make(map[string]interface{})
+		}
+		if value == nil {
+			delete(doc.Attributes, key)
+		} else {
+			doc.Attributes[key] = value
+		}
+	}
+	if upd.FolderID != nil {
+		// Папка определяет унаследованные права, поэтому переносить документ может только его владелец
+		if doc.UserID != userID {
+			return nil, errors.ErrAccessDenied
+		}
+		if *upd.FolderID == "" {
+			doc.FolderID = nil
+		} else {
+			if _, err := s.authorizeFolder(ctx, userID, *upd.FolderID, entity.PermissionWrite); err != nil {
+				return nil, err
+			}
+			folderID := *upd.FolderID
+			doc.FolderID = &folderID
+		}
+	}
+
+	if err := s.docRepo.Update(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	_ = s.cache.DeleteDoc(ctx, docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, doc.UserID)
+
+	return doc, nil
+}
+
+// SetTags заменяет теги документа, требуется право write
+func (s *DocService) SetTags(ctx context.Context, userID, docID string, tags []string) (*entity.Document, error) {
+	doc, err := s.authorize(ctx, userID, docID, entity.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHold(doc); err != nil {
+		return nil, err
+	}
+
+	tags, err = normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.docRepo.SetTags(ctx, docID, tags); err != nil {
+		return nil, err
+	}
+	doc.Tags = tags
+
+	_ = s.cache.DeleteDoc(ctx, docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, doc.UserID)
+
+	return doc, nil
+}
+
+// TagFacets возвращает количество доступных пользователю документов по каждому тегу.
+// loginFilter выбирает владельца документов, как в List.
+func (s *DocService) TagFacets(ctx context.Context, userID, loginFilter string, filter entity.TagFilter) ([]entity.TagCount, error) {
+	targetUserID := userID
+	if loginFilter != "" {
+		user, err := s.userRepo.GetByLogin(ctx, loginFilter)
+		if err != nil {
+			return []entity.TagCount{}, nil
+		}
+		targetUserID = user.ID.String()
+	}
+
+	currentUser, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter.Tags, err = normalizeTags(filter.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.docRepo.TagFacets(ctx, userID, currentUser.Login, targetUserID, filter)
+}
+
+// Share заменяет список грантов документа. Пользователь без права owner не может
+// выдавать права выше своего, а также менять или отзывать такие гранты. Гранты документа
+// под legal hold не меняются.
+func (s *DocService) Share(ctx context.Context, userID, docID string, grants []entity.Grant) (*entity.Document, error) {
+	doc, err := s.authorize(ctx, userID, docID, entity.PermissionShare)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHold(doc); err != nil {
+		return nil, err
+	}
+
+	grants, err = normalizeGrants(grants)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkGrantChange(doc.Permission, doc.Grant, grants); err != nil {
+		return nil, err
+	}
+
+	if err := s.docRepo.SetGrants(ctx, docID, grants); err != nil {
+		return nil, err
+	}
+	doc.Grant = grants
+
+	_ = s.cache.DeleteDoc(ctx, docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, doc.UserID)
+
+	return doc, nil
+}
+
+// Transfer передает документ другому пользователю, гранты сохраняются. Передать документ
+// может только его владелец, грант owner для этого недостаточен.
+func (s *DocService) Transfer(ctx context.Context, userID, docID, toLogin string) (*entity.Document, error) {
+	doc, err := s.authorize(ctx, userID, docID, entity.PermissionOwner)
+	if err != nil {
+		return nil, err
+	}
+	if doc.UserID != userID {
+		return nil, errors.ErrAccessDenied
+	}
+	if err := checkHold(doc); err != nil {
+		return nil, err
+	}
+
+	newOwner, err := s.userRepo.GetByLogin(ctx, toLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	oldOwnerID := doc.UserID
+	newOwnerID := newOwner.ID.String()
+	if newOwnerID == oldOwnerID {
+		return doc, nil
+	}
+
+	if err := s.docRepo.Transfer(ctx, docID, oldOwnerID, newOwnerID, userID); err != nil {
+		return nil, err
+	}
+	doc.UserID = newOwnerID
+
+	_ = s.cache.DeleteDoc(ctx, docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, oldOwnerID)
+	_ = s.cache.InvalidateUserDocLists(ctx, newOwnerID)
+
+	if err := s.checkAccess(ctx, doc, userID, entity.PermissionNone); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// TransferAll административная операция: передает все документы fromLogin пользователю toLogin
+func (s *DocService) TransferAll(ctx context.Context, fromLogin, toLogin string) ([]string, error) {
+	from, err := s.userRepo.GetByLogin(ctx, fromLogin)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.userRepo.GetByLogin(ctx, toLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	fromID, toID := from.ID.String(), to.ID.String()
+	if fromID == toID {
+		return []string{}, nil
+	}
+
+	ids, err := s.docRepo.TransferAll(ctx, fromID, toID, "", fromLogin)
+	if err != nil {
+		s.log.Errorf("failed to transfer documents of %s to %s: %v", fromLogin, toLogin, err)
+		return nil, err
+	}
+
+	for _, id := range ids {
+		_ = s.cache.DeleteDoc(ctx, id)
+	}
+	_ = s.cache.InvalidateUserDocLists(ctx, fromID)
+	_ = s.cache.InvalidateUserDocLists(ctx, toID)
+
+	s.log.Infof("transferred %d documents from %s to %s", len(ids), fromLogin, toLogin)
+
+	return ids, nil
+}
+
+// Delete удаляет документ. Документ под legal hold или правилом хранения не удаляется
+// (ErrLegalHold, ErrRetentionActive), правила хранения проверяет триггер в БД.
+func (s *DocService) Delete(ctx context.Context, userID, docID string) error {
+
+	doc, err := s.authorize(ctx, userID, docID, entity.PermissionOwner)
+	if err != nil {
+		return err
+	}
+	if err := checkHold(doc); err != nil {
+		return err
+	}
+
+	err = s.docRepo.Delete(ctx, docID)
+	if err != nil {
+		return err
+	}
+
+	_ = s.cache.DeleteDoc(ctx, docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, doc.UserID)
+
+	return nil
+}
+
+// parseGrants разбирает meta.grant: поддерживается как старый формат (список логинов,
+// право read), так и список объектов {"login": ..., "permission": ...}
+func parseGrants(raw interface{}) ([]entity.Grant, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.ErrInvalidGrant
+	}
+
+	grants := make([]entity.Grant, 0, len(list))
+	for _, item := range list {
+		switch g := item.(type) {
+		case string:
+			grants = append(grants, entity.Grant{Login: g, Permission: entity.PermissionRead})
+		case map[string]interface{}:
+			login, _ := g["login"].(string)
+			perm, _ := g["permission"].(string)
+			if perm == "" {
+				perm = string(entity.PermissionRead)
+			}
+			grants = append(grants, entity.Grant{Login: login, Permission: entity.Permission(perm)})
+		default:
+			return nil, errors.ErrInvalidGrant
+		}
+	}
+
+	return normalizeGrants(grants)
+}
+
+// parseExpiry вычисляет срок жизни документа из meta.expires_at (RFC 3339) или meta.ttl
+// (секунды либо строка вида "36h"). Задать можно только одно из них.
+func parseExpiry(rawAt, rawTTL interface{}, now time.Time) (*time.Time, error) {
+	if rawAt == nil && rawTTL == nil {
+		return nil, nil
+	}
+	if rawAt != nil && rawTTL != nil {
+		return nil, errors.ErrInvalidExpiry
+	}
+
+	var expiresAt time.Time
+	if rawAt != nil {
+		str, ok := rawAt.(string)
+		if !ok {
+			return nil, errors.ErrInvalidExpiry
+		}
+		t, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return nil, errors.ErrInvalidExpiry
+		}
+		expiresAt = t
+	} else {
+		var ttl time.Duration
+		switch v := rawTTL.(type) {
+		case float64:
+			ttl = time.Duration(v * float64(time.Second))
+		case string:
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, errors.ErrInvalidExpiry
+			}
+			ttl = d
+		default:
+			return nil, errors.ErrInvalidExpiry
+		}
+		if ttl <= 0 {
+			return nil, errors.ErrInvalidExpiry
+		}
+		expiresAt = now.Add(ttl)
+	}
+
+	if !expiresAt.After(now) {
+		return nil, errors.ErrInvalidExpiry
+	}
+	expiresAt = expiresAt.UTC()
+	return &expiresAt, nil
+}
+
+// earliestExpiry возвращает самый ранний срок жизни среди docs или nil, если его нет ни у одного
+func earliestExpiry(docs []*entity.Document) *time.Time {
+	var earliest *time.Time
+	for _, doc := range docs {
+		if doc.ExpiresAt != nil && (earliest == nil || doc.ExpiresAt.Before(*earliest)) {
+			earliest = doc.ExpiresAt
+		}
+	}
+	return earliest
+}
+
+func parseTags(raw interface{}) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.ErrInvalidTags
+	}
+
+	tags := make([]string, 0, len(list))
+	for _, item := range list {
+		tag, ok := item.(string)
+		if !ok {
+			return nil, errors.ErrInvalidTags
+		}
+		tags = append(tags, tag)
+	}
+
+	return normalizeTags(tags)
+}
+
+// normalizeTags обрезает пробелы и убирает дубликаты, пустые и слишком длинные теги запрещены
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || utf8.RuneCountInString(tag) > entity.MaxTagLength {
+			return nil, errors.ErrInvalidTags
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// checkGrantChange запрещает пользователю без права owner выдавать права выше своего,
+// а также менять или отзывать такие гранты
+func checkGrantChange(own entity.Permission, oldList, newList []entity.Grant) error {
+	if own == entity.PermissionOwner {
+		return nil
+	}
+
+	oldGrants := make(map[string]entity.Permission, len(oldList))
+	for _, g := range oldList {
+		oldGrants[g.Login] = g.Permission
+	}
+	newGrants := make(map[string]entity.Permission, len(newList))
+	for _, g := range newList {
+		newGrants[g.Login] = g.Permission
+		if g.Permission.Level() > own.Level() && oldGrants[g.Login] != g.Permission {
+			return errors.ErrPermissionEscalation
+		}
+	}
+	for login, perm := range oldGrants {
+		if perm.Level() > own.Level() && newGrants[login] != perm {
+			return errors.ErrPermissionEscalation
+		}
+	}
+	return nil
+}
+
+func normalizeGrants(grants []entity.Grant) ([]entity.Grant, error) {
+	result := make([]entity.Grant, 0, len(grants))
+	seen := make(map[string]int, len(grants))
+	for _, g := range grants {
+		if g.Login == "" {
+			return nil, errors.ErrInvalidGrant
+		}
+		if g.Permission == entity.PermissionNone {
+			g.Permission = entity.PermissionRead
+		}
+		if !g.Permission.Valid() {
+			return nil, errors.ErrInvalidPermission
+		}
+		if i, ok := seen[g.Login]; ok {
+			if g.Permission.Level() > result[i].Permission.Level() {
+				result[i].Permission = g.Permission
+			}
+			continue
+		}
+		seen[g.Login] = len(result)
+		result = append(result, g)
+	}
+	return result, nil
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+type FolderService struct {
+	folderRepo repository.Folder
+	docRepo    repository.Doc
+	userRepo   repository.User
+	docs       *DocService
+	cache      cache.Doc
+	log        *logrus.Logger
+}
+
+func NewFolderService(folderRepo repository.Folder, docRepo repository.Doc, userRepo repository.User, docs *DocService, cache cache.Doc, log *logrus.Logger) *FolderService {
+	return &FolderService{
+		folderRepo: folderRepo,
+		docRepo:    docRepo,
+		userRepo:   userRepo,
+		docs:       docs,
+		cache:      cache,
+		log:        log,
+	}
+}
+
+func (s *FolderService) Create(ctx context.Context, userID string, req *entity.CreateFolderRequest) (*entity.Folder, error) {
+	if err := validateFolderName(req.Name); err != nil {
+		return nil, err
+	}
+
+	folder := &entity.Folder{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Name:       req.Name,
+		CreatedAt:  time.Now(),
+		Permission: entity.PermissionOwner,
+	}
+
+	if req.ParentID != nil && *req.ParentID != "" {
+		parent, err := s.docs.authorizeFolder(ctx, userID, *req.ParentID, entity.PermissionWrite)
+		if err != nil {
+			return nil, err
+		}
+		// Дерево папок принадлежит одному владельцу
+		folder.UserID = parent.UserID
+		folder.ParentID = &parent.ID
+		folder.Permission = parent.Permission
+	}
+
+	if err := s.folderRepo.Create(ctx, folder); err != nil {
+		if err != errors.ErrFolderAlreadyExist {
+			s.log.Errorf("failed to create folder in DB: %v", err)
+		}
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// Get возвращает папку и ее непосредственное содержимое
+func (s *FolderService) Get(ctx context.Context, userID, folderID string) (*entity.FolderContents, error) {
+	folder, err := s.docs.authorizeFolder(ctx, userID, folderID, entity.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.contents(ctx, userID, folder.UserID, folder)
+}
+
+// Root возвращает содержимое корня пользователя
+func (s *FolderService) Root(ctx context.Context, userID string) (*entity.FolderContents, error) {
+	return s.contents(ctx, userID, userID, nil)
+}
+
+func (s *FolderService) Update(ctx context.Context, userID, folderID string, upd *entity.FolderUpdate) (*entity.Folder, error) {
+	folder, err := s.docs.authorizeFolder(ctx, userID, folderID, entity.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if upd.Name != nil {
+		if err := validateFolderName(*upd.Name); err != nil {
+			return nil, err
+		}
+		folder.Name = *upd.Name
+	}
+
+	if upd.ParentID != nil {
+		if *upd.ParentID == "" {
+			folder.ParentID = nil
+		} else {
+			parent, err := s.docs.authorizeFolder(ctx, userID, *upd.ParentID, entity.PermissionWrite)
+			if err != nil {
+				return nil, err
+			}
+			if parent.UserID != folder.UserID {
+				return nil, errors.ErrFolderOwnerMismatch
+			}
+
+			cycle, err := s.folderRepo.IsDescendant(ctx, folder.ID, parent.ID)
+			if err != nil {
+				return nil, err
+			}
+			if cycle {
+				return nil, errors.ErrFolderCycle
+			}
+			folder.ParentID = &parent.ID
+		}
+	}
+
+	if err := s.folderRepo.Update(ctx, folder); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// Delete рекурсивно удаляет папку вместе с вложенными папками и документами ее владельца,
+// документы других пользователей переносятся в их корень
+func (s *FolderService) Delete(ctx context.Context, userID, folderID string) error {
+	folder, err := s.docs.authorizeFolder(ctx, userID, folderID, entity.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	docs, err := s.folderRepo.Delete(ctx, folder.ID)
+	if err != nil {
+		return err
+	}
+
+	owners := map[string]struct{}{folder.UserID: {}}
+	for _, doc := range docs {
+		_ = s.cache.DeleteDoc(ctx, doc.ID)
+		owners[doc.UserID] = struct{}{}
+	}
+	for ownerID := range owners {
+		_ = s.cache.InvalidateUserDocLists(ctx, ownerID)
+	}
+
+	return nil
+}
+
+// Share заменяет гранты папки, они наследуются всеми вложенными папками и документами
+func (s *FolderService) Share(ctx context.Context, userID, folderID string, grants []entity.Grant) (*entity.Folder, error) {
+	folder, err := s.docs.authorizeFolder(ctx, userID, folderID, entity.PermissionShare)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err = normalizeGrants(grants)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkGrantChange(folder.Permission, folder.Grant, grants); err != nil {
+		return nil, err
+	}
+
+	if err := s.folderRepo.SetGrants(ctx, folder.ID, grants); err != nil {
+		return nil, err
+	}
+	folder.Grant = grants
+
+	return folder, nil
+}
+
+// Resolve находит папку или документ по пути вида "a/b/doc.txt" в пространстве пользователя ownerLogin
+// (по умолчанию текущего). Пустой путь означает корень, тогда оба результата nil.
+// В чужом дереве отсутствующий и недоступный путь неразличимы: оба дают ErrFolderNotFound,
+// чтобы по 403 и 404 нельзя было узнать, какие папки и документы существуют.
+func (s *FolderService) Resolve(ctx context.Context, userID, ownerLogin, path string) (*entity.Folder, *entity.Document, error) {
+	if ownerLogin == "" {
+		return s.resolvePath(ctx, userID, userID, path)
+	}
+
+	owner, err := s.userRepo.GetByLogin(ctx, ownerLogin)
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			return nil, nil, errors.ErrFolderNotFound
+		}
+		return nil, nil, err
+	}
+
+	folder, doc, err := s.resolvePath(ctx, userID, owner.ID.String(), path)
+	if owner.ID.String() != userID {
+		switch err {
+		case errors.ErrAccessDenied, errors.ErrDocNotFound:
+			return nil, nil, errors.ErrFolderNotFound
+		}
+	}
+	return folder, doc, err
+}
+
+func (s *FolderService) resolvePath(ctx context.Context, userID, ownerID, path string) (*entity.Folder, *entity.Document, error) {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+
+	if len(segments) == 0 {
+		if ownerID != userID {
+			return nil, nil, errors.ErrAccessDenied
+		}
+		return nil, nil, nil
+	}
+
+	var parent *entity.Folder
+	for i, seg := range segments {
+		var parentID *string
+		if parent != nil {
+			parentID = &parent.ID
+		}
+
+		folder, err := s.folderRepo.GetByName(ctx, ownerID, parentID, seg)
+		if err == nil {
+			parent = folder
+			continue
+		}
+		if err != errors.ErrFolderNotFound {
+			return nil, nil, err
+		}
+
+		// Последний сегмент может быть именем документа
+		if i != len(segments)-1 {
+			return nil, nil, err
+		}
+
+		doc, err := s.docRepo.GetByName(ctx, ownerID, parentID, seg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.docs.checkAccess(ctx, doc, userID, entity.PermissionRead); err != nil {
+			return nil, nil, err
+		}
+		if err := s.docs.loadContent(ctx, doc, userID); err != nil {
+			return nil, nil, err
+		}
+		return nil, doc, nil
+	}
+
+	if err := s.docs.checkFolderAccess(ctx, parent, userID, entity.PermissionRead); err != nil {
+		return nil, nil, err
+	}
+
+	return parent, nil, nil
+}
+
+func (s *FolderService) contents(ctx context.Context, userID, ownerID string, folder *entity.Folder) (*entity.FolderContents, error) {
+	var folderID *string
+	if folder != nil {
+		folderID = &folder.ID
+	}
+
+	folders, err := s.folderRepo.ListChildren(ctx, ownerID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(folders))
+	for i, f := range folders {
+		ids[i] = f.ID
+	}
+
+	currentUser, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.folderRepo.Permissions(ctx, ids, userID, currentUser.Login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder permissions: %w", err)
+	}
+	for _, f := range folders {
+		f.Permission = access[f.ID].Permission
+	}
+
+	docs, err := s.docRepo.ListByFolder(ctx, ownerID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err = s.docs.filterReadable(ctx, docs, userID, currentUser.Login)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.FolderContents{
+		Folder:  folder,
+		Folders: folders,
+		Docs:    docs,
+	}, nil
+}
+
+func validateFolderName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return errors.ErrInvalidFolderName
+	}
+	return nil
+}
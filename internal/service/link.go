@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const linkTokenBytes = 32
+
+type LinkService struct {
+	linkRepo repository.ShareLink
+	docRepo  repository.Doc
+	docs     *DocService
+	cache    cache.Link
+	cfg      *config.Config
+	log      *logrus.Logger
+}
+
+func NewLinkService(linkRepo repository.ShareLink, docRepo repository.Doc, docs *DocService, cache cache.Link, cfg *config.Config, log *logrus.Logger) *LinkService {
+	return &LinkService{
+		linkRepo: linkRepo,
+		docRepo:  docRepo,
+		docs:     docs,
+		cache:    cache,
+		cfg:      cfg,
+		log:      log,
+	}
+}
+
+func (s *LinkService) Create(ctx context.Context, userID, docID string, req *entity.CreateLinkRequest) (*entity.ShareLink, error) {
+	if _, err := s.docs.authorize(ctx, userID, docID, entity.PermissionShare); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(s.cfg.LinkTTL) * time.Hour)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	} else if req.ExpiresIn > 0 {
+		expiresAt = now.Add(time.Duration(req.ExpiresIn) * time.Second)
+	}
+	if !expiresAt.After(now) || expiresAt.After(now.Add(time.Duration(s.cfg.LinkMaxTTL)*time.Hour)) {
+		return nil, errors.ErrInvalidLinkExpiry
+	}
+
+	if req.MaxDownloads < 0 {
+		return nil, errors.ErrInvalidLinkDownloads
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+
+	link := &entity.ShareLink{
+		ID:           uuid.New().String(),
+		DocumentID:   docID,
+		CreatedBy:    userID,
+		TokenHash:    hashLinkToken(token),
+		ExpiresAt:    expiresAt,
+		MaxDownloads: req.MaxDownloads,
+		CreatedAt:    now,
+		Token:        token,
+	}
+
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash share link password: %w", err)
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	if err := s.linkRepo.Create(ctx, link); err != nil {
+		s.log.Errorf("failed to create share link in DB: %v", err)
+		return nil, fmt.Errorf("failed to create share link in DB")
+	}
+
+	return link, nil
+}
+
+func (s *LinkService) List(ctx context.Context, userID, docID string) ([]*entity.ShareLink, error) {
+	if _, err := s.docs.authorize(ctx, userID, docID, entity.PermissionShare); err != nil {
+		return nil, err
+	}
+
+	links, err := s.linkRepo.ListByDoc(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(links))
+	for i, link := range links {
+		ids[i] = link.ID
+	}
+
+	downloads, err := s.cache.GetLinkDownloads(ctx, ids)
+	if err != nil {
+		s.log.Errorf("Error getting share link counters from cache: %v", err)
+	}
+	for _, link := range links {
+		link.Downloads = downloads[link.ID]
+	}
+
+	return links, nil
+}
+
+func (s *LinkService) Revoke(ctx context.Context, userID, docID, linkID string) error {
+	if _, err := s.docs.authorize(ctx, userID, docID, entity.PermissionShare); err != nil {
+		return err
+	}
+
+	if err := s.linkRepo.Revoke(ctx, docID, linkID); err != nil {
+		return err
+	}
+
+	_ = s.cache.DeleteLinkDownloads(ctx, linkID)
+	_ = s.cache.DeleteLinkFailures(ctx, linkID)
+
+	return nil
+}
+
+// Open проверяет ссылку и пароль, атомарно учитывает скачивание и возвращает документ
+func (s *LinkService) Open(ctx context.Context, token, password string) (*entity.Document, error) {
+	link, err := s.linkRepo.GetByTokenHash(ctx, hashLinkToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if link.RevokedAt != nil || !time.Now().Before(link.ExpiresAt) {
+		return nil, errors.ErrShareLinkExpired
+	}
+
+	if link.HasPassword() {
+		if err := s.checkPassword(ctx, link, password); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := s.docRepo.GetByID(ctx, link.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+	// Ссылка действует, пока у ее автора есть право share: после отзыва гранта или
+	// передачи документа его ссылки перестают открываться
+	if err := s.docs.checkAccess(ctx, doc, link.CreatedBy, entity.PermissionShare); err != nil {
+		if err == errors.ErrAccessDenied || err == errors.ErrUserNotFound {
+			return nil, errors.ErrShareLinkExpired
+		}
+		return nil, err
+	}
+	if err := s.docs.loadContent(ctx, doc, ""); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.cache.IncrLinkDownloads(ctx, link.ID, link.MaxDownloads, link.ExpiresAt); err != nil {
+		doc.CloseContent()
+		return nil, err
+	}
+
+	doc.Permission = entity.PermissionRead
+
+	return doc, nil
+}
+
+// checkPassword сверяет пароль ссылки. Неверные пароли считаются в Redis, после
+// LinkMaxAttempts ошибок ссылка возвращает ErrShareLinkLocked без проверки bcrypt
+// до конца окна LinkLockout.
+func (s *LinkService) checkPassword(ctx context.Context, link *entity.ShareLink, password string) error {
+	limit := int64(s.cfg.LinkMaxAttempts)
+	if limit > 0 {
+		failures, err := s.cache.GetLinkFailures(ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		if failures >= limit {
+			return errors.ErrShareLinkLocked
+		}
+	}
+
+	if password != "" && bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) == nil {
+		if limit > 0 {
+			_ = s.cache.DeleteLinkFailures(ctx, link.ID)
+		}
+		return nil
+	}
+
+	if limit > 0 && password != "" {
+		if _, err := s.cache.IncrLinkFailures(ctx, link.ID, time.Duration(s.cfg.LinkLockout)*time.Second); err != nil {
+			s.log.Errorf("Error counting wrong share link password for %s: %v", link.ID, err)
+		}
+	}
+	return errors.ErrShareLinkPassword
+}
+
+func newLinkToken() (string, error) {
+	buf := make([]byte, linkTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashLinkToken в БД хранится только хеш токена, сам токен отдается один раз при создании
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
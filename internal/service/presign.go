@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+)
+
+const (
+	DocsPath = "/api/docs/"
+
+	SignatureParam = "X-Signature"
+	KeyIDParam     = "X-Key-Id"
+	UserParam      = "X-User"
+	ExpiresParam   = "X-Expires"
+)
+
+type PresignService struct {
+	docs *DocService
+	cfg  *config.Config
+}
+
+func NewPresignService(docs *DocService, cfg *config.Config) *PresignService {
+	return &PresignService{
+		docs: docs,
+		cfg:  cfg,
+	}
+}
+
+// PresignDownload подписывает GET ссылку на документ, доступный пользователю на чтение
+func (s *PresignService) PresignDownload(ctx context.Context, userID, docID string, ttl time.Duration) (*entity.PresignedURL, error) {
+	if _, err := s.docs.authorize(ctx, userID, docID, entity.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	return s.sign(http.MethodGet, docID, userID, ttl)
+}
+
+// PresignUpload резервирует ID нового документа и подписывает PUT ссылку для прямой загрузки
+func (s *PresignService) PresignUpload(ctx context.Context, userID string, ttl time.Duration) (*entity.PresignedURL, error) {
+	return s.sign(http.MethodPut, uuid.New().String(), userID, ttl)
+}
+
+// Verify проверяет подпись запроса и возвращает ID пользователя, от имени которого она выдана
+func (s *PresignService) Verify(method, path string, query url.Values) (string, error) {
+	keyID := query.Get(KeyIDParam)
+	userID := query.Get(UserParam)
+	expires := query.Get(ExpiresParam)
+	signature := query.Get(SignatureParam)
+
+	if keyID == "" || userID == "" || expires == "" || signature == "" {
+		return "", errors.ErrInvalidSignature
+	}
+
+	key, ok := s.cfg.PresignKeys[keyID]
+	if !ok || key == "" {
+		return "", errors.ErrUnknownSigningKey
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", errors.ErrInvalidSignature
+	}
+
+	// HEAD допускается по подписи для GET
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	expected := computeSignature(key, method, path, expires, userID, keyID)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", errors.ErrInvalidSignature
+	}
+
+	if time.Now().Unix() >= expiresAt {
+		return "", errors.ErrSignatureExpired
+	}
+
+	return userID, nil
+}
+
+func (s *PresignService) sign(method, docID, userID string, ttl time.Duration) (*entity.PresignedURL, error) {
+	keyID := s.cfg.PresignKeyID
+	key, ok := s.cfg.PresignKeys[keyID]
+	if keyID == "" || !ok || key == "" {
+		return nil, errors.ErrPresignNotConfigured
+	}
+
+	if ttl == 0 {
+		ttl = time.Duration(s.cfg.PresignTTL) * time.Second
+	}
+	if ttl < 0 || ttl > time.Duration(s.cfg.PresignMaxTTL)*time.Second {
+		return nil, errors.ErrInvalidPresignTTL
+	}
+
+	path := DocsPath + docID
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set(KeyIDParam, keyID)
+	query.Set(UserParam, userID)
+	query.Set(ExpiresParam, expires)
+	query.Set(SignatureParam, computeSignature(key, method, path, expires, userID, keyID))
+
+	return &entity.PresignedURL{
+		Method:     method,
+		URL:        path + "?" + query.Encode(),
+		DocumentID: docID,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// computeSignature HMAC-SHA256 над методом, путем, сроком действия и пользователем
+func computeSignature(key, method, path, expires, userID, keyID string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(method + "\n" + path + "\n" + expires + "\n" + userID + "\n" + keyID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// DocReaper периодически удаляет документы с истекшим сроком жизни. До удаления
+// они уже скрыты запросами репозитория, поэтому задержка очистки не видна клиентам.
+type DocReaper struct {
+	docRepo repository.Doc
+	cache   cache.Doc
+	cfg     *config.Config
+	log     *logrus.Logger
+}
+
+func NewDocReaper(docRepo repository.Doc, cache cache.Doc, cfg *config.Config, log *logrus.Logger) *DocReaper {
+	return &DocReaper{
+		docRepo: docRepo,
+		cache:   cache,
+		cfg:     cfg,
+		log:     log,
+	}
+}
+
+// Run запускает очистку раз в DOC_REAPER_INTERVAL и блокируется до отмены ctx
+func (r *DocReaper) Run(ctx context.Context) {
+	if r.cfg.DocReaperInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(r.cfg.DocReaperInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reap(ctx)
+		}
+	}
+}
+
+func (r *DocReaper) reap(ctx context.Context) {
+	batch := r.cfg.DocReaperBatch
+	if batch <= 0 {
+		batch = 500
+	}
+
+	var total int
+	for ctx.Err() == nil {
+		docs, err := r.docRepo.DeleteExpired(ctx, batch)
+		if err != nil {
+			r.log.Errorf("expired document cleanup failed: %v", err)
+			break
+		}
+
+		ids := make([]string, len(docs))
+		owners := make(map[string]struct{})
+		for i, doc := range docs {
+			ids[i] = doc.ID
+			owners[doc.UserID] = struct{}{}
+		}
+		_ = r.cache.DeleteDocs(ctx, ids)
+		for ownerID := range owners {
+			_ = r.cache.InvalidateUserDocLists(ctx, ownerID)
+		}
+
+		total += len(docs)
+		if len(docs) < batch {
+			break
+		}
+	}
+	if total > 0 {
+		r.log.Infof("removed %d expired documents", total)
+	}
+}
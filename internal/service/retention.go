@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionService административное управление правилами хранения и legal hold.
+// Запреты применяет триггер в БД, поэтому они действуют для любого пути удаления.
+type RetentionService struct {
+	retentionRepo repository.Retention
+	userRepo      repository.User
+	folderRepo    repository.Folder
+	cache         cache.Doc
+	log           *logrus.Logger
+}
+
+func NewRetentionService(retentionRepo repository.Retention, userRepo repository.User, folderRepo repository.Folder, cache cache.Doc, log *logrus.Logger) *RetentionService {
+	return &RetentionService{
+		retentionRepo: retentionRepo,
+		userRepo:      userRepo,
+		folderRepo:    folderRepo,
+		cache:         cache,
+		log:           log,
+	}
+}
+
+func (s *RetentionService) ListRules(ctx context.Context) ([]*entity.RetentionRule, error) {
+	return s.retentionRepo.ListRules(ctx)
+}
+
+// CreateRule добавляет правило хранения. Правило действует сразу, в том числе на уже
+// загруженные документы, и нужно хотя бы одно условие, чтобы не заблокировать все хранилище.
+func (s *RetentionService) CreateRule(ctx context.Context, req *entity.CreateRetentionRuleRequest) (*entity.RetentionRule, error) {
+	if req.Owner == "" && req.Tag == "" && req.FolderID == "" && req.Mime == "" {
+		return nil, errors.ErrInvalidRetentionRule
+	}
+	if !req.RetainUntil.After(time.Now()) {
+		return nil, errors.ErrInvalidRetentionRule
+	}
+
+	rule := &entity.RetentionRule{
+		Owner:       req.Owner,
+		FolderID:    req.FolderID,
+		Mime:        req.Mime,
+		RetainUntil: req.RetainUntil.UTC(),
+		Reason:      req.Reason,
+	}
+
+	if req.Owner != "" {
+		owner, err := s.userRepo.GetByLogin(ctx, req.Owner)
+		if err != nil {
+			return nil, err
+		}
+		rule.OwnerID = owner.ID.String()
+	}
+
+	if req.FolderID != "" {
+		if _, err := uuid.Parse(req.FolderID); err != nil {
+			return nil, errors.ErrFolderNotFound
+		}
+		if _, err := s.folderRepo.GetByID(ctx, req.FolderID); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Tag != "" {
+		tags, err := normalizeTags([]string{req.Tag})
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) == 0 {
+			return nil, errors.ErrInvalidRetentionRule
+		}
+		rule.Tag = tags[0]
+	}
+
+	if err := s.retentionRepo.CreateRule(ctx, rule); err != nil {
+		s.log.Errorf("failed to create retention rule in DB: %v", err)
+		return nil, err
+	}
+
+	s.log.Infof("retention rule %s created until %s", rule.ID, rule.RetainUntil.Format(time.RFC3339))
+
+	return rule, nil
+}
+
+func (s *RetentionService) DeleteRule(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errors.ErrRetentionRuleNotFound
+	}
+	if err := s.retentionRepo.DeleteRule(ctx, id); err != nil {
+		return err
+	}
+
+	s.log.Infof("retention rule %s deleted", id)
+
+	return nil
+}
+
+// SetLegalHold ставит или снимает legal hold документа и возвращает его ограничения
+func (s *RetentionService) SetLegalHold(ctx context.Context, docID string, hold bool) (*entity.RetentionStatus, error) {
+	if _, err := uuid.Parse(docID); err != nil {
+		return nil, errors.ErrDocNotFound
+	}
+
+	ownerID, err := s.retentionRepo.SetLegalHold(ctx, docID, hold)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.cache.DeleteDoc(ctx, docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, ownerID)
+
+	s.log.Infof("legal hold of document %s set to %t", docID, hold)
+
+	until, err := s.retentionRepo.RetainedUntil(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.RetentionStatus{DocumentID: docID, LegalHold: hold, RetainedUntil: until}, nil
+}
+
+// checkHold запрещает менять документ под legal hold. Удаление и изменение полей документа
+// дополнительно проверяет триггер в БД, теги - только эта проверка.
+func checkHold(doc *entity.Document) error {
+	if doc.LegalHold {
+		return errors.ErrLegalHold
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/paudarco/doc-storage/pkg/envelope"
+	"github.com/sirupsen/logrus"
+)
+
+// KeyRotator шифрует данные, записанные открытыми, и переоборачивает ключи данных активным
+// мастер-ключом. Уже зашифрованное содержимое не перешифровывается.
+type KeyRotator struct {
+	keyRepo repository.Key
+	keys    *envelope.Keyring
+	log     *logrus.Logger
+}
+
+func NewKeyRotator(keyRepo repository.Key, keys *envelope.Keyring, log *logrus.Logger) *KeyRotator {
+	return &KeyRotator{
+		keyRepo: keyRepo,
+		keys:    keys,
+		log:     log,
+	}
+}
+
+// Rotate сначала шифрует открытые документы (им создаются ключи) и блобы, затем обходит ключи
+// блобов и документов пачками по batch. Строка, измененная или удаленная во время ротации,
+// пропускается: новая запись уже зашифрована и обернута активным ключом.
+func (r *KeyRotator) Rotate(ctx context.Context, batch int) (*entity.RotateReport, error) {
+	if !r.keys.Enabled() {
+		return nil, envelope.ErrDisabled
+	}
+	if batch <= 0 {
+		batch = 500
+	}
+
+	report := &entity.RotateReport{ActiveKeyID: r.keys.ActiveID()}
+	var err error
+	report.EncryptedDocuments, err = r.seal(ctx, entity.KeyOwnerDocument, r.keyRepo.PlainDocs, r.keyRepo.SealDoc, batch, report)
+	if err != nil {
+		return nil, err
+	}
+	// Блоб шифруется, только когда у всех ссылающихся документов уже есть ключ
+	report.EncryptedBlobs, err = r.seal(ctx, entity.KeyOwnerBlob, r.keyRepo.PlainBlobs, r.keyRepo.SealBlob, batch, report)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, owner := range []string{entity.KeyOwnerBlob, entity.KeyOwnerDocument} {
+		after := ""
+		for {
+			stale, err := r.keyRepo.Stale(ctx, owner, r.keys.ActiveID(), after, batch)
+			if err != nil {
+				return nil, err
+			}
+			if len(stale) == 0 {
+				break
+			}
+
+			for _, key := range stale {
+				keyID, wrapped, err := r.keys.Rewrap(key.KeyID, key.Wrapped)
+				if err != nil {
+					r.log.Errorf("%s %s: cannot unwrap data key %s: %s", owner, key.ID, key.KeyID, err.Error())
+					return nil, err
+				}
+				ok, err := r.keyRepo.Replace(ctx, key, keyID, wrapped)
+				if err != nil {
+					return nil, err
+				}
+				switch {
+				case !ok:
+					report.Skipped++
+				case owner == entity.KeyOwnerBlob:
+					report.Blobs++
+				default:
+					report.Documents++
+				}
+			}
+			after = stale[len(stale)-1].ID
+		}
+	}
+	return report, nil
+}
+
+// seal шифрует строки, которые возвращает list, по одной через seal и возвращает их число
+func (r *KeyRotator) seal(ctx context.Context, owner string,
+	list func(ctx context.Context, after string, limit int) ([]string, error),
+	seal func(ctx context.Context, id string) (bool, error),
+	batch int, report *entity.RotateReport) (int, error) {
+	sealed, after := 0, ""
+	for {
+		ids, err := list(ctx, after, batch)
+		if err != nil {
+			return 0, err
+		}
+		if len(ids) == 0 {
+			return sealed, nil
+		}
+
+		for _, id := range ids {
+			ok, err := seal(ctx, id)
+			if err != nil {
+				r.log.Errorf("%s %s: cannot encrypt: %s", owner, id, err.Error())
+				return 0, err
+			}
+			if ok {
+				sealed++
+			} else {
+				report.Skipped++
+			}
+		}
+		after = ids[len(ids)-1]
+	}
+}
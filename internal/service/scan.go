@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/paudarco/doc-storage/pkg/scan"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ScannerNone  = "none"
+	ScannerClamd = "clamd"
+
+	maxQuarantineLimit = 1000
+)
+
+type scanJob struct {
+	docID  string
+	userID string
+	hash   string
+	data   []byte
+}
+
+// ContentScanner проверяет загруженные файлы антивирусом в фоне. Пока проверка не завершена
+// или найдена угроза, содержимое отдается только владельцу.
+type ContentScanner struct {
+	scanRepo repository.Scan
+	blobRepo repository.Blob
+	cache    cache.Doc
+	scanner  scan.Scanner
+	enabled  bool
+	jobs     chan scanJob
+	cfg      *config.Config
+	log      *logrus.Logger
+}
+
+func NewContentScanner(scanRepo repository.Scan, blobRepo repository.Blob, cache cache.Doc, cfg *config.Config, log *logrus.Logger) *ContentScanner {
+	var scanner scan.Scanner = scan.Noop{}
+	enabled := cfg.Scanner == ScannerClamd
+	if enabled {
+		scanner = scan.NewClamd(cfg.ClamdAddress, time.Duration(cfg.ScanTimeout)*time.Second)
+	} else if cfg.Scanner != ScannerNone && cfg.Scanner != "" {
+		log.Warnf("unknown SCANNER %q, uploads are not scanned", cfg.Scanner)
+	}
+
+	return &ContentScanner{
+		scanRepo: scanRepo,
+		blobRepo: blobRepo,
+		cache:    cache,
+		scanner:  scanner,
+		enabled:  enabled,
+		jobs:     make(chan scanJob, cfg.ScanQueueSize),
+		cfg:      cfg,
+		log:      log,
+	}
+}
+
+// Status возвращает начальный статус проверки для нового документа. Без сканера файлы сразу чистые.
+func (s *ContentScanner) Status(doc *entity.Document) string {
+	if !doc.IsFile || doc.ContentHash == "" {
+		return entity.ScanStatusNone
+	}
+	if !s.enabled {
+		return entity.ScanStatusClean
+	}
+	return entity.ScanStatusPending
+}
+
+// Enqueue ставит документ в очередь на проверку. При переполненной очереди документ остается
+// в статусе pending и будет проверен периодическим обходом.
+func (s *ContentScanner) Enqueue(ctx context.Context, doc *entity.Document) {
+	if doc.ScanStatus != entity.ScanStatusPending {
+		return
+	}
+
+	job := scanJob{docID: doc.ID, userID: doc.UserID, hash: doc.ContentHash, data: doc.FileData}
+	select {
+	case s.jobs <- job:
+	default:
+		s.log.Warnf("scan queue is full, document %s is left for the next sweep", doc.ID)
+	}
+}
+
+// Run запускает воркеры и обход документов, оставшихся в pending (очередь переполнилась
+// или сервер перезапускался), и блокируется до отмены ctx
+func (s *ContentScanner) Run(ctx context.Context) {
+	workers := s.cfg.ScanWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.jobs:
+					_ = s.process(ctx, job)
+				}
+			}
+		}()
+	}
+
+	if s.cfg.ScanSweepInterval > 0 {
+		interval := time.Duration(s.cfg.ScanSweepInterval) * time.Second
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.sweep(ctx, 0)
+		for {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			case <-ticker.C:
+				s.sweep(ctx, interval)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+// sweep проверяет документы, ожидающие проверки дольше olderThan. Свежие документы
+// еще могут стоять в очереди, их не трогаем.
+func (s *ContentScanner) sweep(ctx context.Context, olderThan time.Duration) {
+	for ctx.Err() == nil {
+		docs, err := s.scanRepo.Pending(ctx, olderThan, 100)
+		if err != nil {
+			s.log.Errorf("failed to list documents pending scan: %v", err)
+			return
+		}
+		if len(docs) == 0 {
+			return
+		}
+		for _, doc := range docs {
+			// Если статус не сохранился, документ остался в pending и вернется в следующей выборке,
+			// поэтому обход прерывается до следующего тика, а не крутится на тех же документах
+			if err := s.process(ctx, scanJob{docID: doc.ID, userID: doc.UserID, hash: doc.ContentHash}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// process проверяет документ и возвращает ошибку, только если результат не удалось сохранить
+func (s *ContentScanner) process(ctx context.Context, job scanJob) error {
+	if job.data == nil {
+		data, err := s.blobRepo.Get(ctx, job.hash)
+		if err != nil {
+			s.log.Errorf("failed to load content of document %s for scan: %v", job.docID, err)
+			return s.save(ctx, job, entity.ScanStatusError, err.Error())
+		}
+		job.data = data
+	}
+
+	result, err := s.scanner.Scan(ctx, bytes.NewReader(job.data))
+	switch {
+	case err != nil:
+		s.log.Warnf("scan of document %s failed: %v", job.docID, err)
+		return s.save(ctx, job, entity.ScanStatusError, err.Error())
+	case result.Infected:
+		s.log.Warnf("document %s is infected: %s", job.docID, result.Signature)
+		return s.save(ctx, job, entity.ScanStatusInfected, result.Signature)
+	default:
+		return s.save(ctx, job, entity.ScanStatusClean, "")
+	}
+}
+
+func (s *ContentScanner) save(ctx context.Context, job scanJob, status, signature string) error {
+	if err := s.scanRepo.SetStatus(ctx, job.docID, status, signature); err != nil {
+		// Документ удален до завершения проверки, сохранять нечего
+		if err == errors.ErrDocNotFound {
+			return nil
+		}
+		s.log.Errorf("failed to save scan result for document %s: %v", job.docID, err)
+		return err
+	}
+
+	_ = s.cache.DeleteDoc(ctx, job.docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, job.userID)
+	return nil
+}
+
+// Quarantine возвращает документы с указанными статусами проверки, по умолчанию зараженные
+func (s *ContentScanner) Quarantine(ctx context.Context, statuses []string, limit int) ([]*entity.QuarantinedDoc, error) {
+	if len(statuses) == 0 {
+		statuses = []string{entity.ScanStatusInfected}
+	}
+	for _, status := range statuses {
+		switch status {
+		case entity.ScanStatusPending, entity.ScanStatusInfected, entity.ScanStatusError:
+		default:
+			return nil, errors.ErrInvalidScanStatus
+		}
+	}
+	if limit <= 0 || limit > maxQuarantineLimit {
+		limit = maxQuarantineLimit
+	}
+	return s.scanRepo.Quarantine(ctx, statuses, limit)
+}
+
+// checkScan не дает отдать содержимое, которое не проверено (в том числе из-за ошибки сканера)
+// или заражено, никому, кроме владельца документа userID. Грант owner и права, унаследованные
+// от папки, владельцем не делают.
+func checkScan(doc *entity.Document, userID string) error {
+	if doc.UserID == userID {
+		return nil
+	}
+	switch doc.ScanStatus {
+	case entity.ScanStatusPending:
+		return errors.ErrScanPending
+	case entity.ScanStatusError:
+		return errors.ErrScanFailed
+	case entity.ScanStatusInfected:
+		return errors.ErrContentInfected
+	}
+	return nil
+}
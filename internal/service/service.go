@@ -1,43 +1,143 @@
-package service
-
-import (
-	"context"
-	"encoding/json"
-
-	"github.com/paudarco/doc-storage/internal/cache"
-	"github.com/paudarco/doc-storage/internal/config"
-	"github.com/paudarco/doc-storage/internal/entity"
-	"github.com/paudarco/doc-storage/internal/repository"
-	"github.com/sirupsen/logrus"
-)
-
-type Auth interface {
-}
-
-type User interface {
-	Register(ctx context.Context, login, password string) error
-	Authenticate(ctx context.Context, login, password string) (string, error)
-	GetByID(ctx context.Context, id string) (*entity.User, error)
-	InvalidateToken(ctx context.Context, token string) error
-}
-
-type Doc interface {
-	Create(ctx context.Context, userID string, meta map[string]interface{}, jsonData json.RawMessage, fileData []byte) (*entity.Document, error)
-	List(ctx context.Context, userID, loginFilter, keyFilter, valueFilter string, limit int) ([]*entity.Document, error)
-	GetByID(ctx context.Context, userID, docID string) (*entity.Document, error)
-	checkAccess(ctx context.Context, doc *entity.Document, userID string) error
-	Delete(ctx context.Context, userID, docID string) error
-}
-
-type Service struct {
-	Auth
-	User
-	Doc
-}
-
-func NewService(repo *repository.Repository, cache *cache.Cache, cfg *config.Config, log *logrus.Logger) *Service {
-	return &Service{
-		User: NewUserService(repo.User, cache.Token, cfg),
-		Doc:  NewDocService(repo.Doc, repo.User, cache.Doc, log),
-	}
-}
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/paudarco/doc-storage/pkg/extract"
+	"github.com/sirupsen/logrus"
+)
+
+type Auth interface {
+}
+
+type User interface {
+	Register(ctx context.Context, login, password string) error
+	Authenticate(ctx context.Context, login, password string) (string, error)
+	GetByID(ctx context.Context, id string) (*entity.User, error)
+	InvalidateToken(ctx context.Context, token string) error
+}
+
+type Doc interface {
+	Create(ctx context.Context, userID string, meta map[string]interface{}, jsonData json.RawMessage, fileData []byte) (*entity.Document, error)
+	CreateWithID(ctx context.Context, docID, userID string, meta map[string]interface{}, jsonData json.RawMessage, fileData []byte) (*entity.Document, error)
+	Upload(ctx context.Context, userID string, meta map[string]interface{}, jsonData json.RawMessage, r io.Reader) (*entity.Document, error)
+	UploadWithID(ctx context.Context, docID, userID string, meta map[string]interface{}, jsonData json.RawMessage, r io.Reader) (*entity.Document, error)
+	CreateFromArchive(ctx context.Context, userID string, meta map[string]interface{}, fileData []byte) (*entity.ExtractResult, error)
+	List(ctx context.Context, userID string, q *entity.DocListQuery) (*entity.DocPage, error)
+	GetText(ctx context.Context, userID, docID string) (*entity.DocText, error)
+	Search(ctx context.Context, userID string, q entity.SearchQuery) ([]*entity.SearchResult, error)
+	TagFacets(ctx context.Context, userID, loginFilter string, filter entity.TagFilter) ([]entity.TagCount, error)
+	GetByID(ctx context.Context, userID, docID string) (*entity.Document, error)
+	checkAccess(ctx context.Context, doc *entity.Document, userID string, required entity.Permission) error
+	Update(ctx context.Context, userID, docID string, upd *entity.DocUpdate) (*entity.Document, error)
+	Share(ctx context.Context, userID, docID string, grants []entity.Grant) (*entity.Document, error)
+	SetTags(ctx context.Context, userID, docID string, tags []string) (*entity.Document, error)
+	Transfer(ctx context.Context, userID, docID, toLogin string) (*entity.Document, error)
+	TransferAll(ctx context.Context, fromLogin, toLogin string) ([]string, error)
+	Delete(ctx context.Context, userID, docID string) error
+	Batch(ctx context.Context, userID string, req *entity.BatchRequest) ([]*entity.BatchResult, bool, error)
+	CheckHashes(ctx context.Context, userID string, hashes []string) ([]string, error)
+	UploadLimit(ctx context.Context, userID string) (int64, error)
+	Archive(ctx context.Context, userID string, req *entity.ArchiveRequest, q *entity.DocListQuery, visit ArchiveVisitor) (*entity.ArchiveResult, error)
+}
+
+type Scan interface {
+	Quarantine(ctx context.Context, statuses []string, limit int) ([]*entity.QuarantinedDoc, error)
+}
+
+type Retention interface {
+	ListRules(ctx context.Context) ([]*entity.RetentionRule, error)
+	CreateRule(ctx context.Context, req *entity.CreateRetentionRuleRequest) (*entity.RetentionRule, error)
+	DeleteRule(ctx context.Context, id string) error
+	SetLegalHold(ctx context.Context, docID string, hold bool) (*entity.RetentionStatus, error)
+}
+
+type Account interface {
+	Usage(ctx context.Context, userID string) (*entity.Usage, error)
+	UserUsage(ctx context.Context, login string) (*entity.Usage, error)
+	SetQuota(ctx context.Context, login string, override *entity.QuotaOverride) (*entity.Usage, error)
+}
+
+// Worker фоновая обработка документов, запускается из main
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+type Thumbnail interface {
+	Get(ctx context.Context, userID, docID string, size int) (*entity.Thumbnail, error)
+}
+
+type Link interface {
+	Create(ctx context.Context, userID, docID string, req *entity.CreateLinkRequest) (*entity.ShareLink, error)
+	List(ctx context.Context, userID, docID string) ([]*entity.ShareLink, error)
+	Revoke(ctx context.Context, userID, docID, linkID string) error
+	Open(ctx context.Context, token, password string) (*entity.Document, error)
+}
+
+type Presign interface {
+	PresignDownload(ctx context.Context, userID, docID string, ttl time.Duration) (*entity.PresignedURL, error)
+	PresignUpload(ctx context.Context, userID string, ttl time.Duration) (*entity.PresignedURL, error)
+	Verify(method, path string, query url.Values) (string, error)
+}
+
+type Folder interface {
+	Create(ctx context.Context, userID string, req *entity.CreateFolderRequest) (*entity.Folder, error)
+	Get(ctx context.Context, userID, folderID string) (*entity.FolderContents, error)
+	Root(ctx context.Context, userID string) (*entity.FolderContents, error)
+	Update(ctx context.Context, userID, folderID string, upd *entity.FolderUpdate) (*entity.Folder, error)
+	Delete(ctx context.Context, userID, folderID string) error
+	Share(ctx context.Context, userID, folderID string, grants []entity.Grant) (*entity.Folder, error)
+	Resolve(ctx context.Context, userID, ownerLogin, path string) (*entity.Folder, *entity.Document, error)
+}
+
+type Service struct {
+	Auth
+	User
+	Doc
+	Link
+	Presign
+	Folder
+	Thumbnail
+	Account
+	Scan
+	Retention
+
+	TextWorker      Worker
+	ThumbnailWorker Worker
+	ScanWorker      Worker
+	BlobCollector   Worker
+	DocReaper       Worker
+}
+
+func NewService(repo *repository.Repository, cache *cache.Cache, cfg *config.Config, log *logrus.Logger) *Service {
+	textExtractor := NewTextExtractor(repo.Doc, repo.Blob, cache.Doc, extract.Default(), cfg, log)
+	thumbGenerator := NewThumbnailGenerator(repo.Thumbnail, repo.Blob, cache.Doc, cfg, log)
+	contentScanner := NewContentScanner(repo.Scan, repo.Blob, cache.Doc, cfg, log)
+	docService := NewDocService(repo.Doc, repo.User, repo.Folder, repo.Blob, repo.Usage, cache.Doc, textExtractor, thumbGenerator, contentScanner, cfg, log)
+
+	return &Service{
+		User:      NewUserService(repo.User, cache.Token, cfg),
+		Doc:       docService,
+		Link:      NewLinkService(repo.ShareLink, repo.Doc, docService, cache.Link, cfg, log),
+		Presign:   NewPresignService(docService, cfg),
+		Folder:    NewFolderService(repo.Folder, repo.Doc, repo.User, docService, cache.Doc, log),
+		Thumbnail: NewThumbnailService(repo.Thumbnail, docService, cfg),
+		Account:   NewAccountService(repo.Usage, repo.User),
+		Scan:      contentScanner,
+		Retention: NewRetentionService(repo.Retention, repo.User, repo.Folder, cache.Doc, log),
+
+		TextWorker:      textExtractor,
+		ThumbnailWorker: thumbGenerator,
+		ScanWorker:      contentScanner,
+		BlobCollector:   NewBlobCollector(repo.Blob, cfg, log),
+		DocReaper:       NewDocReaper(repo.Doc, cache.Doc, cfg, log),
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/paudarco/doc-storage/pkg/extract"
+	"github.com/sirupsen/logrus"
+)
+
+type textJob struct {
+	docID  string
+	userID string
+	mime   string
+	hash   string
+	data   []byte // nil - содержимое загружено потоком и читается из хранилища блобов
+}
+
+// TextExtractor извлекает текст загруженных файлов в фоне и сохраняет его вместе с документом
+type TextExtractor struct {
+	docRepo    repository.Doc
+	blobRepo   repository.Blob
+	cache      cache.Doc
+	extractors *extract.Registry
+	jobs       chan textJob
+	cfg        *config.Config
+	log        *logrus.Logger
+}
+
+func NewTextExtractor(docRepo repository.Doc, blobRepo repository.Blob, cache cache.Doc, extractors *extract.Registry, cfg *config.Config, log *logrus.Logger) *TextExtractor {
+	return &TextExtractor{
+		docRepo:    docRepo,
+		blobRepo:   blobRepo,
+		cache:      cache,
+		extractors: extractors,
+		jobs:       make(chan textJob, cfg.TextQueueSize),
+		cfg:        cfg,
+		log:        log,
+	}
+}
+
+// Status возвращает начальный статус извлечения для нового документа
+func (s *TextExtractor) Status(doc *entity.Document) string {
+	if !doc.IsFile {
+		return entity.TextStatusNone
+	}
+	if _, ok := s.extractors.Lookup(doc.Mime); !ok || doc.Size == 0 {
+		return entity.TextStatusUnsupported
+	}
+	return entity.TextStatusPending
+}
+
+// Enqueue ставит документ в очередь на извлечение. При переполненной очереди документ
+// сразу помечается как failed, чтобы не блокировать загрузку.
+func (s *TextExtractor) Enqueue(ctx context.Context, doc *entity.Document) {
+	if doc.TextStatus != entity.TextStatusPending {
+		return
+	}
+
+	job := textJob{docID: doc.ID, userID: doc.UserID, mime: doc.Mime, hash: doc.ContentHash, data: doc.FileData}
+	select {
+	case s.jobs <- job:
+	default:
+		s.log.Warnf("text extraction queue is full, skipping document %s", doc.ID)
+		s.save(ctx, job, entity.TextStatusFailed, "", "extraction queue is full")
+	}
+}
+
+// Run запускает воркеры и блокируется до отмены ctx
+func (s *TextExtractor) Run(ctx context.Context) {
+	workers := s.cfg.TextWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.jobs:
+					s.process(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *TextExtractor) process(ctx context.Context, job textJob) {
+	if job.data == nil {
+		data, err := s.blobRepo.Get(ctx, job.hash)
+		if err != nil {
+			s.log.Errorf("failed to load content of document %s for text extraction: %v", job.docID, err)
+			s.save(ctx, job, entity.TextStatusFailed, "", err.Error())
+			return
+		}
+		job.data = data
+	}
+
+	text, err := s.extractors.Extract(job.mime, job.data)
+	if err != nil {
+		s.log.Infof("text extraction failed for document %s: %v", job.docID, err)
+		s.save(ctx, job, entity.TextStatusFailed, "", err.Error())
+		return
+	}
+
+	s.save(ctx, job, entity.TextStatusDone, text, "")
+}
+
+func (s *TextExtractor) save(ctx context.Context, job textJob, status, text, errMsg string) {
+	if err := s.docRepo.SetText(ctx, job.docID, status, text, errMsg); err != nil {
+		s.log.Errorf("failed to save extracted text for document %s: %v", job.docID, err)
+		return
+	}
+
+	_ = s.cache.DeleteDoc(ctx, job.docID)
+	_ = s.cache.InvalidateUserDocLists(ctx, job.userID)
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"sync"
+	"time"
+
+	// Декодеры форматов, для которых строятся превью
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/paudarco/doc-storage/internal/cache"
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/internal/repository"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/image/draw"
+)
+
+const thumbnailJPEGQuality = 85
+
+var thumbnailMimes = map[string]struct{}{
+	"image/jpeg": {},
+	"image/png":  {},
+	"image/gif":  {},
+	"image/webp": {},
+}
+
+type thumbnailJob struct {
+	docID  string
+	userID string
+	hash   string
+	data   []byte // nil - содержимое загружено потоком и читается из хранилища блобов
+}
+
+// ThumbnailGenerator строит превью загруженных изображений в фоне
+type ThumbnailGenerator struct {
+	thumbRepo repository.Thumbnail
+	blobRepo  repository.Blob
+	cache     cache.Doc
+	jobs      chan thumbnailJob
+	cfg       *config.Config
+	log       *logrus.Logger
+}
+
+func NewThumbnailGenerator(thumbRepo repository.Thumbnail, blobRepo repository.Blob, cache cache.Doc, cfg *config.Config, log *logrus.Logger) *ThumbnailGenerator {
+	return &ThumbnailGenerator{
+		thumbRepo: thumbRepo,
+		blobRepo:  blobRepo,
+		cache:     cache,
+		jobs:      make(chan thumbnailJob, cfg.ThumbnailQueueSize),
+		cfg:       cfg,
+		log:       log,
+	}
+}
+
+// Status возвращает начальный статус превью для нового документа
+func (g *ThumbnailGenerator) Status(doc *entity.Document) string {
+	if !doc.IsFile || doc.Size == 0 || len(g.cfg.ThumbnailSizes) == 0 {
+		return entity.ThumbnailStatusNone
+	}
+	mediaType, _, err := mime.ParseMediaType(doc.Mime)
+	if err != nil {
+		return entity.ThumbnailStatusNone
+	}
+	if _, ok := thumbnailMimes[mediaType]; !ok {
+		return entity.ThumbnailStatusNone
+	}
+	return entity.ThumbnailStatusPending
+}
+
+// Enqueue ставит изображение в очередь, при переполненной очереди превью помечается как failed
+func (g *ThumbnailGenerator) Enqueue(ctx context.Context, doc *entity.Document) {
+	if doc.ThumbStatus != entity.ThumbnailStatusPending {
+		return
+	}
+
+	job := thumbnailJob{docID: doc.ID, userID: doc.UserID, hash: doc.ContentHash, data: doc.FileData}
+	select {
+	case g.jobs <- job:
+	default:
+		g.log.Warnf("thumbnail queue is full, skipping document %s", doc.ID)
+		g.fail(ctx, job)
+	}
+}
+
+// Run запускает воркеры и блокируется до отмены ctx
+func (g *ThumbnailGenerator) Run(ctx context.Context) {
+	workers := g.cfg.ThumbnailWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-g.jobs:
+					g.process(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (g *ThumbnailGenerator) process(ctx context.Context, job thumbnailJob) {
+	if job.data == nil {
+		data, err := g.blobRepo.Get(ctx, job.hash)
+		if err != nil {
+			g.log.Errorf("failed to load content of document %s for thumbnails: %v", job.docID, err)
+			g.fail(ctx, job)
+			return
+		}
+		job.data = data
+	}
+
+	thumbs, err := renderThumbnails(job.data, g.cfg.ThumbnailSizes, g.cfg.ThumbnailMaxPixels)
+	if err != nil {
+		g.log.Infof("thumbnail generation failed for document %s: %v", job.docID, err)
+		g.fail(ctx, job)
+		return
+	}
+
+	if err := g.thumbRepo.Save(ctx, job.docID, thumbs); err != nil {
+		g.log.Errorf("failed to save thumbnails for document %s: %v", job.docID, err)
+		return
+	}
+
+	g.invalidate(ctx, job)
+}
+
+func (g *ThumbnailGenerator) fail(ctx context.Context, job thumbnailJob) {
+	if err := g.thumbRepo.SetStatus(ctx, job.docID, entity.ThumbnailStatusFailed); err != nil {
+		g.log.Errorf("failed to update thumbnail status for document %s: %v", job.docID, err)
+		return
+	}
+	g.invalidate(ctx, job)
+}
+
+func (g *ThumbnailGenerator) invalidate(ctx context.Context, job thumbnailJob) {
+	_ = g.cache.DeleteDoc(ctx, job.docID)
+	_ = g.cache.InvalidateUserDocLists(ctx, job.userID)
+}
+
+// renderThumbnails вписывает изображение в квадраты заданных размеров без увеличения.
+// Непрозрачные изображения кодируются в JPEG, с прозрачностью - в PNG.
+func renderThumbnails(data []byte, sizes []int, maxPixels int) ([]*entity.Thumbnail, error) {
+	imgCfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if imgCfg.Width <= 0 || imgCfg.Height <= 0 {
+		return nil, fmt.Errorf("invalid image dimensions %dx%d", imgCfg.Width, imgCfg.Height)
+	}
+	if maxPixels > 0 && imgCfg.Width*imgCfg.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", imgCfg.Width, imgCfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	opaque := isOpaque(src)
+	now := time.Now()
+	thumbs := make([]*entity.Thumbnail, 0, len(sizes))
+	for _, size := range sizes {
+		if size <= 0 {
+			continue
+		}
+
+		width, height := fitSize(src.Bounds().Dx(), src.Bounds().Dy(), size)
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+		var buf bytes.Buffer
+		thumb := &entity.Thumbnail{Size: size, Width: width, Height: height, CreatedAt: now}
+		if opaque {
+			thumb.Mime = "image/jpeg"
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality})
+		} else {
+			thumb.Mime = "image/png"
+			err = png.Encode(&buf, dst)
+		}
+		if err != nil {
+			return nil, err
+		}
+		thumb.Data = buf.Bytes()
+		thumbs = append(thumbs, thumb)
+	}
+
+	return thumbs, nil
+}
+
+// fitSize вписывает width x height в квадрат size, сохраняя пропорции
+func fitSize(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+type ThumbnailService struct {
+	thumbRepo repository.Thumbnail
+	docs      *DocService
+	cfg       *config.Config
+}
+
+func NewThumbnailService(thumbRepo repository.Thumbnail, docs *DocService, cfg *config.Config) *ThumbnailService {
+	return &ThumbnailService{
+		thumbRepo: thumbRepo,
+		docs:      docs,
+		cfg:       cfg,
+	}
+}
+
+// Get возвращает превью документа с проверкой права на чтение. Нулевой size означает наименьший
+// настроенный размер. Если превью еще строится, возвращается ErrThumbnailPending.
+func (s *ThumbnailService) Get(ctx context.Context, userID, docID string, size int) (*entity.Thumbnail, error) {
+	if size == 0 && len(s.cfg.ThumbnailSizes) > 0 {
+		size = s.cfg.ThumbnailSizes[0]
+		for _, candidate := range s.cfg.ThumbnailSizes {
+			size = min(size, candidate)
+		}
+	}
+	if !s.sizeAllowed(size) {
+		return nil, errors.ErrInvalidThumbnailSize
+	}
+
+	doc, err := s.docs.GetByID(ctx, userID, docID)
+	if err != nil {
+		return nil, err
+	}
+	doc.CloseContent()
+
+	switch doc.ThumbStatus {
+	case entity.ThumbnailStatusPending:
+		return nil, errors.ErrThumbnailPending
+	case entity.ThumbnailStatusDone:
+		return s.thumbRepo.Get(ctx, docID, size)
+	default:
+		return nil, errors.ErrThumbnailNotFound
+	}
+}
+
+func (s *ThumbnailService) sizeAllowed(size int) bool {
+	for _, allowed := range s.cfg.ThumbnailSizes {
+		if size == allowed {
+			return true
+		}
+	}
+	return false
+}
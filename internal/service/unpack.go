@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/pkg/sniff"
+	"github.com/paudarco/doc-storage/pkg/unpack"
+)
+
+// CreateFromArchive распаковывает ZIP или tar архив и создает документ для каждого файла.
+// Структура каталогов архива воссоздается папками внутри meta.folder_id (или корня),
+// остальные ключи meta применяются ко всем документам. Архив целиком проверяется
+// на лимиты до создания первого документа.
+func (s *DocService) CreateFromArchive(ctx context.Context, userID string, meta map[string]interface{}, fileData []byte) (*entity.ExtractResult, error) {
+	ownerID := userID
+	var baseFolderID *string
+	if folderID, ok := meta["folder_id"].(string); ok && folderID != "" {
+		folder, err := s.authorizeFolder(ctx, userID, folderID, entity.PermissionWrite)
+		if err != nil {
+			return nil, err
+		}
+		// Дерево папок принадлежит одному владельцу
+		ownerID = folder.UserID
+		baseFolderID = &folder.ID
+	}
+
+	// meta.sha256 относится к самому архиву
+	fileData, err := s.verifyContent(ctx, userID, meta, fileData)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := unpack.Limits{
+		MaxEntries:   s.cfg.ExtractMaxEntries,
+		MaxTotalSize: s.cfg.ExtractMaxSize,
+		MaxRatio:     s.cfg.ExtractMaxRatio,
+	}
+
+	result := &entity.ExtractResult{Docs: []entity.ExtractedDoc{}}
+	folders := map[string]*string{"": baseFolderID}
+
+	err = unpack.Walk(fileData, limits, func(e *unpack.Entry) error {
+		dir, name := path.Split(e.Path)
+		dir = strings.TrimSuffix(dir, "/")
+
+		folderID, err := s.ensureFolderPath(ctx, ownerID, dir, folders, result)
+		if err != nil {
+			result.Failed = append(result.Failed, entity.ExtractedFailed{Path: e.Path, Error: err.Error()})
+			return nil
+		}
+
+		entryMeta := make(map[string]interface{}, len(meta)+3)
+		for key, value := range meta {
+			entryMeta[key] = value
+		}
+		delete(entryMeta, "extract")
+		delete(entryMeta, "sha256")
+		delete(entryMeta, "folder_id")
+		entryMeta["name"] = name
+		entryMeta["file"] = true
+		entryMeta["mime"] = detectMime(name, e.Data)
+		if folderID != nil {
+			entryMeta["folder_id"] = *folderID
+		}
+
+		doc, err := s.CreateWithID(ctx, uuid.New().String(), userID, entryMeta, nil, e.Data)
+		if err != nil {
+			result.Failed = append(result.Failed, entity.ExtractedFailed{Path: e.Path, Error: err.Error()})
+			return nil
+		}
+		result.Docs = append(result.Docs, entity.ExtractedDoc{ID: doc.ID, Path: e.Path})
+		return nil
+	})
+	if err != nil {
+		err = unpackError(err)
+		if len(result.Docs) == 0 && len(result.Failed) == 0 {
+			return nil, err
+		}
+		// Архив поврежден после проверки заголовков, часть документов уже создана
+		result.Failed = append(result.Failed, entity.ExtractedFailed{Error: err.Error()})
+	}
+
+	return result, nil
+}
+
+// ensureFolderPath возвращает ID папки для каталога архива dir, создавая недостающие папки.
+// folders кэширует уже найденные каталоги, ключ "" - базовая папка.
+func (s *DocService) ensureFolderPath(ctx context.Context, ownerID, dir string, folders map[string]*string, result *entity.ExtractResult) (*string, error) {
+	if id, ok := folders[dir]; ok {
+		return id, nil
+	}
+
+	parentDir, name := path.Split(dir)
+	parentID, err := s.ensureFolderPath(ctx, ownerID, strings.TrimSuffix(parentDir, "/"), folders, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFolderName(name); err != nil {
+		return nil, err
+	}
+
+	folder, err := s.folderRepo.GetByName(ctx, ownerID, parentID, name)
+	if err == errors.ErrFolderNotFound {
+		folder = &entity.Folder{
+			ID:        uuid.New().String(),
+			UserID:    ownerID,
+			ParentID:  parentID,
+			Name:      name,
+			CreatedAt: time.Now(),
+		}
+		err = s.folderRepo.Create(ctx, folder)
+		if err == nil {
+			result.Folders++
+		} else if err == errors.ErrFolderAlreadyExist {
+			// Папку успели создать параллельно
+			folder, err = s.folderRepo.GetByName(ctx, ownerID, parentID, name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	folders[dir] = &folder.ID
+	return &folder.ID, nil
+}
+
+// detectMime определяет тип файла по расширению, а если оно неизвестно - по содержимому
+func detectMime(name string, data []byte) string {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = sniff.Detect(data)
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return contentType
+}
+
+func unpackError(err error) error {
+	switch err {
+	case unpack.ErrUnsupported:
+		return errors.ErrNotArchive
+	case unpack.ErrUnsafePath:
+		return errors.ErrArchiveUnsafePath
+	case unpack.ErrTooManyEntries:
+		return errors.ErrArchiveTooManyEntries
+	case unpack.ErrTooLarge:
+		return errors.ErrArchiveTooLarge
+	case unpack.ErrCompressionRatio:
+		return errors.ErrArchiveCompressionRate
+	}
+	return err
+}
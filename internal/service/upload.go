@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/paudarco/doc-storage/internal/entity"
+	"github.com/paudarco/doc-storage/internal/errors"
+	"github.com/paudarco/doc-storage/pkg/sniff"
+)
+
+// UploadLimit возвращает максимальный размер одного файла для userID, 0 - без ограничения.
+// Обработчики читают загрузку не дальше этого размера.
+func (s *DocService) UploadLimit(ctx context.Context, userID string) (int64, error) {
+	usage, err := s.usageRepo.Get(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return usage.MaxUploadSize, nil
+}
+
+// Upload создает документ, записывая содержимое файла из r в хранилище потоком
+func (s *DocService) Upload(ctx context.Context, userID string, meta map[string]interface{}, jsonData json.RawMessage, r io.Reader) (*entity.Document, error) {
+	return s.UploadWithID(ctx, uuid.New().String(), userID, meta, jsonData, r)
+}
+
+// UploadWithID создает документ с заранее выданным ID из потока r. Содержимое не собирается
+// в памяти: тип проверяется по первым sniff.HeadSize байтам, а хэш и размер считаются по ходу
+// записи в хранилище, превышение лимита или несовпадение хэша отменяют запись. Пустой поток
+// с meta.sha256 ссылается на уже загруженное пользователем содержимое, как и в CreateWithID.
+func (s *DocService) UploadWithID(ctx context.Context, docID, userID string, meta map[string]interface{}, jsonData json.RawMessage, r io.Reader) (*entity.Document, error) {
+	doc, err := s.newDoc(ctx, docID, userID, meta)
+	if err != nil {
+		return nil, err
+	}
+	if !doc.IsFile {
+		return s.create(ctx, doc, jsonData)
+	}
+
+	src := bufio.NewReaderSize(r, sniff.HeadSize)
+	head, err := src.Peek(sniff.HeadSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(head) == 0 {
+		return s.CreateWithID(ctx, docID, userID, meta, jsonData, []byte{})
+	}
+
+	expected, err := expectedHash(meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkType(doc, head); err != nil {
+		return nil, err
+	}
+	limit, err := s.UploadLimit(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = src
+	if limit > 0 {
+		body = io.LimitReader(src, limit+1)
+	}
+	hash, size, err := s.blobRepo.Put(ctx, body, func(hash string, size int64) error {
+		if limit > 0 && size > limit {
+			return errors.ErrUploadTooLarge
+		}
+		if expected != "" && hash != expected {
+			return errors.ErrDigestMismatch
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc.ContentHash = hash
+	doc.Size = size
+	return s.create(ctx, doc, jsonData)
+}
+
+// checkUpload применяет к содержимому файла ограничение размера и политику типов
+func (s *DocService) checkUpload(ctx context.Context, doc *entity.Document, fileData []byte) error {
+	limit, err := s.UploadLimit(ctx, doc.UserID)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && int64(len(fileData)) > limit {
+		return errors.ErrUploadTooLarge
+	}
+	return s.checkType(doc, fileData)
+}
+
+// checkType применяет политику типов к началу содержимого head. Тип определяется
+// по сигнатуре и должен быть совместим с заявленным meta.mime; без meta.mime документу
+// присваивается распознанный тип. Запрещенным считается файл, если под deny подходит
+// заявленный или распознанный тип.
+func (s *DocService) checkType(doc *entity.Document, head []byte) error {
+	detected := sniff.Detect(head)
+	if !sniff.Compatible(doc.Mime, detected) {
+		s.log.Warnf("upload %q by %s declared as %q, detected %q", doc.Name, doc.UserID, doc.Mime, detected)
+		return errors.ErrMimeMismatch
+	}
+	if doc.Mime == "" {
+		doc.Mime = detected
+	}
+
+	if sniff.Match(s.cfg.UploadMimeDeny, doc.Mime) || sniff.Match(s.cfg.UploadMimeDeny, detected) {
+		return errors.ErrMimeDenied
+	}
+	if len(s.cfg.UploadMimeAllow) > 0 && !sniff.Match(s.cfg.UploadMimeAllow, doc.Mime) {
+		return errors.ErrMimeNotAllowed
+	}
+	return nil
+}
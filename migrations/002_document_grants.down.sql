@@ -0,0 +1,18 @@
+BEGIN;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS grant_list TEXT[];
+
+UPDATE documents d
+SET grant_list = g.logins
+FROM (
+    SELECT document_id, array_agg(login ORDER BY login) AS logins
+    FROM document_grants
+    GROUP BY document_id
+) g
+WHERE d.id = g.document_id;
+
+DROP INDEX IF EXISTS idx_document_grants_login;
+
+DROP TABLE IF EXISTS document_grants;
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS document_grants (
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    login VARCHAR(255) NOT NULL,
+    permission VARCHAR(16) NOT NULL DEFAULT 'read'
+        CHECK (permission IN ('read', 'write', 'share', 'owner')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (document_id, login)
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_grants_login ON document_grants(login);
+
+-- Переносим старые гранты: раньше любой грант означал только чтение
+INSERT INTO document_grants (document_id, login, permission)
+SELECT DISTINCT d.id, g.login, 'read'
+FROM documents d, unnest(d.grant_list) AS g(login)
+WHERE g.login IS NOT NULL AND g.login <> ''
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS grant_list;
+
+COMMIT;
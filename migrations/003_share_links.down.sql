@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_share_links_document_id;
+
+DROP TABLE IF EXISTS share_links;
+
+COMMIT;
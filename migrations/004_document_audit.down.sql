@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_document_audit_document_id;
+
+DROP TABLE IF EXISTS document_audit;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Журнал не ссылается на documents, чтобы записи переживали удаление документа
+CREATE TABLE IF NOT EXISTS document_audit (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    document_id UUID NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(32) NOT NULL,
+    from_user_id UUID,
+    to_user_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_audit_document_id ON document_audit(document_id);
+
+COMMIT;
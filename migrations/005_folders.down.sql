@@ -0,0 +1,16 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_folder_id;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS folder_id;
+
+DROP INDEX IF EXISTS idx_folder_grants_login;
+
+DROP TABLE IF EXISTS folder_grants;
+
+DROP INDEX IF EXISTS idx_folders_parent_id;
+DROP INDEX IF EXISTS idx_folders_unique_name;
+
+DROP TABLE IF EXISTS folders;
+
+COMMIT;
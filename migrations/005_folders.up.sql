@@ -0,0 +1,31 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS folders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Имена папок уникальны в пределах родителя, корневые папки уникальны в пределах пользователя
+CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_unique_name
+    ON folders(user_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid), name);
+CREATE INDEX IF NOT EXISTS idx_folders_parent_id ON folders(parent_id);
+
+CREATE TABLE IF NOT EXISTS folder_grants (
+    folder_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    login VARCHAR(255) NOT NULL,
+    permission VARCHAR(16) NOT NULL DEFAULT 'read'
+        CHECK (permission IN ('read', 'write', 'share', 'owner')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (folder_id, login)
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_grants_login ON folder_grants(login);
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS folder_id UUID REFERENCES folders(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_documents_folder_id ON documents(folder_id);
+
+COMMIT;
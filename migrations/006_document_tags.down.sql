@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_document_tags_tag;
+
+DROP TABLE IF EXISTS document_tags;
+
+COMMIT;
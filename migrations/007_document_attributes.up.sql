@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_search;
+ALTER TABLE documents DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE documents DROP COLUMN IF EXISTS search_lang;
+ALTER TABLE documents DROP COLUMN IF EXISTS content_text;
+ALTER TABLE documents DROP COLUMN IF EXISTS json_data;
+
+COMMIT;
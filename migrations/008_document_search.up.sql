@@ -0,0 +1,18 @@
+BEGIN;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS json_data JSONB;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_text TEXT;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_lang REGCONFIG NOT NULL DEFAULT 'simple';
+
+-- Веса: A - имя, B - строковые атрибуты, C - строки JSON-документа, D - текст файла.
+-- Текст файла обрезается, т.к. tsvector ограничен 1 МБ.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector(search_lang, coalesce(name, '')), 'A') ||
+    setweight(jsonb_to_tsvector(search_lang, attributes, '["string"]'), 'B') ||
+    setweight(jsonb_to_tsvector(search_lang, coalesce(json_data, '{}'::jsonb), '["string"]'), 'C') ||
+    setweight(to_tsvector(search_lang, left(coalesce(content_text, ''), 262144)), 'D')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_documents_search ON documents USING GIN (search_vector);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS text_extracted_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS text_error;
+ALTER TABLE documents DROP COLUMN IF EXISTS text_status;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS text_status VARCHAR(16) NOT NULL DEFAULT 'none'
+    CHECK (text_status IN ('none', 'pending', 'done', 'failed', 'unsupported'));
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS text_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS text_extracted_at TIMESTAMP;
+
+UPDATE documents SET text_status = 'done', text_extracted_at = created_at WHERE content_text IS NOT NULL;
+
+COMMIT;
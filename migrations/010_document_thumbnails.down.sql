@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS document_thumbnails;
+ALTER TABLE documents DROP COLUMN IF EXISTS thumbnail_status;
+
+COMMIT;
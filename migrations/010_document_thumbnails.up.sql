@@ -0,0 +1,17 @@
+BEGIN;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS thumbnail_status VARCHAR(16) NOT NULL DEFAULT 'none'
+    CHECK (thumbnail_status IN ('none', 'pending', 'done', 'failed'));
+
+CREATE TABLE IF NOT EXISTS document_thumbnails (
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    size INTEGER NOT NULL,
+    mime VARCHAR(64) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (document_id, size)
+);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_user_updated;
+DROP INDEX IF EXISTS idx_documents_user_size;
+DROP INDEX IF EXISTS idx_documents_user_created;
+DROP INDEX IF EXISTS idx_documents_user_name;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS size;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+UPDATE documents SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE documents SET size = octet_length(json_data::text) WHERE json_data IS NOT NULL;
+
+ALTER TABLE documents ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE documents ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+
+-- Индексы под keyset-пагинацию: сортировка всегда дополняется id
+CREATE INDEX IF NOT EXISTS idx_documents_user_name ON documents(user_id, name, id);
+CREATE INDEX IF NOT EXISTS idx_documents_user_created ON documents(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_documents_user_size ON documents(user_id, size, id);
+CREATE INDEX IF NOT EXISTS idx_documents_user_updated ON documents(user_id, updated_at, id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_user_public;
+DROP INDEX IF EXISTS idx_documents_user_mime;
+
+COMMIT;
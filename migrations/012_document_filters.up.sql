@@ -0,0 +1,7 @@
+BEGIN;
+
+-- mime фильтруется по точному значению или диапазону префикса ("image/*" -> ~>=~ 'image/' AND ~<~ 'image0')
+CREATE INDEX IF NOT EXISTS idx_documents_user_mime ON documents(user_id, mime varchar_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_documents_user_public ON documents(user_id) WHERE public;
+
+COMMIT;
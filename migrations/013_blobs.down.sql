@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS documents_blob_refs ON documents;
+DROP FUNCTION IF EXISTS documents_blob_refs();
+DROP INDEX IF EXISTS idx_documents_content_hash;
+ALTER TABLE documents DROP COLUMN IF EXISTS content_hash;
+DROP TABLE IF EXISTS blobs;
+
+COMMIT;
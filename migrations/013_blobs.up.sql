@@ -0,0 +1,40 @@
+BEGIN;
+
+-- Содержимое файлов хранится один раз на SHA-256, документы ссылаются на него через content_hash
+CREATE TABLE IF NOT EXISTS blobs (
+    hash CHAR(64) PRIMARY KEY,
+    size BIGINT NOT NULL,
+    data BYTEA NOT NULL,
+    ref_count INTEGER NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    unreferenced_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_blobs_unreferenced ON blobs(unreferenced_at) WHERE ref_count = 0;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash CHAR(64) REFERENCES blobs(hash);
+
+CREATE INDEX IF NOT EXISTS idx_documents_content_hash ON documents(user_id, content_hash) WHERE content_hash IS NOT NULL;
+
+-- Счетчик ссылок ведется триггером, чтобы учитывались и каскадные удаления (папки, пользователи)
+CREATE OR REPLACE FUNCTION documents_blob_refs() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.content_hash IS NOT NULL THEN
+        UPDATE blobs
+        SET ref_count = ref_count - 1,
+            unreferenced_at = CASE WHEN ref_count = 1 THEN now() ELSE unreferenced_at END
+        WHERE hash = OLD.content_hash;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.content_hash IS NOT NULL THEN
+        UPDATE blobs SET ref_count = ref_count + 1, unreferenced_at = NULL WHERE hash = NEW.content_hash;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS documents_blob_refs ON documents;
+CREATE TRIGGER documents_blob_refs
+    AFTER INSERT OR DELETE OR UPDATE OF content_hash ON documents
+    FOR EACH ROW EXECUTE FUNCTION documents_blob_refs();
+
+COMMIT;
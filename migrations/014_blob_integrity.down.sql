@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE blobs DROP COLUMN IF EXISTS corrupt_at;
+ALTER TABLE blobs DROP COLUMN IF EXISTS verified_at;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- verified_at - последняя успешная проверка содержимого, corrupt_at - хэш не совпал, блоб в карантине
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS corrupt_at TIMESTAMP;
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_key_id;
+DROP INDEX IF EXISTS idx_blobs_key_id;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS json_enc;
+ALTER TABLE documents DROP COLUMN IF EXISTS wrapped_key;
+ALTER TABLE documents DROP COLUMN IF EXISTS key_id;
+
+ALTER TABLE blobs DROP COLUMN IF EXISTS wrapped_key;
+ALTER TABLE blobs DROP COLUMN IF EXISTS key_id;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Envelope-шифрование: key_id - мастер-ключ, которым обернут ключ данных wrapped_key.
+-- Строки без key_id хранятся открытым текстом (записаны до включения шифрования).
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS key_id VARCHAR(64);
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+
+-- Зашифрованное содержимое JSON-документа хранится в json_enc, json_data у него NULL
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS key_id VARCHAR(64);
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS json_enc BYTEA;
+
+CREATE INDEX IF NOT EXISTS idx_blobs_key_id ON blobs(key_id) WHERE key_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_documents_key_id ON documents(key_id) WHERE key_id IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS documents_user_usage ON documents;
+DROP FUNCTION IF EXISTS documents_user_usage();
+DROP TABLE IF EXISTS user_usage;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+-- Использование хранилища по пользователям: bytes и docs ведет триггер на documents,
+-- max_bytes и max_docs - заданные администратором квоты (NULL - квота по умолчанию из конфигурации)
+CREATE TABLE IF NOT EXISTS user_usage (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    bytes BIGINT NOT NULL DEFAULT 0 CHECK (bytes >= 0),
+    docs BIGINT NOT NULL DEFAULT 0 CHECK (docs >= 0),
+    max_bytes BIGINT CHECK (max_bytes >= 0),
+    max_docs BIGINT CHECK (max_docs >= 0)
+);
+
+INSERT INTO user_usage (user_id, bytes, docs)
+SELECT user_id, SUM(size), COUNT(*) FROM documents GROUP BY user_id
+ON CONFLICT (user_id) DO UPDATE SET bytes = EXCLUDED.bytes, docs = EXCLUDED.docs;
+
+-- При удалении пользователя его строка удаляется каскадно, поэтому для уменьшения используется UPDATE
+CREATE OR REPLACE FUNCTION documents_user_usage() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE user_usage SET bytes = bytes - OLD.size, docs = docs - 1 WHERE user_id = OLD.user_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO user_usage (user_id, bytes, docs) VALUES (NEW.user_id, NEW.size, 1)
+        ON CONFLICT (user_id) DO UPDATE SET bytes = user_usage.bytes + EXCLUDED.bytes, docs = user_usage.docs + 1;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS documents_user_usage ON documents;
+CREATE TRIGGER documents_user_usage
+    AFTER INSERT OR DELETE OR UPDATE OF user_id, size ON documents
+    FOR EACH ROW EXECUTE FUNCTION documents_user_usage();
+
+COMMIT;
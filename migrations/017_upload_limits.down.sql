@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE user_usage DROP COLUMN IF EXISTS max_upload_size;
+
+COMMIT;
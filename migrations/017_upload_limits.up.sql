@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Максимальный размер одного загружаемого файла, заданный администратором (NULL - UPLOAD_MAX_SIZE)
+ALTER TABLE user_usage ADD COLUMN IF NOT EXISTS max_upload_size BIGINT CHECK (max_upload_size >= 0);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_scan_status;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS scanned_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS scan_signature;
+ALTER TABLE documents DROP COLUMN IF EXISTS scan_status;
+
+COMMIT;
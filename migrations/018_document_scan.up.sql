@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Результат антивирусной проверки: scan_signature - найденная сигнатура или текст ошибки
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS scan_status VARCHAR(16) NOT NULL DEFAULT 'none'
+    CHECK (scan_status IN ('none', 'pending', 'clean', 'infected', 'error'));
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS scan_signature TEXT NOT NULL DEFAULT '';
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS scanned_at TIMESTAMP;
+
+-- Загруженные ранее файлы проверяются фоновым обходом
+UPDATE documents SET scan_status = 'pending' WHERE is_file AND content_hash IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_documents_scan_status ON documents(scan_status, created_at)
+    WHERE scan_status IN ('pending', 'infected', 'error');
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_expires_at;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS expires_at;
+
+COMMIT;
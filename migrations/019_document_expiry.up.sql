@@ -0,0 +1,9 @@
+BEGIN;
+
+-- Срок жизни документа: после expires_at документ скрыт, фоновая очистка удаляет его
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_documents_expires_at ON documents(expires_at)
+    WHERE expires_at IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS documents_legal_hold_update ON documents;
+DROP TRIGGER IF EXISTS documents_retention_delete ON documents;
+DROP FUNCTION IF EXISTS documents_retention();
+DROP FUNCTION IF EXISTS document_retained_until(UUID, UUID, UUID, TEXT);
+
+DROP TABLE IF EXISTS retention_rules;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS legal_hold;
+
+COMMIT;
@@ -0,0 +1,72 @@
+BEGIN;
+
+-- Документ под legal hold нельзя удалить, изменить или передать, пока флаг не снят администратором
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT false;
+
+-- Правила хранения: подходящие документы нельзя удалить до retain_until. Заданные условия
+-- объединяются через И. Ссылки на пользователя и папку без внешних ключей: пока правило действует,
+-- триггер не даст удалить подходящие документы, а правило для удаленной папки ни с чем не совпадает.
+CREATE TABLE IF NOT EXISTS retention_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID,
+    tag VARCHAR(64),
+    folder_id UUID,
+    mime VARCHAR(255),
+    retain_until TIMESTAMP NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (owner_id IS NOT NULL OR tag IS NOT NULL OR folder_id IS NOT NULL OR mime IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_rules_until ON retention_rules(retain_until);
+
+-- Самый поздний срок хранения документа по действующим правилам или NULL. Правило по папке
+-- действует и на вложенные папки, mime поддерживает шаблоны вида image/*.
+CREATE OR REPLACE FUNCTION document_retained_until(doc_id UUID, doc_user_id UUID, doc_folder_id UUID, doc_mime TEXT)
+RETURNS TIMESTAMP AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT id, parent_id FROM folders WHERE id = doc_folder_id
+        UNION
+        SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+    )
+    SELECT max(r.retain_until) FROM retention_rules r
+    WHERE r.retain_until > now()
+      AND (r.owner_id IS NULL OR r.owner_id = doc_user_id)
+      AND (r.tag IS NULL OR EXISTS (SELECT 1 FROM document_tags t WHERE t.document_id = doc_id AND t.tag = r.tag))
+      AND (r.folder_id IS NULL OR r.folder_id IN (SELECT id FROM ancestors))
+      AND (r.mime IS NULL OR doc_mime LIKE replace(r.mime, '*', '%'))
+$$ LANGUAGE sql STABLE;
+
+-- Проверка выполняется в БД, поэтому ее не обходят ни каскадное удаление, ни фоновые задачи.
+-- Коды DSH01 (legal hold) и DSR01 (правило хранения) приложение отличает от прочих ошибок.
+CREATE OR REPLACE FUNCTION documents_retention() RETURNS trigger AS $$
+DECLARE
+    retained TIMESTAMP;
+BEGIN
+    IF OLD.legal_hold THEN
+        RAISE EXCEPTION 'document % is under legal hold', OLD.id USING ERRCODE = 'DSH01';
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        retained := document_retained_until(OLD.id, OLD.user_id, OLD.folder_id, OLD.mime);
+        IF retained IS NOT NULL THEN
+            RAISE EXCEPTION 'document % is retained until %', OLD.id, retained USING ERRCODE = 'DSR01';
+        END IF;
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS documents_retention_delete ON documents;
+CREATE TRIGGER documents_retention_delete
+    BEFORE DELETE ON documents
+    FOR EACH ROW EXECUTE FUNCTION documents_retention();
+
+-- Изменение запрещено только для пользовательских полей: служебные колонки (текст, миниатюры,
+-- антивирусная проверка, ключи шифрования) фоновые задачи обновляют и под legal hold
+DROP TRIGGER IF EXISTS documents_legal_hold_update ON documents;
+CREATE TRIGGER documents_legal_hold_update
+    BEFORE UPDATE OF user_id, name, public, mime, folder_id, attributes ON documents
+    FOR EACH ROW WHEN (OLD.legal_hold) EXECUTE FUNCTION documents_retention();
+
+COMMIT;
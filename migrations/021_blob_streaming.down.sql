@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS blobs_unlink ON blobs;
+DROP FUNCTION IF EXISTS blobs_unlink();
+ALTER TABLE blobs DROP CONSTRAINT IF EXISTS blobs_content;
+
+UPDATE blobs SET data = lo_get(lo) WHERE lo IS NOT NULL;
+SELECT lo_unlink(lo) FROM blobs WHERE lo IS NOT NULL;
+
+ALTER TABLE blobs DROP COLUMN IF EXISTS lo;
+ALTER TABLE blobs ALTER COLUMN data SET NOT NULL;
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+-- Новые блобы записываются потоком в large object (lo), без сборки содержимого в памяти.
+-- Блобы, записанные раньше, остаются в data; у каждой строки заполнено ровно одно из двух.
+ALTER TABLE blobs ALTER COLUMN data DROP NOT NULL;
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS lo OID;
+ALTER TABLE blobs DROP CONSTRAINT IF EXISTS blobs_content;
+ALTER TABLE blobs ADD CONSTRAINT blobs_content CHECK ((data IS NULL) <> (lo IS NULL));
+
+-- Large object не удаляется вместе со строкой, его освобождает триггер при удалении блоба
+-- сборщиком и при перезаписи блоба из карантина
+CREATE OR REPLACE FUNCTION blobs_unlink() RETURNS trigger AS $$
+BEGIN
+    IF OLD.lo IS NOT NULL AND (TG_OP = 'DELETE' OR NEW.lo IS DISTINCT FROM OLD.lo) THEN
+        PERFORM lo_unlink(OLD.lo);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS blobs_unlink ON blobs;
+CREATE TRIGGER blobs_unlink
+    AFTER DELETE OR UPDATE OF lo ON blobs
+    FOR EACH ROW EXECUTE FUNCTION blobs_unlink();
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE documents DROP COLUMN IF EXISTS search_text;
+ALTER TABLE documents DROP COLUMN IF EXISTS search_json;
+
+ALTER TABLE documents ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector(search_lang, coalesce(name, '')), 'A') ||
+    setweight(jsonb_to_tsvector(search_lang, attributes, '["string"]'), 'B') ||
+    setweight(jsonb_to_tsvector(search_lang, coalesce(json_data, '{}'::jsonb), '["string"]'), 'C') ||
+    setweight(to_tsvector(search_lang, left(coalesce(content_text, ''), 262144)), 'D')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_documents_search ON documents USING GIN (search_vector);
+
+COMMIT;
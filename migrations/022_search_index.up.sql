@@ -0,0 +1,25 @@
+BEGIN;
+
+-- Индекс по JSON и тексту файла хранится отдельно от содержимого и заполняется при записи
+-- из открытых данных: у зашифрованных документов json_data и content_text пустые, и индекс,
+-- вычисляемый из них, терял бы это содержимое. Веса прежние: A - имя, B - строковые атрибуты,
+-- C - строки JSON-документа, D - текст файла.
+ALTER TABLE documents DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_json TSVECTOR;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_text TSVECTOR;
+
+UPDATE documents SET search_json = jsonb_to_tsvector(search_lang, json_data, '["string"]')
+WHERE json_data IS NOT NULL;
+UPDATE documents SET search_text = to_tsvector(search_lang, left(content_text, 262144))
+WHERE content_text IS NOT NULL;
+
+ALTER TABLE documents ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector(search_lang, coalesce(name, '')), 'A') ||
+    setweight(jsonb_to_tsvector(search_lang, attributes, '["string"]'), 'B') ||
+    setweight(coalesce(search_json, ''::tsvector), 'C') ||
+    setweight(coalesce(search_text, ''::tsvector), 'D')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_documents_search ON documents USING GIN (search_vector);
+
+COMMIT;
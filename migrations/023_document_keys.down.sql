@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Ключи содержимого, перенесенные в документы, обратно не переносятся: перед откатом
+-- зашифрованные таким образом блобы нужно выгрузить
+ALTER TABLE blobs DROP COLUMN IF EXISTS sealed;
+
+ALTER TABLE document_thumbnails DROP COLUMN IF EXISTS encrypted;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS text_enc;
+ALTER TABLE documents DROP COLUMN IF EXISTS content_key;
+
+COMMIT;
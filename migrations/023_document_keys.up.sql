@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Ключ данных есть у каждого документа (key_id, wrapped_key), им шифруются JSON, извлеченный
+-- текст (text_enc вместо content_text) и превью. Содержимое файла шифруется ключом блоба, который
+-- хранится у документов в content_key, зашифрованным ключом документа: одно содержимое делят
+-- несколько документов, но каждый получает его ключ только через свой.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_key BYTEA;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS text_enc BYTEA;
+
+ALTER TABLE document_thumbnails ADD COLUMN IF NOT EXISTS encrypted BOOLEAN NOT NULL DEFAULT false;
+
+-- sealed - содержимое зашифровано ключом, который хранят документы. Пока на блоб не сослался
+-- документ, его ключ обернут мастер-ключом в key_id и wrapped_key, как у блобов, записанных раньше.
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS sealed BOOLEAN NOT NULL DEFAULT false;
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS folders_retention_update ON folders;
+DROP FUNCTION IF EXISTS folders_retention_move();
+DROP FUNCTION IF EXISTS check_document_tags_retention(UUID, TEXT[]);
+DROP TRIGGER IF EXISTS documents_retention_update ON documents;
+DROP FUNCTION IF EXISTS documents_retention_change();
+
+CREATE OR REPLACE FUNCTION document_retained_until(doc_id UUID, doc_user_id UUID, doc_folder_id UUID, doc_mime TEXT)
+RETURNS TIMESTAMP AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT id, parent_id FROM folders WHERE id = doc_folder_id
+        UNION
+        SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+    )
+    SELECT max(r.retain_until) FROM retention_rules r
+    WHERE r.retain_until > now()
+      AND (r.owner_id IS NULL OR r.owner_id = doc_user_id)
+      AND (r.tag IS NULL OR EXISTS (SELECT 1 FROM document_tags t WHERE t.document_id = doc_id AND t.tag = r.tag))
+      AND (r.folder_id IS NULL OR r.folder_id IN (SELECT id FROM ancestors))
+      AND (r.mime IS NULL OR retention_mime_matches(doc_mime, r.mime))
+$$ LANGUAGE sql STABLE;
+
+DROP FUNCTION IF EXISTS document_state_retained_until(UUID, UUID, TEXT, TEXT[]);
+
+COMMIT;
@@ -0,0 +1,119 @@
+BEGIN;
+
+-- Срок хранения документа в заданном состоянии. Владелец, папка, тип и теги передаются явно,
+-- чтобы проверить изменение до того, как оно записано.
+CREATE OR REPLACE FUNCTION document_state_retained_until(doc_user_id UUID, doc_folder_id UUID, doc_mime TEXT, doc_tags TEXT[])
+RETURNS TIMESTAMP AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT id, parent_id FROM folders WHERE id = doc_folder_id
+        UNION
+        SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+    )
+    SELECT max(r.retain_until) FROM retention_rules r
+    WHERE r.retain_until > now()
+      AND (r.owner_id IS NULL OR r.owner_id = doc_user_id)
+      AND (r.tag IS NULL OR r.tag = ANY(doc_tags))
+      AND (r.folder_id IS NULL OR r.folder_id IN (SELECT id FROM ancestors))
+      AND (r.mime IS NULL OR retention_mime_matches(doc_mime, r.mime))
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION document_retained_until(doc_id UUID, doc_user_id UUID, doc_folder_id UUID, doc_mime TEXT)
+RETURNS TIMESTAMP AS $$
+    SELECT document_state_retained_until(doc_user_id, doc_folder_id, doc_mime,
+                                         ARRAY(SELECT tag::text FROM document_tags WHERE document_id = doc_id))
+$$ LANGUAGE sql STABLE;
+
+-- Изменение, после которого документ перестал бы подходить под действующее правило или подходил бы
+-- под правило с более ранним сроком, запрещено: иначе ограничение снималось бы сменой тегов, папки,
+-- типа или владельца прямо перед удалением. Код ошибки тот же, что и при удалении (DSR01).
+CREATE OR REPLACE FUNCTION documents_retention_change() RETURNS trigger AS $$
+DECLARE
+    retained TIMESTAMP;
+    kept TIMESTAMP;
+BEGIN
+    retained := document_retained_until(OLD.id, OLD.user_id, OLD.folder_id, OLD.mime);
+    IF retained IS NOT NULL THEN
+        kept := document_retained_until(NEW.id, NEW.user_id, NEW.folder_id, NEW.mime);
+        IF kept IS NULL OR kept < retained THEN
+            RAISE EXCEPTION 'document % is retained until %', OLD.id, retained USING ERRCODE = 'DSR01';
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS documents_retention_update ON documents;
+CREATE TRIGGER documents_retention_update
+    BEFORE UPDATE OF user_id, folder_id, mime ON documents
+    FOR EACH ROW WHEN (OLD.user_id IS DISTINCT FROM NEW.user_id OR OLD.folder_id IS DISTINCT FROM NEW.folder_id
+                       OR OLD.mime IS DISTINCT FROM NEW.mime)
+    EXECUTE FUNCTION documents_retention_change();
+
+-- Теги заменяются удалением и вставкой, поэтому триггер на document_tags увидел бы промежуточное
+-- состояние. Приложение проверяет итоговый набор тегов этой функцией до записи.
+CREATE OR REPLACE FUNCTION check_document_tags_retention(doc_id UUID, new_tags TEXT[]) RETURNS void AS $$
+DECLARE
+    doc RECORD;
+    retained TIMESTAMP;
+    kept TIMESTAMP;
+BEGIN
+    SELECT id, user_id, folder_id, mime INTO doc FROM documents WHERE id = doc_id;
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+    retained := document_retained_until(doc.id, doc.user_id, doc.folder_id, doc.mime);
+    IF retained IS NULL THEN
+        RETURN;
+    END IF;
+    kept := document_state_retained_until(doc.user_id, doc.folder_id, doc.mime, new_tags);
+    IF kept IS NULL OR kept < retained THEN
+        RAISE EXCEPTION 'document % is retained until %', doc_id, retained USING ERRCODE = 'DSR01';
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Перенос папки выводит вложенные документы из-под правил по ее прежним родительским папкам.
+-- Триггер выполняется после переноса и сравнивает потерянные правила с новым сроком документа.
+CREATE OR REPLACE FUNCTION folders_retention_move() RETURNS trigger AS $$
+DECLARE
+    doc_id UUID;
+    retained TIMESTAMP;
+BEGIN
+    WITH RECURSIVE old_parents AS (
+        SELECT id, parent_id FROM folders WHERE id = OLD.parent_id
+        UNION
+        SELECT f.id, f.parent_id FROM folders f JOIN old_parents p ON f.id = p.parent_id
+    ), new_parents AS (
+        SELECT id, parent_id FROM folders WHERE id = NEW.parent_id
+        UNION
+        SELECT f.id, f.parent_id FROM folders f JOIN new_parents p ON f.id = p.parent_id
+    ), tree AS (
+        SELECT id FROM folders WHERE id = NEW.id
+        UNION
+        SELECT f.id FROM folders f JOIN tree t ON f.parent_id = t.id
+    )
+    SELECT d.id, r.retain_until INTO doc_id, retained
+    FROM retention_rules r
+    JOIN documents d ON d.folder_id IN (SELECT id FROM tree)
+    WHERE r.retain_until > now()
+      AND r.folder_id IN (SELECT id FROM old_parents)
+      AND r.folder_id NOT IN (SELECT id FROM new_parents)
+      AND (r.owner_id IS NULL OR r.owner_id = d.user_id)
+      AND (r.tag IS NULL OR EXISTS (SELECT 1 FROM document_tags dt WHERE dt.document_id = d.id AND dt.tag = r.tag))
+      AND (r.mime IS NULL OR retention_mime_matches(d.mime, r.mime))
+      AND r.retain_until > COALESCE(document_retained_until(d.id, d.user_id, d.folder_id, d.mime), '-infinity'::timestamp)
+    LIMIT 1;
+    IF doc_id IS NOT NULL THEN
+        RAISE EXCEPTION 'document % is retained until %', doc_id, retained USING ERRCODE = 'DSR01';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS folders_retention_update ON folders;
+CREATE TRIGGER folders_retention_update
+    AFTER UPDATE OF parent_id ON folders
+    FOR EACH ROW WHEN (OLD.parent_id IS DISTINCT FROM NEW.parent_id)
+    EXECUTE FUNCTION folders_retention_move();
+
+COMMIT;
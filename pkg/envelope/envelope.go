@@ -0,0 +1,200 @@
+// Package envelope реализует envelope-шифрование AES-256-GCM: данные шифруются случайным
+// ключом данных (DEK), а он сам - мастер-ключом из keyring. Смена мастер-ключа требует
+// только переобернуть DEK, сами данные не перечитываются.
+package envelope
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paudarco/doc-storage/internal/config"
+)
+
+// KeySize размер мастер-ключа и ключа данных
+const KeySize = 32
+
+var (
+	ErrUnknownKey = errors.New("unknown master key id")
+	ErrInvalidKey = errors.New("master key must be 32 bytes encoded in base64")
+	ErrDecrypt    = errors.New("decryption failed")
+	ErrDisabled   = errors.New("encryption is disabled: ENCRYPTION_KEY_ID is not set")
+)
+
+// Keyring набор мастер-ключей по ID. Новые ключи данных оборачиваются активным ключом,
+// остальные нужны, чтобы читать данные до ротации. nil Keyring означает выключенное шифрование.
+type Keyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewKeyring собирает ключи из ENCRYPTION_KEYS и ENCRYPTION_KEY_FILE (строки "kid:base64",
+// пустые строки и строки с # пропускаются). Без ENCRYPTION_KEY_ID шифрование выключено и возвращается nil.
+func NewKeyring(cfg config.Encryption) (*Keyring, error) {
+	if cfg.EncryptionKeyID == "" {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: make(map[string][]byte), active: cfg.EncryptionKeyID}
+	for id, encoded := range cfg.EncryptionKeys {
+		if err := k.add(id, encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.EncryptionKeyFile != "" {
+		file, err := os.Open(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			id, encoded, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("%s: expected kid:base64 line", cfg.EncryptionKeyFile)
+			}
+			if err := k.add(strings.TrimSpace(id), strings.TrimSpace(encoded)); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := k.keys[k.active]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, k.active)
+	}
+	return k, nil
+}
+
+func (k *Keyring) add(id, encoded string) error {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != KeySize {
+		return fmt.Errorf("%w: %s", ErrInvalidKey, id)
+	}
+	k.keys[id] = key
+	return nil
+}
+
+// Enabled сообщает, шифруются ли новые данные
+func (k *Keyring) Enabled() bool {
+	return k != nil
+}
+
+// ActiveID ID мастер-ключа, которым оборачиваются новые ключи данных
+func (k *Keyring) ActiveID() string {
+	if k == nil {
+		return ""
+	}
+	return k.active
+}
+
+// NewKey создает ключ данных и возвращает ID мастер-ключа, сам ключ для шифрования потоком
+// через NewWriter и его обернутую копию для хранения
+func (k *Keyring) NewKey() (string, []byte, []byte, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", nil, nil, err
+	}
+
+	wrapped, err := k.wrap(k.active, dek)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return k.active, dek, wrapped, nil
+}
+
+// Seal шифрует plaintext новым ключом данных и возвращает ID мастер-ключа, обернутый ключ данных
+// и шифротекст
+func (k *Keyring) Seal(plaintext []byte) (string, []byte, []byte, error) {
+	keyID, dek, wrapped, err := k.NewKey()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	ciphertext, err := Encrypt(dek, plaintext)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return keyID, wrapped, ciphertext, nil
+}
+
+// Open расшифровывает данные, зашифрованные Seal
+func (k *Keyring) Open(keyID string, wrapped, ciphertext []byte) ([]byte, error) {
+	dek, err := k.Unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(dek, ciphertext)
+}
+
+// Unwrap расшифровывает ключ данных мастер-ключом keyID
+func (k *Keyring) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	master, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dek, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dek, nil
+}
+
+// Rewrap переоборачивает ключ данных активным мастер-ключом
+func (k *Keyring) Rewrap(keyID string, wrapped []byte) (string, []byte, error) {
+	dek, err := k.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", nil, err
+	}
+	rewrapped, err := k.wrap(k.active, dek)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.active, rewrapped, nil
+}
+
+// wrap шифрует ключ данных, ID мастер-ключа входит в AAD
+func (k *Keyring) wrap(keyID string, dek []byte) ([]byte, error) {
+	aead, err := newGCM(k.keys[keyID])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dek, []byte(keyID)), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
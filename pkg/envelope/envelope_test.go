@@ -0,0 +1,238 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paudarco/doc-storage/internal/config"
+)
+
+func randomKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newDEK(t *testing.T) []byte {
+	t.Helper()
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		t.Fatal(err)
+	}
+	return dek
+}
+
+func segments(n int) int {
+	if n == 0 {
+		return 1
+	}
+	return (n + SegmentSize - 1) / SegmentSize
+}
+
+func TestSegmentFormat(t *testing.T) {
+	dek := newDEK(t)
+
+	for _, size := range []int{0, 1, SegmentSize - 1, SegmentSize, SegmentSize + 1, 3*SegmentSize + 17} {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+
+		ciphertext, err := Encrypt(dek, plaintext)
+		if err != nil {
+			t.Fatalf("size %d: encrypt: %v", size, err)
+		}
+		if ciphertext[0] != formatVersion {
+			t.Errorf("size %d: version byte = %d, want %d", size, ciphertext[0], formatVersion)
+		}
+		if want := headerSize + size + segments(size)*tagSize; len(ciphertext) != want {
+			t.Errorf("size %d: ciphertext length = %d, want %d", size, len(ciphertext), want)
+		}
+
+		decrypted, err := Decrypt(dek, ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("size %d: decrypted data differs", size)
+		}
+	}
+}
+
+func TestStreamSmallWrites(t *testing.T) {
+	dek := newDEK(t)
+	plaintext := make([]byte, 2*SegmentSize+100)
+	_, _ = rand.Read(plaintext)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Запись кусками, не совпадающими с границами сегментов
+	for rest := plaintext; len(rest) > 0; {
+		n := min(len(rest), 1000)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Чтение маленьким буфером
+	var out bytes.Buffer
+	if _, err := io.CopyBuffer(&out, struct{ io.Reader }{r}, make([]byte, 333)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Error("stream round trip differs")
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	dek := newDEK(t)
+	plaintext := bytes.Repeat([]byte("x"), 2*SegmentSize+10)
+	ciphertext, err := Encrypt(dek, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := SegmentSize + tagSize
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+		dek    []byte
+	}{
+		{name: "wrong key", mutate: func(c []byte) []byte { return c }, dek: newDEK(t)},
+		{name: "flipped byte", mutate: func(c []byte) []byte { c[headerSize+5] ^= 1; return c }},
+		{name: "unknown version", mutate: func(c []byte) []byte { c[0] = formatVersion + 1; return c }},
+		{name: "short header", mutate: func(c []byte) []byte { return c[:headerSize-1] }},
+		{name: "last segment cut", mutate: func(c []byte) []byte { return c[:headerSize+2*full] }},
+		{name: "truncated segment", mutate: func(c []byte) []byte { return c[:len(c)-1] }},
+		{name: "swapped segments", mutate: func(c []byte) []byte {
+			first := append([]byte(nil), c[headerSize:headerSize+full]...)
+			copy(c[headerSize:], c[headerSize+full:headerSize+2*full])
+			copy(c[headerSize+full:], first)
+			return c
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := dek
+			if tt.dek != nil {
+				key = tt.dek
+			}
+			mutated := tt.mutate(append([]byte(nil), ciphertext...))
+			if _, err := Decrypt(key, mutated); !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("decrypt error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, newKey := randomKey(t), randomKey(t)
+	plaintext := []byte("secret document")
+
+	old, err := NewKeyring(config.Encryption{
+		EncryptionKeys:  map[string]string{"k1": oldKey},
+		EncryptionKeyID: "k1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, wrapped, ciphertext, err := old.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "k1" {
+		t.Fatalf("key id = %q, want k1", keyID)
+	}
+
+	rotated, err := NewKeyring(config.Encryption{
+		EncryptionKeys:  map[string]string{"k1": oldKey, "k2": newKey},
+		EncryptionKeyID: "k2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Старые данные читаются до переобертки
+	if got, err := rotated.Open(keyID, wrapped, ciphertext); err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("open with old key: %q, %v", got, err)
+	}
+
+	newID, rewrapped, err := rotated.Rewrap(keyID, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID != "k2" {
+		t.Fatalf("rewrapped key id = %q, want k2", newID)
+	}
+
+	// После переобертки старый ключ больше не нужен, шифротекст тот же
+	onlyNew, err := NewKeyring(config.Encryption{
+		EncryptionKeys:  map[string]string{"k2": newKey},
+		EncryptionKeyID: "k2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := onlyNew.Open(newID, rewrapped, ciphertext); err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("open after rewrap: %q, %v", got, err)
+	}
+	if _, err := onlyNew.Open(keyID, wrapped, ciphertext); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("open with removed key error = %v, want ErrUnknownKey", err)
+	}
+	// ID мастер-ключа входит в AAD: обертку нельзя выдать за другой ключ
+	if _, err := rotated.Open("k1", rewrapped, ciphertext); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("open with wrong key id error = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	key := randomKey(t)
+	file := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(file, []byte("# master keys\n\nk2 : "+key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.Encryption
+		enabled bool
+		wantErr error
+	}{
+		{name: "disabled", cfg: config.Encryption{EncryptionKeys: map[string]string{"k1": key}}},
+		{name: "env keys", cfg: config.Encryption{EncryptionKeys: map[string]string{"k1": key}, EncryptionKeyID: "k1"}, enabled: true},
+		{name: "key file", cfg: config.Encryption{EncryptionKeyFile: file, EncryptionKeyID: "k2"}, enabled: true},
+		{name: "unknown active", cfg: config.Encryption{EncryptionKeys: map[string]string{"k1": key}, EncryptionKeyID: "k3"}, wantErr: ErrUnknownKey},
+		{name: "short key", cfg: config.Encryption{EncryptionKeys: map[string]string{"k1": "c2hvcnQ="}, EncryptionKeyID: "k1"}, wantErr: ErrInvalidKey},
+		{name: "bad base64", cfg: config.Encryption{EncryptionKeys: map[string]string{"k1": "!!"}, EncryptionKeyID: "k1"}, wantErr: ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && k.Enabled() != tt.enabled {
+				t.Fatalf("enabled = %t, want %t", k.Enabled(), tt.enabled)
+			}
+		})
+	}
+}
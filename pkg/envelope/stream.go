@@ -0,0 +1,196 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+// Формат шифротекста: версия (1 байт) и префикс nonce (7 байт), затем сегменты по SegmentSize
+// байт открытого текста, каждый запечатан AES-GCM отдельно. Nonce сегмента - префикс, номер
+// сегмента (4 байта) и признак последнего сегмента (1 байт), поэтому сегменты нельзя
+// переставить или отрезать. Данные можно шифровать и расшифровывать потоком.
+const (
+	SegmentSize = 64 * 1024
+
+	formatVersion = 1
+	prefixSize    = 7
+	headerSize    = 1 + prefixSize
+	tagSize       = 16
+)
+
+// Encrypt шифрует данные целиком ключом данных dek
+func Encrypt(dek, plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(headerSize + len(plaintext) + (len(plaintext)/SegmentSize+1)*tagSize)
+	w, err := NewWriter(&buf, dek)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt расшифровывает данные, зашифрованные Encrypt или Writer
+func Decrypt(dek, ciphertext []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(ciphertext), dek)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+type segmenter struct {
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+}
+
+func (s *segmenter) nonce(last bool) []byte {
+	nonce := make([]byte, 0, s.aead.NonceSize())
+	nonce = append(nonce, s.prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, s.counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// Writer шифрует поток, держа в памяти не больше одного сегмента. Close обязателен.
+type Writer struct {
+	w   io.Writer
+	seg segmenter
+	buf []byte
+}
+
+func NewWriter(w io.Writer, dek []byte) (*Writer, error) {
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	header[0] = formatVersion
+	if _, err := rand.Read(header[1:]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:   w,
+		seg: segmenter{aead: aead, prefix: header[1:]},
+		buf: make([]byte, 0, SegmentSize),
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// Полный сегмент пишется, только когда за ним есть данные: последний сегмент
+		// запечатывается в Close с признаком конца
+		if len(w.buf) == SegmentSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):SegmentSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *Writer) Close() error {
+	return w.flush(true)
+}
+
+func (w *Writer) flush(last bool) error {
+	sealed := w.seg.aead.Seal(nil, w.seg.nonce(last), w.buf, nil)
+	w.seg.counter++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+// Reader расшифровывает поток, записанный Writer
+type Reader struct {
+	r     io.Reader
+	seg   segmenter
+	in    []byte // прочитанный, но еще не расшифрованный шифротекст
+	out   []byte // расшифрованный, но еще не отданный текст
+	done  bool
+	inErr error
+}
+
+func NewReader(r io.Reader, dek []byte) (*Reader, error) {
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || header[0] != formatVersion {
+		return nil, ErrDecrypt
+	}
+
+	return &Reader{
+		r:   r,
+		seg: segmenter{aead: aead, prefix: header[1:]},
+		in:  make([]byte, 0, 2*(SegmentSize+tagSize)),
+	}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// next расшифровывает следующий сегмент. Сегмент последний, если за ним нет данных,
+// поэтому читается на байт больше полного сегмента.
+func (r *Reader) next() error {
+	full := SegmentSize + tagSize
+	for len(r.in) <= full && r.inErr == nil {
+		n, err := r.r.Read(r.in[len(r.in):cap(r.in)])
+		r.in = r.in[:len(r.in)+n]
+		if err != nil {
+			r.inErr = err
+		}
+	}
+	if r.inErr != nil && r.inErr != io.EOF {
+		return r.inErr
+	}
+
+	last := len(r.in) <= full
+	size := full
+	if last {
+		size = len(r.in)
+	}
+	plain, err := r.seg.aead.Open(nil, r.seg.nonce(last), r.in[:size], nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	r.seg.counter++
+	r.in = r.in[:copy(r.in, r.in[size:])]
+	r.out = plain
+	r.done = last
+	return nil
+}
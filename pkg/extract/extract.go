@@ -0,0 +1,102 @@
+// Package extract извлекает текст из файлов разных форматов для поиска и превью.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxText ограничивает объем извлеченного текста
+const MaxText = 256 * 1024
+
+var (
+	ErrUnsupported = errors.New("unsupported mime type")
+	ErrEncrypted   = errors.New("document is encrypted")
+)
+
+// Extractor извлекает текст из содержимого файла
+type Extractor interface {
+	Extract(data []byte) (string, error)
+}
+
+// ExtractorFunc позволяет использовать функцию как Extractor
+type ExtractorFunc func(data []byte) (string, error)
+
+func (f ExtractorFunc) Extract(data []byte) (string, error) {
+	return f(data)
+}
+
+// Registry сопоставляет MIME-типы с экстракторами
+type Registry struct {
+	extractors map[string]Extractor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		extractors: make(map[string]Extractor),
+	}
+}
+
+// Default возвращает реестр со всеми встроенными экстракторами
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register(ExtractorFunc(Plain), "text/plain", "text/markdown", "text/x-markdown", "text/csv")
+	r.Register(ExtractorFunc(HTML), "text/html", "application/xhtml+xml")
+	r.Register(ExtractorFunc(PDF), "application/pdf")
+	r.Register(ExtractorFunc(DOCX), "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	r.Register(ExtractorFunc(XLSX), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	r.Register(ExtractorFunc(ODT), "application/vnd.oasis.opendocument.text")
+	return r
+}
+
+func (r *Registry) Register(ex Extractor, mimeTypes ...string) {
+	for _, m := range mimeTypes {
+		r.extractors[m] = ex
+	}
+}
+
+// Lookup ищет экстрактор по MIME-типу, параметры типа (charset и т.п.) игнорируются
+func (r *Registry) Lookup(mimeType string) (Extractor, bool) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return nil, false
+	}
+	ex, ok := r.extractors[mediaType]
+	return ex, ok
+}
+
+// Extract извлекает текст подходящим экстрактором. Результат приводится к валидному UTF-8
+// и обрезается до MaxText, паника экстрактора на поврежденном файле возвращается как ошибка.
+func (r *Registry) Extract(mimeType string, data []byte) (text string, err error) {
+	ex, ok := r.Lookup(mimeType)
+	if !ok {
+		return "", ErrUnsupported
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			text, err = "", fmt.Errorf("malformed document: %v", p)
+		}
+	}()
+
+	text, err = ex.Extract(data)
+	if err != nil {
+		return "", err
+	}
+
+	return truncate(strings.ToValidUTF8(text, ""), MaxText), nil
+}
+
+// truncate обрезает строку до limit байт, не разрывая UTF-8 символы
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
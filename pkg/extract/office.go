@@ -0,0 +1,276 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxXMLPart ограничивает распакованный размер одной XML-части архива (защита от zip-бомб)
+const maxXMLPart = 64 << 20
+
+// xmlTextRules описывает, какие элементы XML содержат текст и какие разделяют его
+type xmlTextRules struct {
+	text   map[string]bool // текст берется только внутри этих элементов
+	lines  map[string]bool // конец элемента дает перевод строки
+	spaces map[string]bool // пустые элементы, заменяемые пробелом или табуляцией
+}
+
+var (
+	docxRules = xmlTextRules{
+		text:   map[string]bool{"t": true},
+		lines:  map[string]bool{"p": true, "br": true, "cr": true},
+		spaces: map[string]bool{"tab": true},
+	}
+	odtRules = xmlTextRules{
+		text:   map[string]bool{"p": true, "h": true},
+		lines:  map[string]bool{"p": true, "h": true, "line-break": true},
+		spaces: map[string]bool{"s": true, "tab": true},
+	}
+)
+
+// DOCX извлекает текст основного документа Word
+func DOCX(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	r, err := openZipPart(zr, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	return xmlText(r, docxRules)
+}
+
+// ODT извлекает текст документа OpenDocument
+func ODT(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	r, err := openZipPart(zr, "content.xml")
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	return xmlText(r, odtRules)
+}
+
+// XLSX извлекает значения ячеек всех листов: ячейки разделяются табуляцией, строки переводом строки
+func XLSX(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	shared, err := xlsxSharedStrings(zr)
+	if err != nil {
+		return "", err
+	}
+
+	var sheets []string
+	for _, f := range zr.File {
+		if path.Dir(f.Name) == "xl/worksheets" && strings.HasSuffix(f.Name, ".xml") {
+			sheets = append(sheets, f.Name)
+		}
+	}
+	sort.Strings(sheets)
+
+	var sb strings.Builder
+	for _, name := range sheets {
+		if err := xlsxSheetText(zr, name, shared, &sb); err != nil {
+			return "", err
+		}
+		if sb.Len() >= MaxText {
+			break
+		}
+	}
+	return sb.String(), nil
+}
+
+func xlsxSharedStrings(zr *zip.Reader) ([]string, error) {
+	r, err := openZipPart(zr, "xl/sharedStrings.xml")
+	if errors.Is(err, errPartNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var shared []string
+	var current strings.Builder
+	inText := false
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return shared, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				shared = append(shared, current.String())
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+}
+
+func xlsxSheetText(zr *zip.Reader, name string, shared []string, sb *strings.Builder) error {
+	r, err := openZipPart(zr, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var cellType string
+	var value strings.Builder
+	inValue := false
+	firstCell := true
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "c":
+				cellType = ""
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "t" {
+						cellType = attr.Value
+					}
+				}
+				value.Reset()
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				text := value.String()
+				if cellType == "s" {
+					idx, err := strconv.Atoi(strings.TrimSpace(text))
+					if err != nil || idx < 0 || idx >= len(shared) {
+						return fmt.Errorf("invalid shared string index %q", text)
+					}
+					text = shared[idx]
+				}
+				if text == "" {
+					continue
+				}
+				if !firstCell {
+					sb.WriteByte('\t')
+				}
+				sb.WriteString(text)
+				firstCell = false
+			case "row":
+				if !firstCell {
+					sb.WriteByte('\n')
+				}
+				firstCell = true
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+}
+
+// xmlText собирает текст XML-документа по правилам rules
+func xmlText(r io.Reader, rules xmlTextRules) (string, error) {
+	var sb strings.Builder
+	depth := 0
+	dec := xml.NewDecoder(r)
+	for sb.Len() < MaxText {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if rules.text[t.Name.Local] {
+				depth++
+			}
+			if rules.spaces[t.Name.Local] {
+				sb.WriteByte(' ')
+			}
+		case xml.EndElement:
+			if rules.text[t.Name.Local] && depth > 0 {
+				depth--
+			}
+			if rules.lines[t.Name.Local] {
+				sb.WriteByte('\n')
+			}
+		case xml.CharData:
+			if depth > 0 {
+				sb.Write(t)
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+var errPartNotFound = errors.New("archive part not found")
+
+type limitedPart struct {
+	io.Reader
+	io.Closer
+}
+
+// openZipPart открывает часть архива с ограничением распакованного размера
+func openZipPart(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		if f.UncompressedSize64 > maxXMLPart {
+			return nil, fmt.Errorf("%s is too large", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		return limitedPart{Reader: io.LimitReader(rc, maxXMLPart), Closer: rc}, nil
+	}
+	return nil, fmt.Errorf("%s: %w", name, errPartNotFound)
+}
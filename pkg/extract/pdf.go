@@ -0,0 +1,31 @@
+package extract
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDF извлекает текст всех страниц. Зашифрованные документы не поддерживаются.
+func PDF(data []byte) (string, error) {
+	reader, err := pdf.NewReaderEncrypted(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		if err == pdf.ErrInvalidPassword {
+			return "", ErrEncrypted
+		}
+		return "", err
+	}
+
+	text, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, io.LimitReader(text, MaxText)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,50 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Plain возвращает содержимое текстового файла как есть
+func Plain(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// HTML собирает текстовые узлы документа, пропуская скрипты и стили
+func HTML(data []byte) (string, error) {
+	var sb strings.Builder
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	skip := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return sb.String(), nil
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); isSkippedTag(name) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); isSkippedTag(name) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			if text := strings.TrimSpace(string(tokenizer.Text())); text != "" {
+				sb.WriteString(text)
+				sb.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func isSkippedTag(name []byte) bool {
+	switch string(name) {
+	case "script", "style", "noscript", "template":
+		return true
+	}
+	return false
+}
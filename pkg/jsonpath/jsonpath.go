@@ -0,0 +1,101 @@
+// Package jsonpath выбирает поддерево разобранного JSON по упрощенному пути JSONPath:
+// "$.a.b[0]", "a.b.0", "$['key with dots'].c". Фильтры и wildcard не поддерживаются.
+package jsonpath
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPath = errors.New("invalid path")
+	ErrNotFound    = errors.New("path not found")
+)
+
+// Parse разбирает путь на сегменты: строки для ключей объектов, числа для индексов массивов
+func Parse(path string) ([]interface{}, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+
+	var segments []interface{}
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			if end == 0 {
+				return nil, ErrInvalidPath
+			}
+			segments = append(segments, keyOrIndex(path[:end]))
+			path = path[end:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, ErrInvalidPath
+			}
+			inner := path[1:end]
+			path = path[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, inner[1:len(inner)-1])
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, ErrInvalidPath
+			}
+			segments = append(segments, index)
+		default:
+			// Путь без ведущих "$." : "a.b"
+			if len(segments) > 0 {
+				return nil, ErrInvalidPath
+			}
+			path = "." + path
+		}
+	}
+	return segments, nil
+}
+
+// Select возвращает поддерево data по пути path. data - результат json.Unmarshal в interface{}.
+func Select(data interface{}, path string) (interface{}, error) {
+	segments, err := Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := data
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			key, ok := segment.(string)
+			if !ok {
+				key = strconv.Itoa(segment.(int))
+			}
+			value, exists := node[key]
+			if !exists {
+				return nil, ErrNotFound
+			}
+			current = value
+		case []interface{}:
+			index, ok := segment.(int)
+			if !ok || index >= len(node) {
+				return nil, ErrNotFound
+			}
+			current = node[index]
+		default:
+			return nil, ErrNotFound
+		}
+	}
+	return current, nil
+}
+
+// keyOrIndex трактует числовой сегмент точечной записи как индекс массива
+func keyOrIndex(segment string) interface{} {
+	if index, err := strconv.Atoi(segment); err == nil && index >= 0 {
+		return index
+	}
+	return segment
+}
@@ -1,26 +1,26 @@
-package redis
-
-import (
-	"context"
-	"errors"
-	"time"
-
-	"github.com/paudarco/doc-storage/internal/config"
-	"github.com/redis/go-redis/v9"
-)
-
-func NewRedisClient(cfg config.Redis) (*redis.Client, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Host + ":" + cfg.Port,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, errors.New("failed to connect to redis: " + err.Error())
-	}
-
-	return client, nil
-}
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/paudarco/doc-storage/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+func NewRedisClient(cfg config.Redis) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Host + ":" + cfg.Port,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, errors.New("failed to connect to redis: " + err.Error())
+	}
+
+	return client, nil
+}
@@ -0,0 +1,120 @@
+// Package scan проверяет содержимое файлов антивирусом. Clamd работает с демоном ClamAV
+// по протоколу INSTREAM через TCP или unix сокет, Noop пропускает все файлы.
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Result результат проверки, Signature - имя найденной сигнатуры
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner проверяет содержимое. Ошибка означает, что результат неизвестен.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Noop считает любое содержимое чистым
+type Noop struct{}
+
+func (Noop) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return Result{}, nil
+}
+
+// chunkSize размер блока INSTREAM, не должен превышать StreamMaxLength демона
+const chunkSize = 64 * 1024
+
+var ErrUnexpectedReply = errors.New("unexpected clamd reply")
+
+// Clamd клиент clamd. Address - "unix:///run/clamav/clamd.ctl", "tcp://host:3310" или "host:3310".
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func NewClamd(address string, timeout time.Duration) *Clamd {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	}
+	return &Clamd{network: network, address: address, timeout: timeout}
+}
+
+// Scan отправляет содержимое командой zINSTREAM блоками с 4-байтовой длиной и ждет ответа
+// "stream: OK", "stream: <сигнатура> FOUND" или "<описание> ERROR"
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else if c.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, err
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd закрывает соединение при превышении лимита, причина будет в ответе
+				break
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+	// Блок нулевой длины завершает поток
+	_, _ = conn.Write([]byte{0, 0, 0, 0})
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return Result{}, err
+	}
+	return parseReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(reply)
+	switch {
+	case strings.HasSuffix(reply, " ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSuffix(reply, " ERROR"))
+	case strings.HasSuffix(reply, ": OK"):
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		_, signature, _ := strings.Cut(strings.TrimSuffix(reply, " FOUND"), ": ")
+		return Result{Infected: true, Signature: signature}, nil
+	}
+	return Result{}, fmt.Errorf("%w: %q", ErrUnexpectedReply, reply)
+}
@@ -0,0 +1,174 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClamd принимает zINSTREAM на unix сокете, собирает поток и отвечает reply(data)
+func fakeClamd(t *testing.T, reply func(data []byte) string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "clamd.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(t, conn, reply)
+		}
+	}()
+
+	return "unix://" + path
+}
+
+func serveClamd(t *testing.T, conn net.Conn, reply func(data []byte) string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	cmd, err := r.ReadString(0)
+	if err != nil || cmd != "zINSTREAM\x00" {
+		t.Errorf("unexpected command %q: %v", cmd, err)
+		return
+	}
+
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			t.Errorf("read chunk size: %v", err)
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if size > chunkSize {
+			t.Errorf("chunk of %d bytes exceeds %d", size, chunkSize)
+		}
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			t.Errorf("read chunk: %v", err)
+			return
+		}
+	}
+
+	_, _ = conn.Write([]byte(reply(data.Bytes()) + "\x00"))
+}
+
+func TestClamdScan(t *testing.T) {
+	eicar := []byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*")
+	large := bytes.Repeat([]byte("a"), 3*chunkSize+17)
+
+	// Большой поток приходит несколькими блоками и должен собраться без потерь
+	address := fakeClamd(t, func(data []byte) string {
+		switch {
+		case bytes.Contains(data, []byte("EICAR")):
+			return "stream: Eicar-Signature FOUND"
+		case bytes.Equal(data, large):
+			return "INSTREAM size limit exceeded. ERROR"
+		case len(data) > chunkSize:
+			return "stream: corrupted stream ERROR"
+		case len(data) == 0:
+			return "garbage"
+		}
+		return "stream: OK"
+	})
+	clamd := NewClamd(address, 5*time.Second)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{name: "clean", data: []byte("hello world")},
+		{name: "infected", data: eicar, infected: true, signature: "Eicar-Signature"},
+		{name: "error", data: large, wantErr: true},
+		{name: "unexpected reply", data: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := clamd.Scan(context.Background(), bytes.NewReader(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Fatalf("got %+v, want infected=%t signature=%q", result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestClamdUnavailable(t *testing.T) {
+	clamd := NewClamd("unix://"+filepath.Join(t.TempDir(), "missing.sock"), time.Second)
+	if _, err := clamd.Scan(context.Background(), bytes.NewReader([]byte("data"))); err == nil {
+		t.Fatal("expected dial error")
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		result  Result
+		wantErr bool
+	}{
+		{reply: "stream: OK", result: Result{}},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND\n", result: Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}},
+		{reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+		{reply: "PONG", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		result, err := parseReply(tt.reply)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReply(%q) error = %v, wantErr %t", tt.reply, err, tt.wantErr)
+		}
+		if result != tt.result {
+			t.Errorf("parseReply(%q) = %+v, want %+v", tt.reply, result, tt.result)
+		}
+	}
+
+	if _, err := parseReply("PONG"); !errors.Is(err, ErrUnexpectedReply) {
+		t.Errorf("unknown reply error = %v, want ErrUnexpectedReply", err)
+	}
+}
+
+func TestNewClamdAddress(t *testing.T) {
+	tests := []struct {
+		address, network, want string
+	}{
+		{"unix:///run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl"},
+		{"/run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl"},
+		{"tcp://clamav:3310", "tcp", "clamav:3310"},
+		{"clamav:3310", "tcp", "clamav:3310"},
+	}
+
+	for _, tt := range tests {
+		c := NewClamd(tt.address, 0)
+		if c.network != tt.network || c.address != tt.want {
+			t.Errorf("NewClamd(%q) = %s %s, want %s %s", tt.address, c.network, c.address, tt.network, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package sniff определяет тип содержимого по сигнатуре и сверяет его с заявленным клиентом.
+// Помимо сигнатур net/http распознаются исполняемые файлы, которые DetectContentType
+// считает application/octet-stream.
+package sniff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	OctetStream = "application/octet-stream"
+
+	TypeWindowsExecutable = "application/x-dosexec"
+	TypeELF               = "application/x-executable"
+	TypeMachO             = "application/x-mach-binary"
+	TypeJavaClass         = "application/java-vm"
+	TypeShellScript       = "text/x-shellscript"
+)
+
+// HeadSize столько байт начала содержимого просматривает Detect, при потоковой загрузке
+// достаточно прочитать их заранее
+const HeadSize = 512
+
+// Detect возвращает тип содержимого без параметров, application/octet-stream если тип не распознан
+func Detect(data []byte) string {
+	if len(data) > HeadSize {
+		data = data[:HeadSize]
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("MZ")):
+		return TypeWindowsExecutable
+	case bytes.HasPrefix(data, []byte("\x7fELF")):
+		return TypeELF
+	case bytes.HasPrefix(data, []byte{0xfe, 0xed, 0xfa, 0xce}), bytes.HasPrefix(data, []byte{0xfe, 0xed, 0xfa, 0xcf}),
+		bytes.HasPrefix(data, []byte{0xce, 0xfa, 0xed, 0xfe}), bytes.HasPrefix(data, []byte{0xcf, 0xfa, 0xed, 0xfe}):
+		return TypeMachO
+	case bytes.HasPrefix(data, []byte{0xca, 0xfe, 0xba, 0xbe}) && len(data) >= 8:
+		// Одна сигнатура у универсального Mach-O и class-файла Java: у первого дальше число
+		// архитектур, у второго версия формата не меньше 45
+		if binary.BigEndian.Uint32(data[4:8]) < 45 {
+			return TypeMachO
+		}
+		return TypeJavaClass
+	case bytes.HasPrefix(data, []byte("#!")):
+		return TypeShellScript
+	}
+
+	return BaseType(http.DetectContentType(data))
+}
+
+// BaseType возвращает тип без параметров в нижнем регистре
+func BaseType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// Compatible сообщает, может ли содержимое с распознанным типом detected быть заявлено как declared.
+// Нераспознанное содержимое совместимо с любым типом, заявленный application/octet-stream -
+// с любым содержимым. Форматы на основе ZIP, XML и текстовые форматы сверяются по семейству.
+func Compatible(declared, detected string) bool {
+	declared, detected = BaseType(declared), BaseType(detected)
+	if declared == "" || declared == detected || declared == OctetStream || detected == OctetStream {
+		return true
+	}
+
+	declaredTop, declaredSub, _ := strings.Cut(declared, "/")
+	detectedTop, _, _ := strings.Cut(detected, "/")
+
+	switch detected {
+	case TypeWindowsExecutable, TypeELF, TypeMachO, TypeJavaClass:
+		return false
+	case "text/plain", TypeShellScript:
+		return declaredTop == "text" || isTextApplication(declaredSub)
+	case "text/xml", "text/html":
+		return declaredTop == "text" || declared == "application/xml" || strings.HasSuffix(declaredSub, "+xml")
+	case "application/zip":
+		return declaredTop == "application" && (strings.HasSuffix(declaredSub, "+zip") || strings.HasPrefix(declaredSub, "vnd.") ||
+			strings.Contains(declaredSub, "zip") || declaredSub == "java-archive")
+	case "application/x-gzip":
+		return declaredTop == "application" && (strings.Contains(declaredSub, "gzip") || strings.Contains(declaredSub, "tar"))
+	case "application/ogg":
+		return declaredTop == "audio" || declaredTop == "video"
+	}
+
+	// Разные форматы изображений, звука или видео не опасны, если заявлен тот же вид содержимого
+	switch detectedTop {
+	case "image", "audio", "video", "font":
+		return declaredTop == detectedTop
+	}
+	return false
+}
+
+func isTextApplication(sub string) bool {
+	switch sub {
+	case "json", "xml", "javascript", "ecmascript", "x-yaml", "yaml", "toml", "csv", "sql", "x-sh", "x-httpd-php", "rtf":
+		return true
+	}
+	return strings.HasSuffix(sub, "+json") || strings.HasSuffix(sub, "+xml")
+}
+
+// Match сообщает, подходит ли тип под один из шаблонов: "image/png", "image/*" или "*/*"
+func Match(patterns []string, contentType string) bool {
+	contentType = BaseType(contentType)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if ok, err := path.Match(pattern, contentType); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+// Package unpack читает ZIP, tar и tar.gz архивы с защитой от zip-бомб, выхода путей
+// за пределы архива и слишком большого числа записей.
+package unpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Форматы архива
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+var (
+	ErrUnsupported      = errors.New("unsupported archive format")
+	ErrUnsafePath       = errors.New("archive entry has unsafe path")
+	ErrTooManyEntries   = errors.New("archive has too many entries")
+	ErrTooLarge         = errors.New("archive is too large when unpacked")
+	ErrCompressionRatio = errors.New("archive compression ratio is too high")
+)
+
+// Limits ограничения распаковки, нулевое значение означает отсутствие ограничения
+type Limits struct {
+	MaxEntries   int   // файлов в архиве
+	MaxTotalSize int64 // суммарный размер распакованных файлов
+	MaxRatio     int64 // отношение распакованного размера к сжатому
+}
+
+// Entry файл из архива. Path очищен, разделитель "/", без ведущего "/" и "..".
+type Entry struct {
+	Path    string
+	ModTime time.Time
+	Data    []byte
+}
+
+// Detect определяет формат архива по сигнатуре, пустая строка - не архив
+func Detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return FormatZip
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return FormatTarGz
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		return FormatTar
+	}
+	return ""
+}
+
+// Walk сначала проверяет весь архив по заголовкам записей и только затем по одной передает
+// файлы в fn. Каталоги, ссылки и служебные записи пропускаются.
+func Walk(data []byte, limits Limits, fn func(*Entry) error) error {
+	switch Detect(data) {
+	case FormatZip:
+		return walkZip(data, limits, fn)
+	case FormatTar, FormatTarGz:
+		if err := checkTar(data, limits); err != nil {
+			return err
+		}
+		return walkTar(data, fn)
+	}
+	return ErrUnsupported
+}
+
+func walkZip(data []byte, limits Limits, fn func(*Entry) error) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ErrUnsupported
+	}
+
+	var files []*zip.File
+	paths := make(map[*zip.File]string)
+	var total int64
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() || skipEntry(f.Name) {
+			continue
+		}
+		p, err := cleanPath(f.Name)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		paths[f] = p
+
+		if limits.MaxEntries > 0 && len(files) > limits.MaxEntries {
+			return ErrTooManyEntries
+		}
+		size := f.UncompressedSize64
+		if limits.MaxTotalSize > 0 && (size > uint64(limits.MaxTotalSize) || total+int64(size) > limits.MaxTotalSize) {
+			return ErrTooLarge
+		}
+		total += int64(size)
+		if limits.MaxRatio > 0 && size > 0 {
+			if f.CompressedSize64 == 0 || size/f.CompressedSize64 > uint64(limits.MaxRatio) {
+				return ErrCompressionRatio
+			}
+		}
+	}
+
+	for _, f := range files {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		// Заголовку нельзя доверять: читаем не больше заявленного размера
+		content, err := io.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if uint64(len(content)) > f.UncompressedSize64 {
+			return ErrTooLarge
+		}
+		if err := fn(&Entry{Path: paths[f], ModTime: f.Modified, Data: content}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openTar(data []byte) (*tar.Reader, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if Detect(data) == FormatTarGz {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, ErrUnsupported
+		}
+		r = gz
+	}
+	return tar.NewReader(r), nil
+}
+
+// checkTar проходит по заголовкам tar. Данные записей при этом пропускаются, но для tar.gz
+// все равно распаковываются, поэтому проверка прерывается, как только превышен лимит.
+func checkTar(data []byte, limits Limits) error {
+	tr, err := openTar(data)
+	if err != nil {
+		return err
+	}
+
+	entries := 0
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if entries == 0 {
+				return ErrUnsupported
+			}
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() || skipEntry(hdr.Name) {
+			continue
+		}
+		if _, err := cleanPath(hdr.Name); err != nil {
+			return err
+		}
+
+		entries++
+		if limits.MaxEntries > 0 && entries > limits.MaxEntries {
+			return ErrTooManyEntries
+		}
+		if hdr.Size < 0 || (limits.MaxTotalSize > 0 && (hdr.Size > limits.MaxTotalSize || total+hdr.Size > limits.MaxTotalSize)) {
+			return ErrTooLarge
+		}
+		total += hdr.Size
+		if limits.MaxRatio > 0 && Detect(data) == FormatTarGz && total/int64(len(data)) > limits.MaxRatio {
+			return ErrCompressionRatio
+		}
+	}
+	return nil
+}
+
+func walkTar(data []byte, fn func(*Entry) error) error {
+	tr, err := openTar(data)
+	if err != nil {
+		return err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() || skipEntry(hdr.Name) {
+			continue
+		}
+		p, err := cleanPath(hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		content, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if err != nil {
+			return err
+		}
+		if err := fn(&Entry{Path: p, ModTime: hdr.ModTime, Data: content}); err != nil {
+			return err
+		}
+	}
+}
+
+// skipEntry служебные записи, которые добавляют архиваторы macOS
+func skipEntry(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store"
+}
+
+// cleanPath нормализует путь записи и отклоняет абсолютные пути и выход за пределы архива
+func cleanPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsRune(name, 0) ||
+		(len(name) >= 2 && name[1] == ':') {
+		return "", ErrUnsafePath
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", ErrUnsafePath
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,217 @@
+package unpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+)
+
+type file struct {
+	name string
+	data []byte
+}
+
+func zipArchive(t *testing.T, files ...file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T, gz bool, files ...file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gw *gzip.Writer
+	if gz {
+		gw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gw)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// archives собирает одни и те же файлы во всех поддерживаемых форматах
+func archives(t *testing.T, files ...file) map[string][]byte {
+	return map[string][]byte{
+		FormatZip:   zipArchive(t, files...),
+		FormatTar:   tarArchive(t, false, files...),
+		FormatTarGz: tarArchive(t, true, files...),
+	}
+}
+
+func walkPaths(data []byte, limits Limits) ([]string, error) {
+	var paths []string
+	err := Walk(data, limits, func(e *Entry) error {
+		paths = append(paths, e.Path)
+		return nil
+	})
+	return paths, err
+}
+
+func TestDetect(t *testing.T) {
+	for format, data := range archives(t, file{"a.txt", []byte("a")}) {
+		if got := Detect(data); got != format {
+			t.Errorf("Detect(%s) = %q", format, got)
+		}
+	}
+	if got := Detect([]byte("plain text")); got != "" {
+		t.Errorf("Detect(text) = %q, want empty", got)
+	}
+	if _, err := walkPaths([]byte("plain text"), Limits{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Walk(text) error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	files := []file{
+		{"docs/a.txt", []byte("alpha")},
+		{"./docs//b.txt", []byte("beta")},
+		{"__MACOSX/docs/._a.txt", []byte("junk")},
+		{"docs/.DS_Store", []byte("junk")},
+	}
+
+	for format, data := range archives(t, files...) {
+		var entries []Entry
+		err := Walk(data, Limits{}, func(e *Entry) error {
+			entries = append(entries, *e)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("%s: got %d entries, want 2", format, len(entries))
+		}
+		if entries[0].Path != "docs/a.txt" || string(entries[0].Data) != "alpha" ||
+			entries[1].Path != "docs/b.txt" || string(entries[1].Data) != "beta" {
+			t.Errorf("%s: unexpected entries %+v", format, entries)
+		}
+	}
+}
+
+func TestWalkUnsafePath(t *testing.T) {
+	for _, name := range []string{"../evil.txt", "docs/../../evil.txt", "/etc/passwd", "..\\evil.txt", "C:/evil.txt"} {
+		for format, data := range archives(t, file{"ok.txt", []byte("ok")}, file{name, []byte("x")}) {
+			called := false
+			err := Walk(data, Limits{}, func(*Entry) error {
+				called = true
+				return nil
+			})
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("%s %q: error = %v, want ErrUnsafePath", format, name, err)
+			}
+			// Архив проверяется целиком до передачи первого файла
+			if called {
+				t.Errorf("%s %q: entries were passed before the check failed", format, name)
+			}
+		}
+	}
+}
+
+func TestWalkLimits(t *testing.T) {
+	small := []file{{"a.txt", []byte("aaaa")}, {"b.txt", []byte("bbbb")}, {"c.txt", []byte("cccc")}}
+	bomb := []file{{"zeros.bin", make([]byte, 1<<20)}}
+
+	tests := []struct {
+		name    string
+		files   []file
+		limits  Limits
+		formats []string
+		wantErr error
+	}{
+		{name: "within limits", files: small, limits: Limits{MaxEntries: 3, MaxTotalSize: 12, MaxRatio: 100}},
+		{name: "too many entries", files: small, limits: Limits{MaxEntries: 2}, wantErr: ErrTooManyEntries},
+		{name: "total size", files: small, limits: Limits{MaxTotalSize: 11}, wantErr: ErrTooLarge},
+		{name: "single file size", files: bomb, limits: Limits{MaxTotalSize: 1 << 19}, wantErr: ErrTooLarge},
+		{name: "compression ratio", files: bomb, limits: Limits{MaxRatio: 100},
+			formats: []string{FormatZip, FormatTarGz}, wantErr: ErrCompressionRatio},
+		{name: "ratio ignored for plain tar", files: bomb, limits: Limits{MaxRatio: 100},
+			formats: []string{FormatTar}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := archives(t, tt.files...)
+			formats := tt.formats
+			if formats == nil {
+				formats = []string{FormatZip, FormatTar, FormatTarGz}
+			}
+			for _, format := range formats {
+				if _, err := walkPaths(all[format], tt.limits); !errors.Is(err, tt.wantErr) {
+					t.Errorf("%s: error = %v, want %v", format, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "a.txt", want: "a.txt"},
+		{name: "dir/sub/a.txt", want: "dir/sub/a.txt"},
+		{name: "./dir//a.txt", want: "dir/a.txt"},
+		{name: "dir\\a.txt", want: "dir/a.txt"},
+		{name: "dir/./a.txt", want: "dir/a.txt"},
+		{name: "", wantErr: true},
+		{name: ".", wantErr: true},
+		{name: "./", wantErr: true},
+		{name: "/abs.txt", wantErr: true},
+		{name: "\\abs.txt", wantErr: true},
+		{name: "C:\\win.txt", wantErr: true},
+		{name: "../up.txt", wantErr: true},
+		{name: "dir/../a.txt", wantErr: true},
+		{name: "dir\\..\\..\\up.txt", wantErr: true},
+		{name: "nul\x00.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanPath(tt.name)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("cleanPath(%q) = %q, %v, want ErrUnsafePath", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}